@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+
+	"github.com/aqyuki/felm/internal/app"
+	"github.com/aqyuki/felm/pkg/admin"
+	"github.com/aqyuki/felm/pkg/logging"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// serveAdmin serves the admin API on the configured address until ctx is done. It does nothing when the API is disabled.
+func serveAdmin(ctx context.Context, cfg app.AdminConfig, option ...admin.Option) {
+	if cfg.Address == "" {
+		return
+	}
+	if err := admin.New(cfg.Token, option...).ListenAndServe(ctx, cfg.Address); err != nil {
+		logging.FromContext(ctx).Error("admin API stopped", zap.Error(err))
+	}
+}
+
+// configView returns the redacted configuration with the keys of the config file, as `felm config print` shows it.
+func configView(cfg *app.Config) any {
+	data, err := yaml.Marshal(cfg.Redacted())
+	if err != nil {
+		return nil
+	}
+	var view map[string]any
+	if err := yaml.Unmarshal(data, &view); err != nil {
+		return nil
+	}
+	return view
+}
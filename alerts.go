@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aqyuki/felm/internal/app"
+	"github.com/aqyuki/felm/pkg/alert"
+	"github.com/aqyuki/felm/pkg/discord"
+	"github.com/aqyuki/felm/pkg/report"
+)
+
+// newAlerter returns the alerter posting to the configured webhook or channel, or nil when the alerts are disabled.
+func newAlerter(cfg *app.Config) (*alert.Alerter, error) {
+	if !cfg.Alert.Enabled() {
+		return nil, nil
+	}
+
+	senders := make([]alert.Sender, 0, 2)
+	if cfg.Alert.Webhook != "" {
+		senders = append(senders, alert.NewWebhook(cfg.Alert.Webhook, cfg.Timeout))
+	}
+	if cfg.Alert.ChannelID != "" {
+		session, err := newRESTSession(cfg.Token)
+		if err != nil {
+			return nil, err
+		}
+		senders = append(senders, alert.NewChannel(session, cfg.Alert.ChannelID))
+	}
+	sender := alert.SenderFunc(func(ctx context.Context, a alert.Alert) error {
+		errs := make([]error, 0, len(senders))
+		for _, s := range senders {
+			errs = append(errs, s.Send(ctx, a))
+		}
+		return errors.Join(errs...)
+	})
+	// felm starts and stops rarely and on purpose, so every lifecycle alert is sent.
+	return alert.New(sender,
+		alert.WithCooldown(cfg.Alert.Cooldown),
+		alert.WithKindCooldown(alert.KindStartup, 0),
+		alert.WithKindCooldown(alert.KindShutdown, 0)), nil
+}
+
+// alertHooks are the detectors of the alerts, hooked into the reporter, the REST API and the connection.
+// They are all empty when the alerts are disabled.
+type alertHooks struct {
+	errors      report.Reporter
+	onForbidden func(guildID, channelID string)
+	rest        []discord.RESTOption
+	conn        []discord.Option
+}
+
+// runAlerts runs alerter until ctx is done and returns its detectors. It returns no hooks when alerter is nil.
+func runAlerts(ctx context.Context, alerter *alert.Alerter, cfg app.AlertConfig) alertHooks {
+	if alerter == nil {
+		return alertHooks{}
+	}
+	go alerter.Run(ctx)
+
+	rateLimits := alerter.RateLimits(cfg.RateLimits.Threshold, cfg.RateLimits.Window)
+	return alertHooks{
+		errors:      alerter.HandlerErrors(cfg.Errors.Threshold, cfg.Errors.Window),
+		onForbidden: alerter.PermissionDenied(cfg.Forbidden.Threshold, cfg.Forbidden.Window),
+		rest:        []discord.RESTOption{discord.WithRateLimitHook(rateLimits.OnREST)},
+		conn: []discord.Option{
+			discord.WithLifecycleHandler(alerter.Disconnects(cfg.Disconnects.Threshold, cfg.Disconnects.Window)),
+			discord.WithEventHandler(rateLimits.OnEvent),
+		},
+	}
+}
+
+// startupAlert is the alert sent when felm is connected.
+func startupAlert(shards int) alert.Alert {
+	return alert.Alert{
+		Kind:     alert.KindStartup,
+		Severity: alert.SeverityInfo,
+		Title:    "felm started",
+		Fields:   []alert.Field{{Name: "Shards", Value: strconv.Itoa(shards)}},
+	}
+}
+
+// shutdownAlert is the alert sent when felm stops, which is critical when the connection could not recover.
+func shutdownAlert(cause error) alert.Alert {
+	if cause != nil {
+		return alert.Alert{
+			Kind:        alert.KindShutdown,
+			Severity:    alert.SeverityCritical,
+			Title:       "felm stopped because the connection can not recover",
+			Description: fmt.Sprint(cause),
+		}
+	}
+	return alert.Alert{
+		Kind:     alert.KindShutdown,
+		Severity: alert.SeverityInfo,
+		Title:    "felm stopped",
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aqyuki/felm/internal/app"
+	"github.com/aqyuki/felm/pkg/audit"
+	"github.com/bwmarrin/discordgo"
+)
+
+// openAuditSink opens the audit log and prunes it until ctx is done, or returns nil when the audit log is disabled.
+func openAuditSink(ctx context.Context, cfg app.AuditConfig) (audit.Sink, error) {
+	if cfg.Path == "" {
+		return nil, nil
+	}
+	sink, err := audit.Open(cfg.Sink, cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	go audit.RunRetention(ctx, sink, cfg.Retention, audit.DefaultPruneInterval)
+	return sink, nil
+}
+
+// registerCommand creates or updates the global application command of the bot.
+func registerCommand(ctx context.Context, token string, command *discordgo.ApplicationCommand) error {
+	session, err := newRESTSession(token)
+	if err != nil {
+		return err
+	}
+	application, err := session.Application("@me")
+	if err != nil {
+		return fmt.Errorf("failed to fetch the application of the bot: %w", err)
+	}
+	// creating a global command with the name of an existing one updates it, leaving the other commands untouched.
+	if _, err := session.ApplicationCommandCreate(application.ID, "", command, discordgo.WithContext(ctx)); err != nil {
+		return fmt.Errorf("failed to register the command (name = %s): %w", command.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+
+	"github.com/aqyuki/felm/internal/app"
+	"github.com/aqyuki/felm/pkg/cache"
+	"github.com/aqyuki/felm/pkg/logging"
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+// newCacheBackend returns the Redis backend shared by the caches, or nil when the caches are kept in memory.
+// An unreachable Redis is only logged, because the caches fall back to memory until it recovers.
+func newCacheBackend(ctx context.Context, cfg *app.Config) *cache.RedisBackend {
+	if cfg.Cache.Redis.Addr == "" {
+		return nil
+	}
+	backend := cache.NewRedisBackend(cfg.Cache.Redis.Addr,
+		cache.WithRedisPassword(cfg.Cache.Redis.Password),
+		cache.WithRedisDB(cfg.Cache.Redis.DB))
+
+	pingCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	if err := backend.Ping(pingCtx); err != nil {
+		logging.FromContext(ctx).Warn("redis backend is unreachable, caches fall back to memory until it recovers", zap.Error(err))
+	}
+	return backend
+}
+
+// cacheOptions returns the options of a cache whose entries are shared under prefix in backend, if any.
+func cacheOptions(cfg app.CacheConfig, backend *cache.RedisBackend, prefix string) []cache.Option {
+	options := []cache.Option{
+		cache.WithCapacity(cfg.Capacity),
+		cache.WithPolicy(cfg.EvictionPolicy()),
+	}
+	if backend != nil {
+		options = append(options, cache.WithBackend(cache.PrefixBackend(backend, prefix), cfg.EntryCodec()))
+	}
+	return options
+}
+
+// restoreSnapshot restores the channel cache from the snapshot at path. A snapshot which can not be read is ignored.
+// Messages are not restored, because they may have been edited or deleted while the bot was offline.
+func restoreSnapshot(ctx context.Context, channels *cache.Cache[discordgo.Channel], path string) {
+	logger := logging.FromContext(ctx)
+	n, err := channels.LoadFile(path)
+	if err != nil {
+		logger.Warn("cache snapshot was ignored", zap.String("path", path), zap.Error(err))
+		return
+	}
+	logger.Info("cache snapshot was restored", zap.String("path", path), zap.Int("entries", n))
+}
+
+// saveSnapshot saves the channel cache to path, so that the next start does not fetch every channel again.
+func saveSnapshot(ctx context.Context, channels *cache.Cache[discordgo.Channel], path string) {
+	logger := logging.FromContext(ctx)
+	n, err := channels.SaveFile(path)
+	if err != nil {
+		logger.Error("failed to save cache snapshot", zap.String("path", path), zap.Error(err))
+		return
+	}
+	logger.Info("cache snapshot was saved", zap.String("path", path), zap.Int("entries", n))
+}
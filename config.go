@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/aqyuki/felm/internal/app"
+	"github.com/aqyuki/felm/pkg/logging"
+	"github.com/aqyuki/felm/pkg/secret"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "config inspects the configuration of felm",
+}
+
+var configPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "print shows the effective configuration as YAML with secrets redacted",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		// the configuration is shown even if it is invalid, so that the cause can be found.
+		var cfg app.Config
+		if err := viper.Unmarshal(&cfg); err != nil {
+			return fmt.Errorf("failed to decode the configuration: %w", err)
+		}
+
+		if path := viper.ConfigFileUsed(); path != "" {
+			fmt.Fprintf(cmd.OutOrStdout(), "# config file: %s\n", path)
+		}
+		encoder := yaml.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent(2)
+		defer encoder.Close()
+		return encoder.Encode(cfg.Redacted())
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "validate checks the configuration and reports every problem",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if _, err := app.LoadConfig(cmd.Context(), viper.GetViper(), secret.NewResolver()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			cmd.SilenceErrors = true
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), "configuration is valid")
+		return nil
+	},
+}
+
+// configChangeDelay is how long the reload waits after a change of the config file,
+// because an editor saving the file causes several events.
+const configChangeDelay = 100 * time.Millisecond
+
+// watchConfig reloads the configuration when the config file is changed or SIGHUP is received, until ctx is done.
+// viper is not safe for concurrent use, so the file is watched here instead of by viper.WatchConfig,
+// and every read of viper after the startup is made by this loop.
+func watchConfig(ctx context.Context, resolver *secret.Resolver, reloader *app.Reloader) {
+	logger := logging.FromContext(ctx)
+
+	path := viper.ConfigFileUsed()
+	if path != "" {
+		path = filepath.Clean(path)
+	}
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	if path != "" {
+		watcher, err := watchConfigFile(path)
+		if err != nil {
+			logger.Warn("failed to watch the config file, reload it with SIGHUP instead", zap.String("config_file", path), zap.Error(err))
+		} else {
+			defer watcher.Close()
+			events, errs = watcher.Events, watcher.Errors
+		}
+	}
+	// the real path is compared because Kubernetes replaces a mounted file by switching the symbolic link to it.
+	realPath, _ := filepath.EvalSymlinks(path)
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	var changed <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-events:
+			current, _ := filepath.EvalSymlinks(path)
+			written := filepath.Clean(event.Name) == path && event.Has(fsnotify.Write|fsnotify.Create)
+			if written || (current != "" && current != realPath) {
+				realPath = current
+				changed = time.After(configChangeDelay)
+			}
+			continue
+		case err := <-errs:
+			logger.Warn("failed to watch the config file", zap.Error(err))
+			continue
+		case <-changed:
+			changed = nil
+			logger.Info("config file was changed, reloading configuration", zap.String("config_file", path))
+		case <-hangup:
+			logger.Info("SIGHUP received, reloading configuration")
+		}
+
+		if path != "" {
+			if err := viper.ReadInConfig(); err != nil {
+				logger.Error("failed to read the config file, configuration was not reloaded", zap.Error(err))
+				continue
+			}
+		}
+		reload(ctx, resolver, reloader)
+	}
+}
+
+// watchConfigFile watches the directory of the config file, so that the file is still watched after an editor replaces it.
+func watchConfigFile(path string) (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		_ = watcher.Close()
+		return nil, err
+	}
+	return watcher, nil
+}
+
+// reload validates the configuration read by viper and applies it.
+func reload(ctx context.Context, resolver *secret.Resolver, reloader *app.Reloader) {
+	logger := logging.FromContext(ctx)
+
+	cfg, err := app.LoadConfig(ctx, viper.GetViper(), resolver)
+	if err != nil {
+		logger.Error("new configuration is invalid, keeping the running one", zap.Error(err))
+		return
+	}
+
+	changes, err := reloader.Reload(cfg)
+	if errors.Is(err, app.ErrNotReloadable) {
+		logger.Error("new configuration was rejected, keeping the running one", zap.Error(err))
+		return
+	}
+	if len(changes) == 0 {
+		logger.Info("configuration was reloaded without changes")
+		return
+	}
+	for _, change := range changes {
+		logger.Info("configuration was changed",
+			zap.String("key", change.Key),
+			zap.Any("old", change.Old),
+			zap.Any("new", change.New))
+	}
+}
+
+func init() {
+	configCmd.AddCommand(configPrintCmd, configValidateCmd)
+}
@@ -0,0 +1,623 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/aqyuki/felm/internal/app/handler"
+	"github.com/aqyuki/felm/pkg/audit"
+	"github.com/aqyuki/felm/pkg/cache"
+	"github.com/aqyuki/felm/pkg/discord"
+	"github.com/aqyuki/felm/pkg/logging"
+	"github.com/aqyuki/felm/pkg/ratelimit"
+	"github.com/aqyuki/felm/pkg/report"
+	"github.com/aqyuki/felm/pkg/secret"
+	"github.com/bwmarrin/discordgo"
+	"github.com/spf13/viper"
+	"go.uber.org/zap/zapcore"
+)
+
+// ErrInvalidConfig is returned when the configuration can not be used to start felm.
+var ErrInvalidConfig = errors.New("invalid configuration")
+
+// The outputs of the logs.
+const (
+	LogOutputStderr = "stderr"
+	LogOutputFile   = "file"
+	LogOutputBoth   = "both"
+)
+
+// Config is the configuration of felm.
+// It is loaded from flags, environment variables (FELM_ followed by the key with dots replaced by underscores),
+// the config file and the defaults, in that order of precedence.
+type Config struct {
+	// Token is the Discord bot token, or a reference to it such as file:///run/secrets/token or env://DISCORD_TOKEN.
+	// It holds the token itself once the configuration is loaded.
+	Token string `mapstructure:"token" yaml:"token"`
+
+	// TokenFile is the file containing the token, e.g. a Docker or Kubernetes secret. It can not be set with Token.
+	TokenFile string `mapstructure:"token_file" yaml:"token_file"`
+
+	// Timeout is the timeout of the handlers and the requests to Redis.
+	Timeout time.Duration `mapstructure:"timeout" yaml:"timeout"`
+
+	// ShutdownTimeout is the duration to wait for the running handlers on shutdown.
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout" yaml:"shutdown_timeout"`
+
+	// Shards is the number of gateway shards. 0 uses the number recommended by Discord.
+	Shards int `mapstructure:"shards" yaml:"shards"`
+
+	// Intents is a comma separated list of gateway intents requested in addition to the ones the handlers require.
+	Intents string `mapstructure:"intents" yaml:"intents"`
+
+	// MaxDisconnection is the duration a shard can stay disconnected before felm exits. 0 disables it.
+	MaxDisconnection time.Duration `mapstructure:"max_disconnection" yaml:"max_disconnection"`
+
+	// Preflight checks the token, the intents and the permissions of the bot before connecting.
+	Preflight bool `mapstructure:"preflight" yaml:"preflight"`
+
+	// StateFallback enables looking up channels in the discordgo state before calling the REST API.
+	StateFallback bool `mapstructure:"state_fallback" yaml:"state_fallback"`
+
+	Log       LogConfig       `mapstructure:"log" yaml:"log"`
+	Presence  PresenceConfig  `mapstructure:"presence" yaml:"presence"`
+	RateLimit RateLimitConfig `mapstructure:"ratelimit" yaml:"ratelimit"`
+	Embed     EmbedConfig     `mapstructure:"embed" yaml:"embed"`
+	Cache     CacheConfig     `mapstructure:"cache" yaml:"cache"`
+	Report    ReportConfig    `mapstructure:"report" yaml:"report"`
+	Alert     AlertConfig     `mapstructure:"alert" yaml:"alert"`
+	Admin     AdminConfig     `mapstructure:"admin" yaml:"admin"`
+	Audit     AuditConfig     `mapstructure:"audit" yaml:"audit"`
+}
+
+// LogConfig is the levels and the outputs of the logs.
+type LogConfig struct {
+	// Level is the minimum level of the logs, e.g. debug or warning. Empty keeps the level given by LOG_LEVEL.
+	Level string `mapstructure:"level" yaml:"level"`
+
+	// Levels overrides the level of the loggers of subsystems, e.g. discord=debug,cache=warning.
+	// Empty keeps the overrides given by LOG_LEVELS.
+	Levels string `mapstructure:"levels" yaml:"levels"`
+
+	// Format is the profile of the logs, gcp, ecs, logfmt or console. Empty chooses console when LOG_MODE is develop and gcp otherwise.
+	Format string `mapstructure:"format" yaml:"format"`
+
+	// TraceProject is the Google Cloud project the gcp format links the trace IDs to.
+	TraceProject string `mapstructure:"trace_project" yaml:"trace_project"`
+
+	// Output is where the logs are written, stderr, file or both.
+	Output string `mapstructure:"output" yaml:"output"`
+
+	File LogFileConfig `mapstructure:"file" yaml:"file"`
+
+	// Sampling limits the logs with the same level and message every second.
+	Sampling SamplingConfig `mapstructure:"sampling" yaml:"sampling"`
+
+	// DemoteMessages logs the events of every message at debug instead of info.
+	DemoteMessages bool `mapstructure:"demote_messages" yaml:"demote_messages"`
+
+	// SummaryInterval is the interval to log the numbers of messages handled. 0 disables the summary.
+	SummaryInterval time.Duration `mapstructure:"summary_interval" yaml:"summary_interval"`
+
+	Privacy PrivacyConfig `mapstructure:"privacy" yaml:"privacy"`
+}
+
+// PrivacyConfig pseudonymizes the users in the logs.
+type PrivacyConfig struct {
+	// Enabled hashes the user IDs and drops the usernames and the message contents in the logs.
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+
+	// Fields overrides the actions on the fields, e.g. author.id=drop,content=keep.
+	Fields string `mapstructure:"fields" yaml:"fields"`
+
+	// Salt is the key of the hashes, or a reference to it. The same salt keeps the hashes of a user the same across restarts.
+	Salt string `mapstructure:"salt" yaml:"salt"`
+}
+
+// LogFileConfig is the log file, which is rotated when it exceeds MaxSize.
+type LogFileConfig struct {
+	Path string `mapstructure:"path" yaml:"path"`
+
+	// MaxSize is the size in megabytes at which the file is rotated.
+	MaxSize int `mapstructure:"max_size" yaml:"max_size"`
+
+	// MaxAge is the age after which rotated files are removed. 0 keeps them regardless of their age.
+	MaxAge time.Duration `mapstructure:"max_age" yaml:"max_age"`
+
+	// MaxBackups is the number of rotated files to keep. 0 keeps every file.
+	MaxBackups int `mapstructure:"max_backups" yaml:"max_backups"`
+}
+
+// SamplingConfig logs the first Initial entries with the same level and message every second,
+// and every Thereafter-th entry after them. An Initial of 0 disables sampling.
+type SamplingConfig struct {
+	Initial    int `mapstructure:"initial" yaml:"initial"`
+	Thereafter int `mapstructure:"thereafter" yaml:"thereafter"`
+}
+
+// EmbedConfig is the appearance of the replies of citations.
+type EmbedConfig struct {
+	// Color is the color of the embeds as an RGB integer, e.g. 0x7fffff.
+	Color int `mapstructure:"color" yaml:"color"`
+
+	// MentionAuthor mentions the author of the message containing the link in the reply.
+	MentionAuthor bool `mapstructure:"mention_author" yaml:"mention_author"`
+}
+
+// PresenceConfig is the status and activity shown in the member list.
+type PresenceConfig struct {
+	Status       string        `mapstructure:"status" yaml:"status"`
+	ActivityType string        `mapstructure:"activity_type" yaml:"activity_type"`
+	ActivityText string        `mapstructure:"activity_text" yaml:"activity_text"`
+	Interval     time.Duration `mapstructure:"interval" yaml:"interval"`
+}
+
+// RateLimitConfig is the default limits of citations applied to every guild.
+type RateLimitConfig struct {
+	User    LimitConfig `mapstructure:"user" yaml:"user"`
+	Channel LimitConfig `mapstructure:"channel" yaml:"channel"`
+	Guild   LimitConfig `mapstructure:"guild" yaml:"guild"`
+
+	// Reaction is the emoji added to messages whose citation was rate limited. Empty disables the reaction.
+	Reaction string `mapstructure:"reaction" yaml:"reaction"`
+
+	// Guilds overrides the limits of the guilds by their IDs. It can only be set in the config file.
+	Guilds map[string]GuildLimitConfig `mapstructure:"guilds" yaml:"guilds,omitempty"`
+}
+
+// GuildLimitConfig is the limits of a guild. The limits which are not set are the defaults.
+type GuildLimitConfig struct {
+	User    *LimitConfig `mapstructure:"user" yaml:"user,omitempty"`
+	Channel *LimitConfig `mapstructure:"channel" yaml:"channel,omitempty"`
+	Guild   *LimitConfig `mapstructure:"guild" yaml:"guild,omitempty"`
+}
+
+// LimitConfig is a token bucket. A burst of 0 disables the limit.
+type LimitConfig struct {
+	Burst    int           `mapstructure:"burst" yaml:"burst"`
+	Interval time.Duration `mapstructure:"interval" yaml:"interval"`
+}
+
+// CacheConfig is the configuration of the caches of channels and messages.
+type CacheConfig struct {
+	// Capacity is the maximum number of cached channels and messages each. 0 means unbounded.
+	Capacity int    `mapstructure:"capacity" yaml:"capacity"`
+	Policy   string `mapstructure:"policy" yaml:"policy"`
+	Codec    string `mapstructure:"codec" yaml:"codec"`
+
+	// SnapshotPath is the file the channel cache is saved to on shutdown and restored from on startup.
+	// Empty disables the snapshot.
+	SnapshotPath string `mapstructure:"snapshot_path" yaml:"snapshot_path"`
+
+	Redis RedisConfig `mapstructure:"redis" yaml:"redis"`
+}
+
+// ReportConfig is where the errors of the handlers are reported in addition to the logs.
+type ReportConfig struct {
+	// SentryDSN is the DSN of Sentry or a compatible server, or a reference to it. Empty disables it.
+	SentryDSN string `mapstructure:"sentry_dsn" yaml:"sentry_dsn"`
+
+	// Webhook is the URL of a Discord webhook, or a reference to it. Empty disables it.
+	Webhook string `mapstructure:"webhook" yaml:"webhook"`
+
+	// ChannelID is the channel the bot reports the errors to. Empty disables it.
+	ChannelID string `mapstructure:"channel_id" yaml:"channel_id"`
+
+	// DedupeWindow is the duration during which the same errors are reported once. 0 reports every error.
+	DedupeWindow time.Duration `mapstructure:"dedupe_window" yaml:"dedupe_window"`
+
+	// Burst and Interval limit the rate of the reports. A burst of 0 disables the limit.
+	Burst    int           `mapstructure:"burst" yaml:"burst"`
+	Interval time.Duration `mapstructure:"interval" yaml:"interval"`
+}
+
+// AlertConfig is where and when the operators are alerted of the health of felm.
+// The alerts are disabled when neither Webhook nor ChannelID is set.
+type AlertConfig struct {
+	// Webhook is the URL of a Discord webhook, or a reference to it.
+	Webhook string `mapstructure:"webhook" yaml:"webhook"`
+
+	// ChannelID is the channel the bot posts the alerts to.
+	ChannelID string `mapstructure:"channel_id" yaml:"channel_id"`
+
+	// Cooldown is the duration after an alert during which the same alert is not sent again.
+	Cooldown time.Duration `mapstructure:"cooldown" yaml:"cooldown"`
+
+	// Lifecycle alerts when felm starts and stops.
+	Lifecycle bool `mapstructure:"lifecycle" yaml:"lifecycle"`
+
+	Errors      SpikeConfig `mapstructure:"errors" yaml:"errors"`
+	Disconnects SpikeConfig `mapstructure:"disconnects" yaml:"disconnects"`
+	RateLimits  SpikeConfig `mapstructure:"ratelimits" yaml:"ratelimits"`
+	Forbidden   SpikeConfig `mapstructure:"forbidden" yaml:"forbidden"`
+}
+
+// Enabled reports whether the alerts have a destination.
+func (c AlertConfig) Enabled() bool {
+	return c.Webhook != "" || c.ChannelID != ""
+}
+
+// SpikeConfig alerts when Threshold events happen within Window. A threshold of 0 disables the alert.
+type SpikeConfig struct {
+	Threshold int           `mapstructure:"threshold" yaml:"threshold"`
+	Window    time.Duration `mapstructure:"window" yaml:"window"`
+}
+
+// AdminConfig is the HTTP API to inspect the running felm. It is disabled when Address is empty.
+type AdminConfig struct {
+	// Address is the address to listen on, e.g. 127.0.0.1:8081. It should not be reachable from the internet.
+	Address string `mapstructure:"address" yaml:"address"`
+
+	// Token is the bearer token required by every request, or a reference to it.
+	Token string `mapstructure:"token" yaml:"token"`
+}
+
+// AuditConfig is the audit log of the message links. It is disabled when Path is empty.
+type AuditConfig struct {
+	// Sink is the format of the audit log, jsonl or sqlite.
+	Sink string `mapstructure:"sink" yaml:"sink"`
+
+	// Path is the JSON lines file or the SQLite database the records are stored in.
+	Path string `mapstructure:"path" yaml:"path"`
+
+	// Retention is how long the records are kept. 0 keeps them forever.
+	Retention time.Duration `mapstructure:"retention" yaml:"retention"`
+
+	// Command registers `/felm audit` for the admins of the guilds to query the records.
+	Command bool `mapstructure:"command" yaml:"command"`
+}
+
+// RedisConfig is the Redis server shared by the caches. An empty address keeps the caches in memory only.
+type RedisConfig struct {
+	Addr     string `mapstructure:"addr" yaml:"addr"`
+	Password string `mapstructure:"password" yaml:"password"`
+	DB       int    `mapstructure:"db" yaml:"db"`
+}
+
+// LoadConfig decodes the configuration from v, resolves the secrets with resolver and validates it.
+func LoadConfig(ctx context.Context, v *viper.Viper, resolver *secret.Resolver) (*Config, error) {
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+	if err := cfg.ResolveSecrets(ctx, resolver); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// ResolveSecrets replaces the token and the Redis password with the secrets they refer to,
+// and reads the token from TokenFile when it is set.
+func (c *Config) ResolveSecrets(ctx context.Context, resolver *secret.Resolver) error {
+	errs := make([]error, 0)
+
+	if c.TokenFile != "" {
+		if c.Token != "" {
+			errs = append(errs, errors.New("token_file: can not be set with token"))
+		} else {
+			c.Token = secret.Reference(secret.SchemeFile, c.TokenFile)
+		}
+	}
+	for _, s := range []struct {
+		key   string
+		value *string
+	}{
+		{"token", &c.Token},
+		{"cache.redis.password", &c.Cache.Redis.Password},
+		{"log.privacy.salt", &c.Log.Privacy.Salt},
+		{"report.sentry_dsn", &c.Report.SentryDSN},
+		{"report.webhook", &c.Report.Webhook},
+		{"alert.webhook", &c.Alert.Webhook},
+		{"admin.token", &c.Admin.Token},
+	} {
+		resolved, err := resolver.Resolve(ctx, *s.value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.key, err))
+			continue
+		}
+		*s.value = resolved
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w:\n%w", ErrInvalidConfig, errors.Join(errs...))
+	}
+	return nil
+}
+
+// Validate reports every problem of the configuration at once, naming the keys.
+func (c *Config) Validate() error {
+	errs := make([]error, 0)
+	invalid := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if c.Token == "" {
+		invalid("token", "is required, set it with --token, FELM_TOKEN or FELM_TOKEN_FILE")
+	}
+	if _, ok := logging.ParseLevel(c.Log.Level); c.Log.Level != "" && !ok {
+		invalid("log.level", "must be debug, info, warning, error, critical, alert or emergency but is %q", c.Log.Level)
+	}
+	if _, err := logging.ParseLevelOverrides(c.Log.Levels); err != nil {
+		invalid("log.levels", "%v", err)
+	}
+	if _, ok := logging.ParseProfile(c.Log.Format); c.Log.Format != "" && !ok {
+		invalid("log.format", "must be gcp, ecs, logfmt or console but is %q", c.Log.Format)
+	}
+	switch c.Log.Output {
+	case LogOutputStderr, LogOutputFile, LogOutputBoth:
+		if c.Log.Output != LogOutputStderr && c.Log.File.Path == "" {
+			invalid("log.file.path", "is required when the output is %s", c.Log.Output)
+		}
+	default:
+		invalid("log.output", "must be stderr, file or both but is %q", c.Log.Output)
+	}
+	if c.Log.File.MaxSize < 0 {
+		invalid("log.file.max_size", "must not be negative but is %d", c.Log.File.MaxSize)
+	}
+	if c.Log.File.MaxAge < 0 {
+		invalid("log.file.max_age", "must not be negative but is %s", c.Log.File.MaxAge)
+	}
+	if c.Log.File.MaxBackups < 0 {
+		invalid("log.file.max_backups", "must not be negative but is %d", c.Log.File.MaxBackups)
+	}
+	if c.Log.Sampling.Initial < 0 {
+		invalid("log.sampling.initial", "must not be negative but is %d", c.Log.Sampling.Initial)
+	}
+	if c.Log.Sampling.Thereafter < 0 {
+		invalid("log.sampling.thereafter", "must not be negative but is %d", c.Log.Sampling.Thereafter)
+	}
+	if c.Log.SummaryInterval < 0 {
+		invalid("log.summary_interval", "must not be negative but is %s", c.Log.SummaryInterval)
+	}
+	if _, err := logging.ParsePrivacyPolicy(c.Log.Privacy.Fields); err != nil {
+		invalid("log.privacy.fields", "%v", err)
+	}
+	if c.Timeout <= 0 {
+		invalid("timeout", "must be positive but is %s", c.Timeout)
+	}
+	if c.ShutdownTimeout < 0 {
+		invalid("shutdown_timeout", "must not be negative but is %s", c.ShutdownTimeout)
+	}
+	if c.Shards < 0 {
+		invalid("shards", "must not be negative but is %d", c.Shards)
+	}
+	if _, err := discord.ParseIntents(c.Intents); err != nil {
+		invalid("intents", "%v", err)
+	}
+	if c.MaxDisconnection < 0 {
+		invalid("max_disconnection", "must not be negative but is %s", c.MaxDisconnection)
+	}
+
+	if _, err := discord.ParsePresence(c.Presence.Status, c.Presence.ActivityType, c.Presence.ActivityText); err != nil {
+		invalid("presence", "%v", err)
+	}
+
+	// the limits of the guilds which are not set are the defaults, so they are nil.
+	type keyedLimit struct {
+		key   string
+		limit *LimitConfig
+	}
+	limits := []keyedLimit{
+		{"ratelimit.user", &c.RateLimit.User},
+		{"ratelimit.channel", &c.RateLimit.Channel},
+		{"ratelimit.guild", &c.RateLimit.Guild},
+	}
+	for _, guildID := range slices.Sorted(maps.Keys(c.RateLimit.Guilds)) {
+		key, guild := "ratelimit.guilds."+guildID, c.RateLimit.Guilds[guildID]
+		if _, err := strconv.ParseUint(guildID, 10, 64); err != nil {
+			invalid(key, "must be keyed by a guild ID but is keyed by %q", guildID)
+		}
+		limits = append(limits,
+			keyedLimit{key + ".user", guild.User},
+			keyedLimit{key + ".channel", guild.Channel},
+			keyedLimit{key + ".guild", guild.Guild})
+	}
+	for _, l := range limits {
+		key, limit := l.key, l.limit
+		if limit == nil {
+			continue
+		}
+		if limit.Burst < 0 {
+			invalid(key+".burst", "must not be negative but is %d", limit.Burst)
+		}
+		if limit.Burst > 0 && limit.Interval <= 0 {
+			invalid(key+".interval", "must be positive when the burst is set but is %s", limit.Interval)
+		}
+	}
+
+	if c.Embed.Color < 0 || c.Embed.Color > 0xffffff {
+		invalid("embed.color", "must be between 0x000000 and 0xffffff but is %#x", c.Embed.Color)
+	}
+
+	if c.Cache.Capacity < 0 {
+		invalid("cache.capacity", "must not be negative but is %d", c.Cache.Capacity)
+	}
+	if _, ok := cache.ParsePolicy(c.Cache.Policy); !ok {
+		invalid("cache.policy", "must be lru, lfu or ttl but is %q", c.Cache.Policy)
+	}
+	if _, ok := cache.ParseCodec(c.Cache.Codec); !ok {
+		invalid("cache.codec", "must be json or gob but is %q", c.Cache.Codec)
+	}
+	if c.Cache.Redis.DB < 0 {
+		invalid("cache.redis.db", "must not be negative but is %d", c.Cache.Redis.DB)
+	}
+
+	if c.Report.SentryDSN != "" {
+		if _, err := report.NewSentry(c.Report.SentryDSN); err != nil {
+			invalid("report.sentry_dsn", "must be like https://key@host/project")
+		}
+	}
+	if u, err := url.Parse(c.Report.Webhook); c.Report.Webhook != "" && (err != nil || u.Scheme != "https" || u.Host == "") {
+		invalid("report.webhook", "must be an https URL")
+	}
+	if c.Report.DedupeWindow < 0 {
+		invalid("report.dedupe_window", "must not be negative but is %s", c.Report.DedupeWindow)
+	}
+	if c.Report.Burst < 0 {
+		invalid("report.burst", "must not be negative but is %d", c.Report.Burst)
+	}
+	if c.Report.Burst > 0 && c.Report.Interval <= 0 {
+		invalid("report.interval", "must be positive when the burst is set but is %s", c.Report.Interval)
+	}
+
+	if u, err := url.Parse(c.Alert.Webhook); c.Alert.Webhook != "" && (err != nil || u.Scheme != "https" || u.Host == "") {
+		invalid("alert.webhook", "must be an https URL")
+	}
+	if c.Alert.Cooldown < 0 {
+		invalid("alert.cooldown", "must not be negative but is %s", c.Alert.Cooldown)
+	}
+	for _, s := range []struct {
+		key   string
+		spike SpikeConfig
+	}{
+		{"alert.errors", c.Alert.Errors},
+		{"alert.disconnects", c.Alert.Disconnects},
+		{"alert.ratelimits", c.Alert.RateLimits},
+		{"alert.forbidden", c.Alert.Forbidden},
+	} {
+		key, spike := s.key, s.spike
+		if spike.Threshold < 0 {
+			invalid(key+".threshold", "must not be negative but is %d", spike.Threshold)
+		}
+		if spike.Threshold > 0 && spike.Window <= 0 {
+			invalid(key+".window", "must be positive when the threshold is set but is %s", spike.Window)
+		}
+	}
+
+	if !audit.ValidSink(c.Audit.Sink) {
+		invalid("audit.sink", "must be %s or %s but is %q", audit.SinkJSONLines, audit.SinkSQLite, c.Audit.Sink)
+	}
+	if c.Audit.Retention < 0 {
+		invalid("audit.retention", "must not be negative but is %s", c.Audit.Retention)
+	}
+
+	if c.Admin.Address != "" {
+		if _, _, err := net.SplitHostPort(c.Admin.Address); err != nil {
+			invalid("admin.address", "must be host:port but is %q", c.Admin.Address)
+		}
+		if c.Admin.Token == "" {
+			invalid("admin.token", "is required when the admin API is enabled")
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w:\n%w", ErrInvalidConfig, errors.Join(errs...))
+	}
+	return nil
+}
+
+// Secrets returns the secrets in the configuration, so that they can be redacted from the logs.
+func (c *Config) Secrets() []string {
+	return []string{c.Token, c.Cache.Redis.Password, c.Log.Privacy.Salt, c.Report.SentryDSN, c.Report.Webhook, c.Alert.Webhook, c.Admin.Token}
+}
+
+// Redacted returns a copy of the configuration whose secrets are replaced, so that it can be shown.
+func (c Config) Redacted() Config {
+	if c.Token != "" {
+		c.Token = logging.Redacted
+	}
+	if c.Cache.Redis.Password != "" {
+		c.Cache.Redis.Password = logging.Redacted
+	}
+	for _, secret := range []*string{&c.Log.Privacy.Salt, &c.Report.SentryDSN, &c.Report.Webhook, &c.Alert.Webhook, &c.Admin.Token} {
+		if *secret != "" {
+			*secret = logging.Redacted
+		}
+	}
+	return c
+}
+
+// LevelOverrides returns the parsed overrides of the log levels. The configuration must have been validated.
+func (c LogConfig) LevelOverrides() map[string]zapcore.Level {
+	overrides, _ := logging.ParseLevelOverrides(c.Levels)
+	return overrides
+}
+
+// Profile returns the parsed format of the logs, or empty when it is not set. The configuration must have been validated.
+func (c LogConfig) Profile() logging.Profile {
+	profile, _ := logging.ParseProfile(c.Format)
+	return profile
+}
+
+// Policy returns the default privacy policy with the overrides of Fields. The configuration must have been validated.
+func (c PrivacyConfig) Policy() logging.PrivacyPolicy {
+	overrides, _ := logging.ParsePrivacyPolicy(c.Fields)
+	return logging.DefaultPrivacyPolicy().Merge(overrides)
+}
+
+// GatewayIntents returns the parsed intents. The configuration must have been validated.
+func (c *Config) GatewayIntents() discordgo.Intent {
+	intents, _ := discord.ParseIntents(c.Intents)
+	return intents
+}
+
+// Parse returns the parsed presence. The configuration must have been validated.
+func (c PresenceConfig) Parse() *discord.Presence {
+	presence, _ := discord.ParsePresence(c.Status, c.ActivityType, c.ActivityText)
+	return presence
+}
+
+// Limits returns the default limits of the rate limiter.
+func (c RateLimitConfig) Limits() ratelimit.Config {
+	return ratelimit.Config{
+		User:    c.User.limit(),
+		Channel: c.Channel.limit(),
+		Guild:   c.Guild.limit(),
+	}
+}
+
+// GuildLimits returns the limits of the guilds with an override, whose limits which are not set are the defaults.
+func (c RateLimitConfig) GuildLimits() map[string]ratelimit.Config {
+	limits := make(map[string]ratelimit.Config, len(c.Guilds))
+	for guildID, guild := range c.Guilds {
+		cfg := c.Limits()
+		if guild.User != nil {
+			cfg.User = guild.User.limit()
+		}
+		if guild.Channel != nil {
+			cfg.Channel = guild.Channel.limit()
+		}
+		if guild.Guild != nil {
+			cfg.Guild = guild.Guild.limit()
+		}
+		limits[guildID] = cfg
+	}
+	return limits
+}
+
+func (c LimitConfig) limit() ratelimit.Limit {
+	return ratelimit.Limit{Burst: c.Burst, Interval: c.Interval}
+}
+
+// CitationSettings returns the settings of the citation service.
+func (c *Config) CitationSettings() handler.CitationSettings {
+	return handler.CitationSettings{
+		SuppressedReaction: c.RateLimit.Reaction,
+		StateFallback:      c.StateFallback,
+		EmbedColor:         c.Embed.Color,
+		MentionAuthor:      c.Embed.MentionAuthor,
+		DemoteMessageLogs:  c.Log.DemoteMessages,
+	}
+}
+
+// EvictionPolicy returns the parsed eviction policy. The configuration must have been validated.
+func (c CacheConfig) EvictionPolicy() cache.Policy {
+	policy, _ := cache.ParsePolicy(c.Policy)
+	return policy
+}
+
+// EntryCodec returns the parsed codec. The configuration must have been validated.
+func (c CacheConfig) EntryCodec() cache.Codec {
+	codec, _ := cache.ParseCodec(c.Codec)
+	return codec
+}
@@ -0,0 +1,286 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aqyuki/felm/pkg/ratelimit"
+	"github.com/aqyuki/felm/pkg/secret"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// newViper returns a viper reading the flags defined by AddFlags, the environment variables and the config file if any.
+func newViper(t *testing.T, file string, flag map[string]string) *viper.Viper {
+	t.Helper()
+
+	flags := pflag.NewFlagSet("felm", pflag.ContinueOnError)
+	AddFlags(flags)
+	for name, value := range flag {
+		if err := flags.Set(name, value); err != nil {
+			t.Fatalf("failed to set the flag %s: %v", name, err)
+		}
+	}
+	v := viper.New()
+	if err := Bind(v, flags); err != nil {
+		t.Fatalf("failed to bind the flags: %v", err)
+	}
+	if file != "" {
+		path := filepath.Join(t.TempDir(), "felm.yaml")
+		if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+			t.Fatalf("failed to write the config file: %v", err)
+		}
+		v.SetConfigFile(path)
+		if err := v.ReadInConfig(); err != nil {
+			t.Fatalf("failed to read the config file: %v", err)
+		}
+	}
+	return v
+}
+
+// validConfig returns the defaults of the flags with a token, which is a valid configuration.
+func validConfig(t *testing.T) *Config {
+	t.Helper()
+
+	cfg, err := LoadConfig(context.Background(), newViper(t, "", map[string]string{"token": "token"}), secret.NewResolver())
+	if err != nil {
+		t.Fatalf("expected the defaults to be valid but received %v", err)
+	}
+	return cfg
+}
+
+// TestLoadConfigPrecedence sets the environment variables, so it can not run in parallel.
+func TestLoadConfigPrecedence(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		flag map[string]string
+		want func(cfg *Config) error
+	}{
+		{
+			name: "defaults",
+			want: func(cfg *Config) error {
+				if cfg.Shards != 1 || cfg.RateLimit.User.Burst != 5 || cfg.Timeout != 5*time.Second {
+					return fmt.Errorf("expected the defaults of the flags but received shards = %d, burst = %d and timeout = %s",
+						cfg.Shards, cfg.RateLimit.User.Burst, cfg.Timeout)
+				}
+				return nil
+			},
+		},
+		{
+			name: "file overrides the defaults",
+			file: "shards: 2\nratelimit:\n  user:\n    burst: 7\n",
+			want: func(cfg *Config) error {
+				if cfg.Shards != 2 || cfg.RateLimit.User.Burst != 7 {
+					return fmt.Errorf("expected shards = 2 and burst = 7 but received %d and %d", cfg.Shards, cfg.RateLimit.User.Burst)
+				}
+				return nil
+			},
+		},
+		{
+			name: "environment variables override the file",
+			file: "shards: 2\nratelimit:\n  user:\n    burst: 7\n",
+			env:  map[string]string{"FELM_SHARDS": "3", "FELM_RATELIMIT_USER_BURST": "8"},
+			want: func(cfg *Config) error {
+				if cfg.Shards != 3 || cfg.RateLimit.User.Burst != 8 {
+					return fmt.Errorf("expected shards = 3 and burst = 8 but received %d and %d", cfg.Shards, cfg.RateLimit.User.Burst)
+				}
+				return nil
+			},
+		},
+		{
+			name: "flags override the environment variables",
+			file: "shards: 2\nratelimit:\n  user:\n    burst: 7\n",
+			env:  map[string]string{"FELM_SHARDS": "3", "FELM_RATELIMIT_USER_BURST": "8"},
+			flag: map[string]string{"shards": "4", "ratelimit_user_burst": "9"},
+			want: func(cfg *Config) error {
+				if cfg.Shards != 4 || cfg.RateLimit.User.Burst != 9 {
+					return fmt.Errorf("expected shards = 4 and burst = 9 but received %d and %d", cfg.Shards, cfg.RateLimit.User.Burst)
+				}
+				return nil
+			},
+		},
+		{
+			name: "unset keys keep the lower sources",
+			file: "shards: 2\nembed:\n  color: 0x123456\n",
+			env:  map[string]string{"FELM_RATELIMIT_USER_BURST": "8"},
+			flag: map[string]string{"shards": "4"},
+			want: func(cfg *Config) error {
+				if cfg.Shards != 4 || cfg.RateLimit.User.Burst != 8 || cfg.Embed.Color != 0x123456 {
+					return fmt.Errorf("expected shards = 4, burst = 8 and color = 0x123456 but received %d, %d and %#x",
+						cfg.Shards, cfg.RateLimit.User.Burst, cfg.Embed.Color)
+				}
+				return nil
+			},
+		},
+		{
+			name: "guilds override the limits in the file",
+			file: "ratelimit:\n  guilds:\n    \"123\":\n      user:\n        burst: 1\n        interval: 1m\n",
+			env:  map[string]string{"FELM_RATELIMIT_CHANNEL_BURST": "8"},
+			want: func(cfg *Config) error {
+				want := ratelimit.Config{
+					User:    ratelimit.Limit{Burst: 1, Interval: time.Minute},
+					Channel: ratelimit.Limit{Burst: 8, Interval: 5 * time.Second},
+					Guild:   ratelimit.Limit{Burst: 30, Interval: 2 * time.Second},
+				}
+				if limits := cfg.RateLimit.GuildLimits(); len(limits) != 1 || limits["123"] != want {
+					return fmt.Errorf("expected the limits of the guild to be %+v but received %+v", want, limits)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("FELM_TOKEN", "token")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			cfg, err := LoadConfig(context.Background(), newViper(t, tt.file, tt.flag), secret.NewResolver())
+			if err != nil {
+				t.Fatalf("expected no error but received %v", err)
+			}
+			if err := tt.want(cfg); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		key    string
+		change func(cfg *Config)
+	}{
+		{"token", func(cfg *Config) { cfg.Token = "" }},
+		{"log.level", func(cfg *Config) { cfg.Log.Level = "verbose" }},
+		{"log.levels", func(cfg *Config) { cfg.Log.Levels = "discord" }},
+		{"log.format", func(cfg *Config) { cfg.Log.Format = "xml" }},
+		{"log.file.path", func(cfg *Config) { cfg.Log.Output = LogOutputFile }},
+		{"log.output", func(cfg *Config) { cfg.Log.Output = "syslog" }},
+		{"log.file.max_size", func(cfg *Config) { cfg.Log.File.MaxSize = -1 }},
+		{"log.file.max_age", func(cfg *Config) { cfg.Log.File.MaxAge = -time.Hour }},
+		{"log.file.max_backups", func(cfg *Config) { cfg.Log.File.MaxBackups = -1 }},
+		{"log.sampling.initial", func(cfg *Config) { cfg.Log.Sampling.Initial = -1 }},
+		{"log.sampling.thereafter", func(cfg *Config) { cfg.Log.Sampling.Thereafter = -1 }},
+		{"log.summary_interval", func(cfg *Config) { cfg.Log.SummaryInterval = -time.Minute }},
+		{"log.privacy.fields", func(cfg *Config) { cfg.Log.Privacy.Fields = "content=shred" }},
+		{"timeout", func(cfg *Config) { cfg.Timeout = 0 }},
+		{"shutdown_timeout", func(cfg *Config) { cfg.ShutdownTimeout = -time.Second }},
+		{"shards", func(cfg *Config) { cfg.Shards = -1 }},
+		{"intents", func(cfg *Config) { cfg.Intents = "unknown_intent" }},
+		{"max_disconnection", func(cfg *Config) { cfg.MaxDisconnection = -time.Second }},
+		{"presence", func(cfg *Config) { cfg.Presence.Status = "away" }},
+		{"ratelimit.user.burst", func(cfg *Config) { cfg.RateLimit.User.Burst = -1 }},
+		{"ratelimit.user.interval", func(cfg *Config) { cfg.RateLimit.User.Interval = 0 }},
+		{"ratelimit.channel.burst", func(cfg *Config) { cfg.RateLimit.Channel.Burst = -1 }},
+		{"ratelimit.channel.interval", func(cfg *Config) { cfg.RateLimit.Channel.Interval = 0 }},
+		{"ratelimit.guild.burst", func(cfg *Config) { cfg.RateLimit.Guild.Burst = -1 }},
+		{"ratelimit.guild.interval", func(cfg *Config) { cfg.RateLimit.Guild.Interval = 0 }},
+		{"ratelimit.guilds.123.user.burst", func(cfg *Config) {
+			cfg.RateLimit.Guilds = map[string]GuildLimitConfig{"123": {User: &LimitConfig{Burst: -1}}}
+		}},
+		{"ratelimit.guilds.123.guild.interval", func(cfg *Config) {
+			cfg.RateLimit.Guilds = map[string]GuildLimitConfig{"123": {Guild: &LimitConfig{Burst: 1}}}
+		}},
+		{"ratelimit.guilds.general", func(cfg *Config) {
+			cfg.RateLimit.Guilds = map[string]GuildLimitConfig{"general": {}}
+		}},
+		{"embed.color", func(cfg *Config) { cfg.Embed.Color = 0x1000000 }},
+		{"cache.capacity", func(cfg *Config) { cfg.Cache.Capacity = -1 }},
+		{"cache.policy", func(cfg *Config) { cfg.Cache.Policy = "fifo" }},
+		{"cache.codec", func(cfg *Config) { cfg.Cache.Codec = "xml" }},
+		{"cache.redis.db", func(cfg *Config) { cfg.Cache.Redis.DB = -1 }},
+		{"report.sentry_dsn", func(cfg *Config) { cfg.Report.SentryDSN = "not a dsn" }},
+		{"report.webhook", func(cfg *Config) { cfg.Report.Webhook = "http://discord.com/api/webhooks/1/a" }},
+		{"report.dedupe_window", func(cfg *Config) { cfg.Report.DedupeWindow = -time.Minute }},
+		{"report.burst", func(cfg *Config) { cfg.Report.Burst = -1 }},
+		{"report.interval", func(cfg *Config) { cfg.Report.Interval = 0 }},
+		{"alert.webhook", func(cfg *Config) { cfg.Alert.Webhook = "discord.com/api/webhooks/1/a" }},
+		{"alert.cooldown", func(cfg *Config) { cfg.Alert.Cooldown = -time.Minute }},
+		{"alert.errors.threshold", func(cfg *Config) { cfg.Alert.Errors.Threshold = -1 }},
+		{"alert.errors.window", func(cfg *Config) { cfg.Alert.Errors.Window = 0 }},
+		{"alert.disconnects.threshold", func(cfg *Config) { cfg.Alert.Disconnects.Threshold = -1 }},
+		{"alert.disconnects.window", func(cfg *Config) { cfg.Alert.Disconnects.Window = 0 }},
+		{"alert.ratelimits.threshold", func(cfg *Config) { cfg.Alert.RateLimits.Threshold = -1 }},
+		{"alert.ratelimits.window", func(cfg *Config) { cfg.Alert.RateLimits.Window = 0 }},
+		{"alert.forbidden.threshold", func(cfg *Config) { cfg.Alert.Forbidden.Threshold = -1 }},
+		{"alert.forbidden.window", func(cfg *Config) { cfg.Alert.Forbidden.Window = 0 }},
+		{"audit.sink", func(cfg *Config) { cfg.Audit.Sink = "csv" }},
+		{"audit.retention", func(cfg *Config) { cfg.Audit.Retention = -time.Hour }},
+		{"admin.address", func(cfg *Config) {
+			cfg.Admin.Address = "8081"
+			cfg.Admin.Token = "admin-token"
+		}},
+		{"admin.token", func(cfg *Config) { cfg.Admin.Address = "127.0.0.1:8081" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := validConfig(t)
+			tt.change(cfg)
+			err := cfg.Validate()
+			if !errors.Is(err, ErrInvalidConfig) {
+				t.Fatalf("expected err to be %v but received %v", ErrInvalidConfig, err)
+			}
+			// every problem is reported on its own line, starting with its key.
+			lines := strings.Split(err.Error(), "\n")[1:]
+			if len(lines) != 1 || !strings.HasPrefix(lines[0], tt.key+": ") {
+				t.Errorf("expected only %s to be reported but received %q", tt.key, lines)
+			}
+		})
+	}
+}
+
+func TestConfigRedacted(t *testing.T) {
+	t.Parallel()
+
+	cfg := validConfig(t)
+	cfg.Token = "secret-token"
+	cfg.Cache.Redis.Password = "secret-password"
+	cfg.Log.Privacy.Salt = "secret-salt"
+	cfg.Report.SentryDSN = "https://secret-key@sentry.example.com/1"
+	cfg.Report.Webhook = "https://discord.com/api/webhooks/1/secret-report-webhook"
+	cfg.Alert.Webhook = "https://discord.com/api/webhooks/2/secret-alert-webhook"
+	cfg.Admin.Token = "secret-admin-token"
+
+	secrets := cfg.Secrets()
+	for _, s := range secrets {
+		if s == "" {
+			t.Fatalf("expected every secret to be set but received %q", secrets)
+		}
+	}
+
+	redacted := cfg.Redacted()
+	shown := fmt.Sprintf("%+v", redacted)
+	for _, s := range secrets {
+		if strings.Contains(shown, s) {
+			t.Errorf("expected %q to be redacted but received %s", s, shown)
+		}
+	}
+	if cfg.Token != "secret-token" {
+		t.Errorf("expected the configuration to be kept but the token is %q", cfg.Token)
+	}
+
+	// secrets which are not set are shown as empty, so that they can be told apart from the redacted ones.
+	empty := *validConfig(t)
+	empty.Token = ""
+	empty = empty.Redacted()
+	for _, s := range empty.Secrets() {
+		if s != "" {
+			t.Errorf("expected the secrets which are not set to be empty but received %q", empty.Secrets())
+			break
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aqyuki/felm/internal/app/handler"
+	"github.com/aqyuki/felm/pkg/alert"
+	"github.com/aqyuki/felm/pkg/audit"
+	"github.com/aqyuki/felm/pkg/report"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// flagKeys maps the flags to the keys of the configuration.
+// The flags keep the flat names documented in the README, while the keys are nested to be grouped in the config file.
+var flagKeys = []struct{ flag, key string }{
+	{"config", "config"},
+	{"token", "token"},
+	{"token_file", "token_file"},
+	{"log_level", "log.level"},
+	{"log_levels", "log.levels"},
+	{"log_format", "log.format"},
+	{"log_trace_project", "log.trace_project"},
+	{"log_output", "log.output"},
+	{"log_file_path", "log.file.path"},
+	{"log_file_max_size", "log.file.max_size"},
+	{"log_file_max_age", "log.file.max_age"},
+	{"log_file_max_backups", "log.file.max_backups"},
+	{"log_sampling_initial", "log.sampling.initial"},
+	{"log_sampling_thereafter", "log.sampling.thereafter"},
+	{"log_demote_messages", "log.demote_messages"},
+	{"log_summary_interval", "log.summary_interval"},
+	{"log_privacy", "log.privacy.enabled"},
+	{"log_privacy_fields", "log.privacy.fields"},
+	{"log_privacy_salt", "log.privacy.salt"},
+	{"timeout", "timeout"},
+	{"shutdown_timeout", "shutdown_timeout"},
+	{"shards", "shards"},
+	{"intents", "intents"},
+	{"max_disconnection", "max_disconnection"},
+	{"presence_status", "presence.status"},
+	{"presence_activity_type", "presence.activity_type"},
+	{"presence_activity_text", "presence.activity_text"},
+	{"presence_interval", "presence.interval"},
+	{"ratelimit_user_burst", "ratelimit.user.burst"},
+	{"ratelimit_user_interval", "ratelimit.user.interval"},
+	{"ratelimit_channel_burst", "ratelimit.channel.burst"},
+	{"ratelimit_channel_interval", "ratelimit.channel.interval"},
+	{"ratelimit_guild_burst", "ratelimit.guild.burst"},
+	{"ratelimit_guild_interval", "ratelimit.guild.interval"},
+	{"ratelimit_reaction", "ratelimit.reaction"},
+	{"embed_color", "embed.color"},
+	{"embed_mention_author", "embed.mention_author"},
+	{"report_sentry_dsn", "report.sentry_dsn"},
+	{"report_webhook", "report.webhook"},
+	{"report_channel_id", "report.channel_id"},
+	{"report_dedupe_window", "report.dedupe_window"},
+	{"report_burst", "report.burst"},
+	{"report_interval", "report.interval"},
+	{"alert_webhook", "alert.webhook"},
+	{"alert_channel_id", "alert.channel_id"},
+	{"alert_cooldown", "alert.cooldown"},
+	{"alert_lifecycle", "alert.lifecycle"},
+	{"alert_errors_threshold", "alert.errors.threshold"},
+	{"alert_errors_window", "alert.errors.window"},
+	{"alert_disconnects_threshold", "alert.disconnects.threshold"},
+	{"alert_disconnects_window", "alert.disconnects.window"},
+	{"alert_ratelimits_threshold", "alert.ratelimits.threshold"},
+	{"alert_ratelimits_window", "alert.ratelimits.window"},
+	{"alert_forbidden_threshold", "alert.forbidden.threshold"},
+	{"alert_forbidden_window", "alert.forbidden.window"},
+	{"admin_address", "admin.address"},
+	{"admin_token", "admin.token"},
+	{"audit_sink", "audit.sink"},
+	{"audit_path", "audit.path"},
+	{"audit_retention", "audit.retention"},
+	{"audit_command", "audit.command"},
+	{"preflight", "preflight"},
+	{"state_fallback", "state_fallback"},
+	{"cache_capacity", "cache.capacity"},
+	{"cache_policy", "cache.policy"},
+	{"cache_codec", "cache.codec"},
+	{"cache_snapshot_path", "cache.snapshot_path"},
+	{"cache_redis_addr", "cache.redis.addr"},
+	{"cache_redis_password", "cache.redis.password"},
+	{"cache_redis_db", "cache.redis.db"},
+}
+
+// AddFlags defines the flags of every key of the configuration with their defaults.
+func AddFlags(flags *pflag.FlagSet) {
+	flags.String("config", "", "config is a path of the config file (yaml, toml or json). It or FELM_CONFIG is optional.")
+	flags.String("token", "", "token is a Discord bot token, or a reference to it such as file:///run/secrets/token or env://NAME. It, FELM_TOKEN or token_file is required.")
+	flags.String("token_file", "", "token_file is a file containing the Discord bot token, e.g. a Docker secret. It or FELM_TOKEN_FILE is optional.")
+	flags.String("log_level", "", "log_level is a minimum level of the logs (debug, info, warning, error, critical, alert or emergency). Empty keeps LOG_LEVEL. It or FELM_LOG_LEVEL is optional.")
+	flags.String("log_levels", "", "log_levels overrides the level of the loggers of subsystems (discord, handler.citation or cache), e.g. discord=debug,cache=warning. Empty keeps LOG_LEVELS. It or FELM_LOG_LEVELS is optional.")
+	flags.String("log_format", "", "log_format is a format of the logs (gcp, ecs, logfmt or console). Empty chooses console when LOG_MODE is develop and gcp otherwise. It or FELM_LOG_FORMAT is optional.")
+	flags.String("log_trace_project", "", "log_trace_project is a Google Cloud project linked to the trace IDs in the gcp format. It or FELM_LOG_TRACE_PROJECT is optional.")
+	flags.String("log_output", LogOutputStderr, "log_output is where the logs are written (stderr, file or both). It or FELM_LOG_OUTPUT is optional.")
+	flags.String("log_file_path", "", "log_file_path is a path of the log file, required when log_output is file or both. It or FELM_LOG_FILE_PATH is optional.")
+	flags.Int("log_file_max_size", 100, "log_file_max_size is a size in megabytes at which the log file is rotated. It or FELM_LOG_FILE_MAX_SIZE is optional.")
+	flags.Duration("log_file_max_age", 0, "log_file_max_age is a duration to keep rotated log files. 0 keeps them regardless of their age. It or FELM_LOG_FILE_MAX_AGE is optional.")
+	flags.Int("log_file_max_backups", 0, "log_file_max_backups is a number of rotated log files to keep. 0 keeps every file. It or FELM_LOG_FILE_MAX_BACKUPS is optional.")
+	flags.Int("log_sampling_initial", 0, "log_sampling_initial is a number of logs with the same level and message written every second before sampling. 0 disables sampling. It or FELM_LOG_SAMPLING_INITIAL is optional.")
+	flags.Int("log_sampling_thereafter", 100, "log_sampling_thereafter writes every n-th log after log_sampling_initial. It or FELM_LOG_SAMPLING_THEREAFTER is optional.")
+	flags.Bool("log_demote_messages", false, "log_demote_messages logs the events of every message at debug instead of info. It or FELM_LOG_DEMOTE_MESSAGES is optional.")
+	flags.Duration("log_summary_interval", 0, "log_summary_interval is a duration to log the numbers of handled messages. 0 disables the summary. It or FELM_LOG_SUMMARY_INTERVAL is optional.")
+	flags.Bool("log_privacy", false, "log_privacy hashes the user IDs and drops the usernames and the message contents in the logs. It or FELM_LOG_PRIVACY_ENABLED is optional.")
+	flags.String("log_privacy_fields", "", "log_privacy_fields overrides the actions (keep, hash or drop) on the fields of the logs, e.g. author.id=drop,content=keep. It or FELM_LOG_PRIVACY_FIELDS is optional.")
+	flags.String("log_privacy_salt", "", "log_privacy_salt is a secret key of the hashes of the user IDs, or a reference to it. It or FELM_LOG_PRIVACY_SALT is optional.")
+	flags.Duration("timeout", 5*time.Second, "timeout is a duration for HTTP client timeout. It or FELM_TIMEOUT is optional.")
+	flags.Duration("shutdown_timeout", 10*time.Second, "shutdown_timeout is a duration to wait for running handlers on shutdown. It or FELM_SHUTDOWN_TIMEOUT is optional.")
+	flags.Int("shards", 1, "shards is a number of gateway shards. 0 uses the number recommended by Discord. It or FELM_SHARDS is optional.")
+	flags.Duration("max_disconnection", 0, "max_disconnection is a duration a shard can stay disconnected before felm exits to be restarted. 0 keeps reconnecting forever. It or FELM_MAX_DISCONNECTION is optional.")
+	flags.String("intents", "message_content", "intents is a comma separated list of gateway intents requested in addition to the ones the handlers require. It or FELM_INTENTS is optional.")
+	flags.String("presence_status", "online", "presence_status is a status of the bot (online, idle, dnd or invisible). It or FELM_PRESENCE_STATUS is optional.")
+	flags.String("presence_activity_type", "watching", "presence_activity_type is a type of the activity (playing, streaming, listening, watching, custom or competing). It or FELM_PRESENCE_ACTIVITY_TYPE is optional.")
+	flags.String("presence_activity_text", "message links in {{.Guilds}} servers", "presence_activity_text is a text of the activity. {{.Guilds}}, {{.Shards}} and {{.CitationsToday}} are replaced. Empty shows no activity. It or FELM_PRESENCE_ACTIVITY_TEXT is optional.")
+	flags.Duration("presence_interval", 10*time.Minute, "presence_interval is a duration to refresh the activity text. It or FELM_PRESENCE_INTERVAL is optional.")
+	flags.Int("ratelimit_user_burst", 5, "ratelimit_user_burst is a number of citations a user can request at once. 0 disables the limit. It or FELM_RATELIMIT_USER_BURST is optional.")
+	flags.Duration("ratelimit_user_interval", 10*time.Second, "ratelimit_user_interval is a duration for a user to regain a citation. It or FELM_RATELIMIT_USER_INTERVAL is optional.")
+	flags.Int("ratelimit_channel_burst", 10, "ratelimit_channel_burst is a number of citations a channel can request at once. 0 disables the limit. It or FELM_RATELIMIT_CHANNEL_BURST is optional.")
+	flags.Duration("ratelimit_channel_interval", 5*time.Second, "ratelimit_channel_interval is a duration for a channel to regain a citation. It or FELM_RATELIMIT_CHANNEL_INTERVAL is optional.")
+	flags.Int("ratelimit_guild_burst", 30, "ratelimit_guild_burst is a number of citations a guild can request at once. 0 disables the limit. It or FELM_RATELIMIT_GUILD_BURST is optional.")
+	flags.Duration("ratelimit_guild_interval", 2*time.Second, "ratelimit_guild_interval is a duration for a guild to regain a citation. It or FELM_RATELIMIT_GUILD_INTERVAL is optional.")
+	flags.String("ratelimit_reaction", "", "ratelimit_reaction is an emoji added to rate limited messages. Empty disables the reaction. It or FELM_RATELIMIT_REACTION is optional.")
+
+	flags.Int("embed_color", handler.DefaultEmbedColor, "embed_color is a color of the embeds as an RGB integer. It or FELM_EMBED_COLOR is optional.")
+	flags.Bool("embed_mention_author", true, "embed_mention_author mentions the author of the message containing the link in the reply. It or FELM_EMBED_MENTION_AUTHOR is optional.")
+
+	flags.String("report_sentry_dsn", "", "report_sentry_dsn is a DSN of Sentry or a compatible server to report the errors of the handlers to, or a reference to it. It or FELM_REPORT_SENTRY_DSN is optional.")
+	flags.String("report_webhook", "", "report_webhook is a URL of a Discord webhook to report the errors of the handlers to, or a reference to it. It or FELM_REPORT_WEBHOOK is optional.")
+	flags.String("report_channel_id", "", "report_channel_id is a channel the bot reports the errors of the handlers to. It or FELM_REPORT_CHANNEL_ID is optional.")
+	flags.Duration("report_dedupe_window", report.DefaultDedupeWindow, "report_dedupe_window is a duration during which the same errors are reported once. 0 reports every error. It or FELM_REPORT_DEDUPE_WINDOW is optional.")
+	flags.Int("report_burst", report.DefaultBurst, "report_burst is a number of errors which can be reported at once. 0 disables the limit. It or FELM_REPORT_BURST is optional.")
+	flags.Duration("report_interval", report.DefaultInterval, "report_interval is a duration to regain a report. It or FELM_REPORT_INTERVAL is optional.")
+	flags.String("alert_webhook", "", "alert_webhook is a URL of a Discord webhook to send the alerts of the bot health to, or a reference to it. It or FELM_ALERT_WEBHOOK is optional.")
+	flags.String("alert_channel_id", "", "alert_channel_id is a channel the bot sends the alerts of its health to. It or FELM_ALERT_CHANNEL_ID is optional.")
+	flags.Duration("alert_cooldown", alert.DefaultCooldown, "alert_cooldown is a duration during which the same alert is sent once. It or FELM_ALERT_COOLDOWN is optional.")
+	flags.Bool("alert_lifecycle", true, "alert_lifecycle sends the alerts when the bot starts and stops. It or FELM_ALERT_LIFECYCLE is optional.")
+	flags.Int("alert_errors_threshold", 10, "alert_errors_threshold is a number of handler errors within alert_errors_window to send an alert. 0 disables the alert. It or FELM_ALERT_ERRORS_THRESHOLD is optional.")
+	flags.Duration("alert_errors_window", 5*time.Minute, "alert_errors_window is a duration in which the handler errors are counted. It or FELM_ALERT_ERRORS_WINDOW is optional.")
+	flags.Int("alert_disconnects_threshold", 5, "alert_disconnects_threshold is a number of gateway disconnections within alert_disconnects_window to send an alert. 0 disables the alert. It or FELM_ALERT_DISCONNECTS_THRESHOLD is optional.")
+	flags.Duration("alert_disconnects_window", 10*time.Minute, "alert_disconnects_window is a duration in which the gateway disconnections are counted. It or FELM_ALERT_DISCONNECTS_WINDOW is optional.")
+	flags.Int("alert_ratelimits_threshold", 20, "alert_ratelimits_threshold is a number of rate limited requests within alert_ratelimits_window to send an alert. 0 disables the alert. It or FELM_ALERT_RATELIMITS_THRESHOLD is optional.")
+	flags.Duration("alert_ratelimits_window", time.Minute, "alert_ratelimits_window is a duration in which the rate limited requests are counted. It or FELM_ALERT_RATELIMITS_WINDOW is optional.")
+	flags.Int("alert_forbidden_threshold", 5, "alert_forbidden_threshold is a number of replies denied in a guild within alert_forbidden_window to send an alert. 0 disables the alert. It or FELM_ALERT_FORBIDDEN_THRESHOLD is optional.")
+	flags.Duration("alert_forbidden_window", 10*time.Minute, "alert_forbidden_window is a duration in which the denied replies are counted. It or FELM_ALERT_FORBIDDEN_WINDOW is optional.")
+	flags.String("admin_address", "", "admin_address is an address of the admin HTTP API, e.g. 127.0.0.1:8081. Empty disables it. It or FELM_ADMIN_ADDRESS is optional.")
+	flags.String("admin_token", "", "admin_token is a bearer token required by the admin HTTP API, or a reference to it. It or FELM_ADMIN_TOKEN is required when admin_address is set.")
+	flags.String("audit_sink", audit.SinkJSONLines, "audit_sink is the format of the audit log, jsonl or sqlite. It or FELM_AUDIT_SINK is optional.")
+	flags.String("audit_path", "", "audit_path is a JSON lines file or a SQLite database to record every message link to. Empty disables the audit log. It or FELM_AUDIT_PATH is optional.")
+	flags.Duration("audit_retention", audit.DefaultRetention, "audit_retention is a duration to keep the audit records. 0 keeps them forever. It or FELM_AUDIT_RETENTION is optional.")
+	flags.Bool("audit_command", true, "audit_command registers /felm audit for the admins of the servers to show the audit records. It or FELM_AUDIT_COMMAND is optional.")
+
+	flags.Bool("preflight", false, "preflight checks the token, the intents and the permissions of the bot before connecting. It or FELM_PREFLIGHT is optional.")
+	flags.Bool("state_fallback", true, "state_fallback enables looking up channels in the gateway state before calling the REST API. It or FELM_STATE_FALLBACK is optional.")
+
+	flags.Int("cache_capacity", 10000, "cache_capacity is a maximum number of cached channels and messages each. 0 means unbounded. It or FELM_CACHE_CAPACITY is optional.")
+	flags.String("cache_policy", "lru", "cache_policy is an eviction policy of the cache (lru, lfu or ttl). It or FELM_CACHE_POLICY is optional.")
+	flags.String("cache_codec", "json", "cache_codec is a codec of entries stored in Redis (json or gob). It or FELM_CACHE_CODEC is optional.")
+	flags.String("cache_snapshot_path", "", "cache_snapshot_path is a file to save the cache on shutdown and restore it on startup. Empty disables it. It or FELM_CACHE_SNAPSHOT_PATH is optional.")
+	flags.String("cache_redis_addr", "", "cache_redis_addr is an address of Redis shared by the caches. Empty keeps the caches in memory. It or FELM_CACHE_REDIS_ADDR is optional.")
+	flags.String("cache_redis_password", "", "cache_redis_password is a password of Redis. It or FELM_CACHE_REDIS_PASSWORD is optional.")
+	flags.Int("cache_redis_db", 0, "cache_redis_db is a database number of Redis. It or FELM_CACHE_REDIS_DB is optional.")
+}
+
+// Bind makes v read the flags defined by AddFlags and the environment variables derived from the keys,
+// e.g. ratelimit.user.burst is read from FELM_RATELIMIT_USER_BURST.
+func Bind(v *viper.Viper, flags *pflag.FlagSet) error {
+	for _, binding := range flagKeys {
+		if err := v.BindPFlag(binding.key, flags.Lookup(binding.flag)); err != nil {
+			return fmt.Errorf("failed to bind the flag (name = %s): %w", binding.flag, err)
+		}
+	}
+
+	v.SetEnvPrefix("felm")
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	return nil
+}
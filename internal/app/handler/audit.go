@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/aqyuki/felm/pkg/audit"
+	"github.com/aqyuki/felm/pkg/logging"
+	"github.com/aqyuki/felm/pkg/trace"
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+// The reasons of the skipped and failed citations in the audit log.
+const (
+	reasonCrossGuild          = "cross_guild"
+	reasonRateLimited         = "rate_limited"
+	reasonChannelInaccessible = "source_channel_inaccessible"
+	reasonNSFW                = "nsfw"
+	reasonMessageInaccessible = "source_message_inaccessible"
+	reasonNoContent           = "no_content"
+	reasonReplyForbidden      = "reply_forbidden"
+	reasonError               = "error"
+)
+
+// newAuditRecord creates the audit record of the message link in the message, without a decision.
+func newAuditRecord(ctx context.Context, message *discordgo.Message, link *messageLink) audit.Record {
+	return audit.Record{
+		Time:            time.Now(),
+		TraceID:         trace.AcquireTraceID(ctx),
+		PosterID:        message.Author.ID,
+		GuildID:         message.GuildID,
+		ChannelID:       message.ChannelID,
+		MessageID:       message.ID,
+		SourceGuildID:   link.guildID,
+		SourceChannelID: link.channelID,
+		SourceMessageID: link.messageID,
+	}
+}
+
+// audit writes the record to the audit sink. A record which can not be written is logged, so that the citation is not affected.
+func (srv *CitationService) audit(ctx context.Context, record audit.Record) {
+	if srv.auditSink == nil {
+		return
+	}
+	if err := srv.auditSink.Write(ctx, record); err != nil {
+		logging.Named(ctx, citationLoggerName).Warn("failed to write the audit record",
+			zap.String("message_id", record.MessageID),
+			zap.String("decision", string(record.Decision)),
+			zap.Error(err))
+	}
+}
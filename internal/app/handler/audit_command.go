@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aqyuki/felm/pkg/audit"
+	"github.com/aqyuki/felm/pkg/discord"
+	"github.com/aqyuki/felm/pkg/trace"
+	"github.com/bwmarrin/discordgo"
+	"github.com/samber/oops"
+)
+
+const (
+	// commandName is the name of the slash command of felm, whose subcommands are the features.
+	commandName = "felm"
+
+	auditSubcommandName = "audit"
+
+	// maxAuditRecords is the maximum number of records shown at once, which fit in an embed.
+	maxAuditRecords = 25
+
+	// maxAuditDescriptionLength is the length of the list of records, which Discord limits to 4096.
+	maxAuditDescriptionLength = 4000
+)
+
+var _ discord.EventHandler[*discordgo.InteractionCreate] = (*AuditCommand)(nil).On
+
+// AuditCommand is the `/felm audit` command which shows the latest audit records of the guild to its admins.
+type AuditCommand struct {
+	sink audit.Sink
+}
+
+// NewAuditCommand creates the command querying the sink.
+func NewAuditCommand(sink audit.Sink) *AuditCommand {
+	return &AuditCommand{sink: sink}
+}
+
+// ApplicationCommand returns the definition of the command to be registered to Discord.
+// Only the members who can manage the guild can use it, unless the guild changes it in the settings of the integration.
+func (c *AuditCommand) ApplicationCommand() *discordgo.ApplicationCommand {
+	permissions := int64(discordgo.PermissionManageGuild)
+	contexts := []discordgo.InteractionContextType{discordgo.InteractionContextGuild}
+	minLimit := float64(1)
+	return &discordgo.ApplicationCommand{
+		Name:                     commandName,
+		Description:              "Commands of felm",
+		DefaultMemberPermissions: &permissions,
+		Contexts:                 &contexts,
+		Options: []*discordgo.ApplicationCommandOption{{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        auditSubcommandName,
+			Description: "Show the latest message links expanded or skipped in this server",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Name:        "user",
+					Description: "Only the message links posted by the user",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionChannel,
+					Name:        "channel",
+					Description: "Only the message links posted in or pointing to the channel",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "limit",
+					Description: fmt.Sprintf("The number of records to show (default %d)", audit.DefaultQueryLimit),
+					MinValue:    &minLimit,
+					MaxValue:    maxAuditRecords,
+				},
+			},
+		}},
+	}
+}
+
+// On answers `/felm audit` with the latest records of the guild, which only the caller can see.
+// The callers who cannot manage the guild are rejected.
+func (c *AuditCommand) On(ctx context.Context, session *discordgo.Session, event *discordgo.InteractionCreate) error {
+	if event.Type != discordgo.InteractionApplicationCommand || event.GuildID == "" {
+		return nil
+	}
+	data := event.ApplicationCommandData()
+	if data.Name != commandName || len(data.Options) == 0 || data.Options[0].Name != auditSubcommandName {
+		return nil
+	}
+
+	// the default permissions of the command can be changed by the guild, so the caller is checked again.
+	if !canManageGuild(event.Member) {
+		if err := c.respond(ctx, session, event.Interaction, &discordgo.InteractionResponseData{
+			Content: "You need the Manage Server permission to use this command.",
+		}); err != nil {
+			return oops.
+				Trace(trace.AcquireTraceID(ctx)).
+				With("guild_id", event.GuildID, "channel_id", event.ChannelID).
+				Wrapf(err, "error occurred while rejecting the audit command (guild_id = %s)", event.GuildID)
+		}
+		return nil
+	}
+
+	// the records of other guilds are never shown, whatever the options are.
+	query := audit.Query{GuildID: event.GuildID}
+	for _, option := range data.Options[0].Options {
+		switch option.Name {
+		case "user":
+			query.PosterID = option.UserValue(nil).ID
+		case "channel":
+			query.ChannelID = option.ChannelValue(nil).ID
+		case "limit":
+			query.Limit = min(int(option.IntValue()), maxAuditRecords)
+		}
+	}
+
+	records, err := c.sink.Query(ctx, query)
+	if err != nil {
+		_ = c.respond(ctx, session, event.Interaction, &discordgo.InteractionResponseData{
+			Content: "The audit log could not be read. Please try again later.",
+		})
+		return oops.
+			Trace(trace.AcquireTraceID(ctx)).
+			With("guild_id", event.GuildID, "channel_id", event.ChannelID).
+			Wrapf(err, "error occurred while querying the audit log (guild_id = %s)", event.GuildID)
+	}
+
+	if err := c.respond(ctx, session, event.Interaction, &discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{newAuditEmbed(records)},
+	}); err != nil {
+		return oops.
+			Trace(trace.AcquireTraceID(ctx)).
+			With("guild_id", event.GuildID, "channel_id", event.ChannelID).
+			Wrapf(err, "error occurred while responding to the audit command (guild_id = %s)", event.GuildID)
+	}
+	return nil
+}
+
+// canManageGuild reports whether the member can manage the guild, which administrators always can.
+func canManageGuild(member *discordgo.Member) bool {
+	if member == nil {
+		return false
+	}
+	return member.Permissions&(discordgo.PermissionManageGuild|discordgo.PermissionAdministrator) != 0
+}
+
+// respond answers the interaction with a message only the caller can see.
+// It is not retried because an interaction can be answered only once.
+func (c *AuditCommand) respond(ctx context.Context, session *discordgo.Session, interaction *discordgo.Interaction, data *discordgo.InteractionResponseData) error {
+	data.Flags = discordgo.MessageFlagsEphemeral
+	data.AllowedMentions = &discordgo.MessageAllowedMentions{Parse: []discordgo.AllowedMentionType{}}
+	return session.InteractionRespond(interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: data,
+	}, discordgo.WithContext(ctx))
+}
+
+// newAuditEmbed creates the embed listing the records, the latest first.
+func newAuditEmbed(records []audit.Record) *discordgo.MessageEmbed {
+	embed := &discordgo.MessageEmbed{
+		Title: "Audit log",
+		Color: DefaultEmbedColor,
+	}
+	if len(records) == 0 {
+		embed.Description = "No message links were recorded."
+		return embed
+	}
+
+	var b strings.Builder
+	shown := 0
+	for _, record := range records {
+		line := auditLine(record)
+		if b.Len()+len(line) > maxAuditDescriptionLength {
+			break
+		}
+		b.WriteString(line)
+		shown++
+	}
+	embed.Description = b.String()
+	embed.Footer = &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("%d records", shown)}
+	return embed
+}
+
+// auditLine formats the record as a line of the embed, with the times shown in the time zone of the reader.
+func auditLine(record audit.Record) string {
+	line := fmt.Sprintf("<t:%d:f> **%s** <@%s> in <#%s>: [source](%s)",
+		record.Time.Unix(), record.Decision, record.PosterID, record.ChannelID,
+		messageURL(record.SourceGuildID, record.SourceChannelID, record.SourceMessageID))
+	if record.ReplyMessageID != "" {
+		line += fmt.Sprintf(" → [reply](%s)", messageURL(record.GuildID, record.ChannelID, record.ReplyMessageID))
+	}
+	if record.Reason != "" {
+		line += fmt.Sprintf(" (%s)", record.Reason)
+	}
+	return line + fmt.Sprintf(" `%s`\n", record.TraceID)
+}
+
+func messageURL(guildID, channelID, messageID string) string {
+	return fmt.Sprintf("https://discord.com/channels/%s/%s/%s", guildID, channelID, messageID)
+}
@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aqyuki/felm/pkg/audit"
+	"github.com/bwmarrin/discordgo"
+)
+
+// responseRecorder answers the requests to the Discord API with 204 and keeps the interaction responses sent.
+type responseRecorder struct {
+	mu        sync.Mutex
+	responses []discordgo.InteractionResponse
+}
+
+func (r *responseRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var response discordgo.InteractionResponse
+	if err := json.NewDecoder(req.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.responses = append(r.responses, response)
+	r.mu.Unlock()
+	return &http.Response{StatusCode: http.StatusNoContent, Header: make(http.Header), Body: http.NoBody, Request: req}, nil
+}
+
+// querySink keeps the queries of the command.
+type querySink struct {
+	*audit.Memory
+
+	mu      sync.Mutex
+	queries []audit.Query
+}
+
+func (s *querySink) Query(ctx context.Context, query audit.Query) ([]audit.Record, error) {
+	s.mu.Lock()
+	s.queries = append(s.queries, query)
+	s.mu.Unlock()
+	return s.Memory.Query(ctx, query)
+}
+
+// newAuditInteraction returns `/felm audit` called in the guild g1 by a member with the permissions.
+func newAuditInteraction(permissions int64, options ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		ID:        "interaction",
+		Token:     "token",
+		Type:      discordgo.InteractionApplicationCommand,
+		GuildID:   "g1",
+		ChannelID: "c1",
+		Member:    &discordgo.Member{User: &discordgo.User{ID: "caller"}, Permissions: permissions},
+		Data: discordgo.ApplicationCommandInteractionData{
+			Name: commandName,
+			Options: []*discordgo.ApplicationCommandInteractionDataOption{{
+				Type:    discordgo.ApplicationCommandOptionSubCommand,
+				Name:    auditSubcommandName,
+				Options: options,
+			}},
+		},
+	}}
+}
+
+func TestAuditCommandOn(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		permissions int64
+		options     []*discordgo.ApplicationCommandInteractionDataOption
+		// want is the query to the sink, or nil if the caller must be rejected.
+		want       *audit.Query
+		wantFooter string
+	}{
+		{
+			name:        "members who cannot manage the guild are rejected",
+			permissions: discordgo.PermissionSendMessages | discordgo.PermissionUseSlashCommands,
+		},
+		{
+			name:        "members who can manage the guild see the records of the guild",
+			permissions: discordgo.PermissionManageGuild,
+			want:        &audit.Query{GuildID: "g1"},
+			wantFooter:  "2 records",
+		},
+		{
+			name:        "administrators see the records of the guild",
+			permissions: discordgo.PermissionAdministrator,
+			want:        &audit.Query{GuildID: "g1"},
+			wantFooter:  "2 records",
+		},
+		{
+			name:        "options never select the records of other guilds",
+			permissions: discordgo.PermissionManageGuild,
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				{Type: discordgo.ApplicationCommandOptionUser, Name: "user", Value: "u1"},
+				{Type: discordgo.ApplicationCommandOptionChannel, Name: "channel", Value: "c2"},
+			},
+			want:       &audit.Query{GuildID: "g1", PosterID: "u1", ChannelID: "c2"},
+			wantFooter: "1 records",
+		},
+		{
+			name:        "limit is kept within the records fitting in an embed",
+			permissions: discordgo.PermissionManageGuild,
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				{Type: discordgo.ApplicationCommandOptionInteger, Name: "limit", Value: float64(100)},
+			},
+			want:       &audit.Query{GuildID: "g1", Limit: maxAuditRecords},
+			wantFooter: "2 records",
+		},
+		{
+			name:        "limit below the maximum is kept",
+			permissions: discordgo.PermissionManageGuild,
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				{Type: discordgo.ApplicationCommandOptionInteger, Name: "limit", Value: float64(1)},
+			},
+			want:       &audit.Query{GuildID: "g1", Limit: 1},
+			wantFooter: "1 records",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			sink := &querySink{Memory: audit.NewMemory()}
+			now := time.Now()
+			for i, record := range []audit.Record{
+				{GuildID: "g1", ChannelID: "c2", PosterID: "u1"},
+				{GuildID: "g2", ChannelID: "c2", PosterID: "u1"},
+				{GuildID: "g1", ChannelID: "c1", PosterID: "u2"},
+			} {
+				record.Time = now.Add(time.Duration(i) * time.Second)
+				if err := sink.Write(context.Background(), record); err != nil {
+					t.Fatalf("expected err to be nil but received %v", err)
+				}
+			}
+			recorder := &responseRecorder{}
+			session, _ := discordgo.New("Bot token")
+			session.Client = &http.Client{Transport: recorder}
+			session.MaxRestRetries = 0
+
+			if err := NewAuditCommand(sink).On(context.Background(), session, newAuditInteraction(tt.permissions, tt.options...)); err != nil {
+				t.Fatalf("expected err to be nil but received %v", err)
+			}
+
+			if len(recorder.responses) != 1 || recorder.responses[0].Data == nil {
+				t.Fatalf("expected a response but received %+v", recorder.responses)
+			}
+			data := recorder.responses[0].Data
+			if data.Flags&discordgo.MessageFlagsEphemeral == 0 {
+				t.Errorf("expected the response to be ephemeral but received flags %d", data.Flags)
+			}
+
+			if tt.want == nil {
+				if len(sink.queries) != 0 {
+					t.Errorf("expected the sink not to be queried but received %+v", sink.queries)
+				}
+				if len(data.Embeds) != 0 || !strings.Contains(data.Content, "Manage Server") {
+					t.Errorf("expected the caller to be rejected but received %+v", data)
+				}
+				return
+			}
+			if len(sink.queries) != 1 || sink.queries[0] != *tt.want {
+				t.Errorf("expected the query to be %+v but received %+v", *tt.want, sink.queries)
+			}
+			if len(data.Embeds) != 1 || data.Embeds[0].Footer == nil || data.Embeds[0].Footer.Text != tt.wantFooter {
+				t.Errorf("expected an embed with the footer %q but received %+v", tt.wantFooter, data.Embeds)
+			}
+		})
+	}
+}
+
+func TestNewAuditEmbed(t *testing.T) {
+	t.Parallel()
+
+	newRecords := func(n int) []audit.Record {
+		records := make([]audit.Record, 0, n)
+		for i := range n {
+			records = append(records, audit.Record{
+				TraceID:         fmt.Sprintf("trace-%d", i),
+				Time:            time.Unix(1700000000, 0),
+				GuildID:         "1",
+				ChannelID:       "2",
+				PosterID:        "3",
+				SourceGuildID:   "1",
+				SourceChannelID: "4",
+				SourceMessageID: fmt.Sprintf("%d", 1000+i),
+				Decision:        audit.DecisionSkipped,
+				Reason:          strings.Repeat("r", 100),
+			})
+		}
+		return records
+	}
+
+	t.Run("no records", func(t *testing.T) {
+		t.Parallel()
+
+		embed := newAuditEmbed(nil)
+		if embed.Description != "No message links were recorded." || embed.Footer != nil {
+			t.Errorf("expected the embed without records but received %+v", embed)
+		}
+	})
+
+	t.Run("records fitting in the embed", func(t *testing.T) {
+		t.Parallel()
+
+		embed := newAuditEmbed(newRecords(3))
+		if lines := strings.Count(embed.Description, "\n"); lines != 3 {
+			t.Errorf("expected 3 lines but received %d", lines)
+		}
+		if embed.Footer == nil || embed.Footer.Text != "3 records" {
+			t.Errorf("expected the footer to be %q but received %+v", "3 records", embed.Footer)
+		}
+	})
+
+	t.Run("records are truncated at the length limit", func(t *testing.T) {
+		t.Parallel()
+
+		records := newRecords(maxAuditRecords)
+		embed := newAuditEmbed(records)
+
+		if len(embed.Description) > maxAuditDescriptionLength {
+			t.Errorf("expected the description to be at most %d characters but received %d", maxAuditDescriptionLength, len(embed.Description))
+		}
+		// only whole lines are shown, and the next one would not fit.
+		shown := strings.Count(embed.Description, "\n")
+		if shown == 0 || shown >= len(records) || !strings.HasSuffix(embed.Description, "\n") {
+			t.Fatalf("expected the records to be truncated but received %d lines of %d", shown, len(records))
+		}
+		if len(embed.Description)+len(auditLine(records[shown])) <= maxAuditDescriptionLength {
+			t.Errorf("expected the record %d not to fit but the description has %d characters", shown, len(embed.Description))
+		}
+		if want := fmt.Sprintf("%d records", shown); embed.Footer == nil || embed.Footer.Text != want {
+			t.Errorf("expected the footer to be %q but received %+v", want, embed.Footer)
+		}
+	})
+}
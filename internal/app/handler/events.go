@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/aqyuki/felm/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// EventCounts are the numbers of messages handled by CitationService since it was created.
+type EventCounts struct {
+	// Messages is the number of messages received.
+	Messages uint64
+
+	// Links is the number of messages containing a message link.
+	Links uint64
+
+	// Citations is the number of citations sent.
+	Citations uint64
+
+	// RateLimited is the number of citations suppressed by the limiter.
+	RateLimited uint64
+
+	// Forbidden is the number of citations the bot was not allowed to send.
+	Forbidden uint64
+
+	// RESTCallsSaved is the number of REST API calls avoided by the caches.
+	RESTCallsSaved uint64
+}
+
+// sub returns the counts since prev.
+func (c EventCounts) sub(prev EventCounts) EventCounts {
+	return EventCounts{
+		Messages:       c.Messages - prev.Messages,
+		Links:          c.Links - prev.Links,
+		Citations:      c.Citations - prev.Citations,
+		RateLimited:    c.RateLimited - prev.RateLimited,
+		Forbidden:      c.Forbidden - prev.Forbidden,
+		RESTCallsSaved: c.RESTCallsSaved - prev.RESTCallsSaved,
+	}
+}
+
+// eventCounters counts the messages while they are handled concurrently.
+type eventCounters struct {
+	messages    atomic.Uint64
+	links       atomic.Uint64
+	citations   atomic.Uint64
+	rateLimited atomic.Uint64
+	forbidden   atomic.Uint64
+}
+
+// EventCounts returns the numbers of messages handled so far.
+func (srv *CitationService) EventCounts() EventCounts {
+	return EventCounts{
+		Messages:       srv.events.messages.Load(),
+		Links:          srv.events.links.Load(),
+		Citations:      srv.events.citations.Load(),
+		RateLimited:    srv.events.rateLimited.Load(),
+		Forbidden:      srv.events.forbidden.Load(),
+		RESTCallsSaved: srv.RESTCallsSaved(),
+	}
+}
+
+// LogEventSummary logs the numbers of messages handled in every interval until ctx is done,
+// so that the activity stays visible when the logs of every message are demoted to Debug.
+func (srv *CitationService) LogEventSummary(ctx context.Context, interval time.Duration) {
+	logger := logging.Named(ctx, citationLoggerName)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	prev := srv.EventCounts()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			current := srv.EventCounts()
+			counts := current.sub(prev)
+			prev = current
+			logger.Info("message events summary",
+				zap.Duration("interval", interval),
+				zap.Uint64("messages", counts.Messages),
+				zap.Uint64("links", counts.Links),
+				zap.Uint64("citations", counts.Citations),
+				zap.Uint64("rate_limited", counts.RateLimited),
+				zap.Uint64("forbidden", counts.Forbidden),
+				zap.Uint64("rest_calls_saved", counts.RESTCallsSaved))
+		}
+	}
+}
+
+// messageEventLogger returns the function logging the events of every message,
+// which logs at Debug instead of Info when DemoteMessageLogs is set.
+func (srv *CitationService) messageEventLogger(logger *zap.Logger) func(string, ...zap.Field) {
+	if srv.settings.Load().DemoteMessageLogs {
+		return logger.Debug
+	}
+	return logger.Info
+}
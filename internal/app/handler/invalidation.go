@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/aqyuki/felm/pkg/discord"
+	"github.com/aqyuki/felm/pkg/logging"
+	"github.com/bwmarrin/discordgo"
+	"github.com/samber/lo"
+	"go.uber.org/zap"
+)
+
+// cacheLoggerName is the name of the logger of the cache invalidation, whose level can be overridden.
+const cacheLoggerName = "cache"
+
+var (
+	_ discord.EventHandler[*discordgo.ChannelUpdate]     = (*CitationService)(nil).OnChannelUpdate
+	_ discord.EventHandler[*discordgo.ChannelDelete]     = (*CitationService)(nil).OnChannelDelete
+	_ discord.EventHandler[*discordgo.ThreadUpdate]      = (*CitationService)(nil).OnThreadUpdate
+	_ discord.EventHandler[*discordgo.ThreadDelete]      = (*CitationService)(nil).OnThreadDelete
+	_ discord.EventHandler[*discordgo.GuildRoleUpdate]   = (*CitationService)(nil).OnGuildRoleUpdate
+	_ discord.EventHandler[*discordgo.GuildMemberUpdate] = (*CitationService)(nil).OnGuildMemberUpdate
+	_ discord.EventHandler[*discordgo.MessageUpdate]     = (*CitationService)(nil).OnMessageUpdate
+	_ discord.EventHandler[*discordgo.MessageDelete]     = (*CitationService)(nil).OnMessageDelete
+	_ discord.EventHandler[*discordgo.MessageDeleteBulk] = (*CitationService)(nil).OnMessageDeleteBulk
+	_ discord.EventHandler[*discordgo.GuildCreate]       = (*CitationService)(nil).OnGuildCreate
+)
+
+// InvalidationHandlers returns the options registering the handlers which keep the channel and message caches coherent.
+func (srv *CitationService) InvalidationHandlers() []discord.Option {
+	return []discord.Option{
+		discord.WithEventHandler(srv.OnChannelUpdate),
+		discord.WithEventHandler(srv.OnChannelDelete),
+		discord.WithEventHandler(srv.OnThreadUpdate),
+		discord.WithEventHandler(srv.OnThreadDelete),
+		discord.WithEventHandler(srv.OnGuildRoleUpdate),
+		discord.WithEventHandler(srv.OnGuildMemberUpdate),
+		discord.WithEventHandler(srv.OnMessageUpdate),
+		discord.WithEventHandler(srv.OnMessageDelete),
+		discord.WithEventHandler(srv.OnMessageDeleteBulk),
+		discord.WithEventHandler(srv.OnGuildCreate),
+	}
+}
+
+func (srv *CitationService) OnChannelUpdate(ctx context.Context, _ *discordgo.Session, event *discordgo.ChannelUpdate) error {
+	srv.refreshChannel(ctx, event.Channel)
+	return nil
+}
+
+func (srv *CitationService) OnChannelDelete(ctx context.Context, _ *discordgo.Session, event *discordgo.ChannelDelete) error {
+	srv.evictChannel(ctx, event.Channel)
+	return nil
+}
+
+func (srv *CitationService) OnThreadUpdate(ctx context.Context, _ *discordgo.Session, event *discordgo.ThreadUpdate) error {
+	srv.refreshChannel(ctx, event.Channel)
+	return nil
+}
+
+func (srv *CitationService) OnThreadDelete(ctx context.Context, _ *discordgo.Session, event *discordgo.ThreadDelete) error {
+	srv.evictChannel(ctx, event.Channel)
+	return nil
+}
+
+// OnGuildCreate refreshes the cached channels of the guild with the ones received when connecting,
+// so that changes made while the bot was offline, e.g. restored from a snapshot, are not kept.
+// Only the channels in memory are refreshed, because the ones in the backend may have just been cached by another process,
+// and every connection would otherwise delete them.
+func (srv *CitationService) OnGuildCreate(ctx context.Context, _ *discordgo.Session, event *discordgo.GuildCreate) error {
+	if event.Guild == nil {
+		return nil
+	}
+	channels := make(map[string]*discordgo.Channel, len(event.Channels)+len(event.Threads))
+	for _, channel := range append(event.Channels, event.Threads...) {
+		channels[channel.ID] = channel
+	}
+
+	// only the channels in memory can be examined, and the deleted ones can not be cited anymore.
+	deleted := srv.channelCache.DeleteFunc(func(_ string, channel discordgo.Channel) bool {
+		_, ok := channels[channel.ID]
+		return channel.GuildID == event.ID && !ok
+	})
+	refreshed := 0
+	for _, channel := range channels {
+		if channel.GuildID == "" {
+			channel.GuildID = event.ID
+		}
+		if err := srv.channelCache.Refresh(channelCacheKey(channel.GuildID, channel.ID), lo.FromPtr(channel)); err == nil {
+			refreshed++
+		}
+	}
+	logging.Named(ctx, cacheLoggerName).Debug("cached channel information of guild refreshed",
+		zap.String("guild_id", event.ID),
+		zap.Int("refreshed", refreshed),
+		zap.Int("evicted", deleted))
+	return nil
+}
+
+// OnGuildRoleUpdate evicts the channels of the guild, because their effective permissions may have changed.
+func (srv *CitationService) OnGuildRoleUpdate(ctx context.Context, _ *discordgo.Session, event *discordgo.GuildRoleUpdate) error {
+	srv.evictGuild(ctx, event.GuildID)
+	return nil
+}
+
+// OnGuildMemberUpdate evicts the channels of the guild when the roles of the bot itself have changed.
+func (srv *CitationService) OnGuildMemberUpdate(ctx context.Context, session *discordgo.Session, event *discordgo.GuildMemberUpdate) error {
+	if event.Member == nil || event.User == nil || session.State == nil || session.State.User == nil {
+		return nil
+	}
+	if event.User.ID != session.State.User.ID {
+		return nil
+	}
+	srv.evictGuild(ctx, event.GuildID)
+	return nil
+}
+
+// refreshChannel replaces the cached channel with the one received from the gateway.
+// Channels which have never been cited are not cached, and their entries in the backend are deleted.
+func (srv *CitationService) refreshChannel(ctx context.Context, channel *discordgo.Channel) {
+	if channel == nil {
+		return
+	}
+	if err := srv.channelCache.Replace(channelCacheKey(channel.GuildID, channel.ID), lo.FromPtr(channel)); err == nil {
+		logging.Named(ctx, cacheLoggerName).Debug("cached channel information updated", zap.String("channel_id", channel.ID))
+	}
+}
+
+func (srv *CitationService) evictChannel(ctx context.Context, channel *discordgo.Channel) {
+	if channel == nil {
+		return
+	}
+	srv.channelCache.Delete(channelCacheKey(channel.GuildID, channel.ID))
+	srv.messageCache.DeletePrefix(channel.ID + "/")
+	logging.Named(ctx, cacheLoggerName).Debug("cached channel information evicted", zap.String("channel_id", channel.ID))
+}
+
+func (srv *CitationService) evictGuild(ctx context.Context, guildID string) {
+	deleted := srv.channelCache.DeletePrefix(guildID + "/")
+	logging.Named(ctx, cacheLoggerName).Debug("cached channel information of guild evicted",
+		zap.String("guild_id", guildID),
+		zap.Int("count", deleted))
+}
+
+// OnMessageUpdate replaces the cached message with the edited one.
+// The message is kept longer because its edits are delivered by the gateway.
+func (srv *CitationService) OnMessageUpdate(ctx context.Context, _ *discordgo.Session, event *discordgo.MessageUpdate) error {
+	if event.Message == nil {
+		return nil
+	}
+	key := messageCacheKey(event.ChannelID, event.ID)
+
+	// partial updates do not contain the author, so the cached message can not be rebuilt from them.
+	if event.Author == nil {
+		srv.messageCache.Delete(key)
+		logging.Named(ctx, cacheLoggerName).Debug("cached message evicted", zap.String("message_id", event.ID))
+		return nil
+	}
+	if err := srv.messageCache.ReplaceWithTTL(key, lo.FromPtr(event.Message), gatewayMessageCacheTTL); err == nil {
+		logging.Named(ctx, cacheLoggerName).Debug("cached message updated", zap.String("message_id", event.ID))
+	}
+	return nil
+}
+
+func (srv *CitationService) OnMessageDelete(ctx context.Context, _ *discordgo.Session, event *discordgo.MessageDelete) error {
+	if event.Message == nil {
+		return nil
+	}
+	srv.messageCache.Delete(messageCacheKey(event.ChannelID, event.ID))
+	logging.Named(ctx, cacheLoggerName).Debug("cached message evicted", zap.String("message_id", event.ID))
+	return nil
+}
+
+func (srv *CitationService) OnMessageDeleteBulk(ctx context.Context, _ *discordgo.Session, event *discordgo.MessageDeleteBulk) error {
+	for _, messageID := range event.Messages {
+		srv.messageCache.Delete(messageCacheKey(event.ChannelID, messageID))
+	}
+	logging.Named(ctx, cacheLoggerName).Debug("cached messages evicted",
+		zap.String("channel_id", event.ChannelID),
+		zap.Int("count", len(event.Messages)))
+	return nil
+}
@@ -0,0 +1,296 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/aqyuki/felm/pkg/audit"
+	"github.com/aqyuki/felm/pkg/cache"
+	"github.com/bwmarrin/discordgo"
+)
+
+// newCachedService returns the service whose caches share backend, as the processes sharing Redis do.
+func newCachedService(backend cache.Backend) *CitationService {
+	return NewCitationService(
+		WithChannelCache(NewChannelCache(cache.WithBackend(cache.PrefixBackend(backend, "channel:"), cache.JSONCodec{}))),
+		WithMessageCache(NewMessageCache(cache.WithBackend(cache.PrefixBackend(backend, "message:"), cache.JSONCodec{}))))
+}
+
+func TestOnGuildCreate(t *testing.T) {
+	t.Parallel()
+
+	backend := cache.NewMemoryBackend()
+	other := newCachedService(backend)
+	srv := newCachedService(backend)
+
+	// another process has just cached a channel, while this one holds a stale channel and a deleted one.
+	if err := other.channelCache.Set(channelCacheKey("g", "shared"), discordgo.Channel{ID: "shared", GuildID: "g", Name: "shared"}); err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+	if err := srv.channelCache.Set(channelCacheKey("g", "held"), discordgo.Channel{ID: "held", GuildID: "g", Name: "stale"}); err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+	if err := srv.channelCache.Set(channelCacheKey("g", "deleted"), discordgo.Channel{ID: "deleted", GuildID: "g"}); err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+
+	event := &discordgo.GuildCreate{Guild: &discordgo.Guild{
+		ID: "g",
+		Channels: []*discordgo.Channel{
+			{ID: "shared", Name: "shared"},
+			{ID: "held", Name: "fresh"},
+		},
+	}}
+	if err := srv.OnGuildCreate(context.Background(), nil, event); err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+
+	if _, _, err := backend.Get(context.Background(), "channel:"+channelCacheKey("g", "shared")); err != nil {
+		t.Errorf("expected the entry of another process to be kept in the backend but received %v", err)
+	}
+	if keys := srv.channelCache.Keys(); len(keys) != 1 || keys[0] != channelCacheKey("g", "held") {
+		t.Errorf("expected only the held channel to be cached in memory but received %v", keys)
+	}
+	// the refreshed channel is shared with the other processes too.
+	if channel, err := other.channelCache.Get(channelCacheKey("g", "held")); err != nil || channel.Name != "fresh" {
+		t.Errorf("expected the held channel to be refreshed but received (%+v, %v)", channel, err)
+	}
+	if _, err := other.channelCache.Get(channelCacheKey("g", "deleted")); !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("expected the deleted channel to be evicted from the backend but received %v", err)
+	}
+}
+
+// newSeededService returns the service which has cached channels c1 and c2 of guild g, c3 of guild h,
+// messages m1 and m2 of c1 and m3 of c2.
+func newSeededService(t *testing.T) *CitationService {
+	t.Helper()
+
+	srv := NewCitationService()
+	for _, channel := range []discordgo.Channel{
+		{ID: "c1", GuildID: "g"},
+		{ID: "c2", GuildID: "g", Type: discordgo.ChannelTypeGuildPublicThread},
+		{ID: "c3", GuildID: "h"},
+	} {
+		if err := srv.channelCache.Set(channelCacheKey(channel.GuildID, channel.ID), channel); err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+	}
+	for _, message := range []discordgo.Message{
+		{ID: "m1", ChannelID: "c1", Content: "first"},
+		{ID: "m2", ChannelID: "c1", Content: "second"},
+		{ID: "m3", ChannelID: "c2", Content: "third"},
+	} {
+		if err := srv.messageCache.Set(messageCacheKey(message.ChannelID, message.ID), message); err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+	}
+	return srv
+}
+
+func TestInvalidationHandlers(t *testing.T) {
+	t.Parallel()
+
+	session := &discordgo.Session{State: discordgo.NewState()}
+	session.State.User = &discordgo.User{ID: "bot"}
+
+	allChannels := []string{"g/c1", "g/c2", "h/c3"}
+	allMessages := []string{"c1/m1", "c1/m2", "c2/m3"}
+	tests := []struct {
+		name         string
+		handle       func(srv *CitationService) error
+		wantChannels []string
+		wantMessages []string
+		check        func(t *testing.T, srv *CitationService)
+	}{
+		{
+			name: "channel update replaces the cached channel",
+			handle: func(srv *CitationService) error {
+				return srv.OnChannelUpdate(context.Background(), session, &discordgo.ChannelUpdate{
+					Channel: &discordgo.Channel{ID: "c1", GuildID: "g", NSFW: true},
+				})
+			},
+			wantChannels: allChannels,
+			wantMessages: allMessages,
+			check: func(t *testing.T, srv *CitationService) {
+				if channel, err := srv.channelCache.Get("g/c1"); err != nil || !channel.NSFW {
+					t.Errorf("expected the channel to become NSFW but received (%+v, %v)", channel, err)
+				}
+			},
+		},
+		{
+			name: "channel update does not cache the channels never cited",
+			handle: func(srv *CitationService) error {
+				return srv.OnChannelUpdate(context.Background(), session, &discordgo.ChannelUpdate{
+					Channel: &discordgo.Channel{ID: "c4", GuildID: "g"},
+				})
+			},
+			wantChannels: allChannels,
+			wantMessages: allMessages,
+		},
+		{
+			name: "thread update replaces the cached thread",
+			handle: func(srv *CitationService) error {
+				return srv.OnThreadUpdate(context.Background(), session, &discordgo.ThreadUpdate{
+					Channel: &discordgo.Channel{ID: "c2", GuildID: "g", Name: "renamed"},
+				})
+			},
+			wantChannels: allChannels,
+			wantMessages: allMessages,
+			check: func(t *testing.T, srv *CitationService) {
+				if channel, err := srv.channelCache.Get("g/c2"); err != nil || channel.Name != "renamed" {
+					t.Errorf("expected the thread to be renamed but received (%+v, %v)", channel, err)
+				}
+			},
+		},
+		{
+			name: "channel delete evicts the channel and its messages",
+			handle: func(srv *CitationService) error {
+				return srv.OnChannelDelete(context.Background(), session, &discordgo.ChannelDelete{
+					Channel: &discordgo.Channel{ID: "c1", GuildID: "g"},
+				})
+			},
+			wantChannels: []string{"g/c2", "h/c3"},
+			wantMessages: []string{"c2/m3"},
+		},
+		{
+			name: "thread delete evicts the thread and its messages",
+			handle: func(srv *CitationService) error {
+				return srv.OnThreadDelete(context.Background(), session, &discordgo.ThreadDelete{
+					Channel: &discordgo.Channel{ID: "c2", GuildID: "g"},
+				})
+			},
+			wantChannels: []string{"g/c1", "h/c3"},
+			wantMessages: []string{"c1/m1", "c1/m2"},
+		},
+		{
+			name: "role update evicts the channels of the guild",
+			handle: func(srv *CitationService) error {
+				return srv.OnGuildRoleUpdate(context.Background(), session, &discordgo.GuildRoleUpdate{
+					GuildRole: &discordgo.GuildRole{GuildID: "g", Role: &discordgo.Role{ID: "r"}},
+				})
+			},
+			wantChannels: []string{"h/c3"},
+			wantMessages: allMessages,
+		},
+		{
+			name: "member update of the bot evicts the channels of the guild",
+			handle: func(srv *CitationService) error {
+				return srv.OnGuildMemberUpdate(context.Background(), session, &discordgo.GuildMemberUpdate{
+					Member: &discordgo.Member{GuildID: "g", User: &discordgo.User{ID: "bot"}},
+				})
+			},
+			wantChannels: []string{"h/c3"},
+			wantMessages: allMessages,
+		},
+		{
+			name: "member update of another user keeps the channels",
+			handle: func(srv *CitationService) error {
+				return srv.OnGuildMemberUpdate(context.Background(), session, &discordgo.GuildMemberUpdate{
+					Member: &discordgo.Member{GuildID: "g", User: &discordgo.User{ID: "user"}},
+				})
+			},
+			wantChannels: allChannels,
+			wantMessages: allMessages,
+		},
+		{
+			name: "message update replaces the cached message",
+			handle: func(srv *CitationService) error {
+				return srv.OnMessageUpdate(context.Background(), session, &discordgo.MessageUpdate{
+					Message: &discordgo.Message{ID: "m1", ChannelID: "c1", Content: "edited", Author: &discordgo.User{ID: "user"}},
+				})
+			},
+			wantChannels: allChannels,
+			wantMessages: allMessages,
+			check: func(t *testing.T, srv *CitationService) {
+				if message, err := srv.messageCache.Get("c1/m1"); err != nil || message.Content != "edited" {
+					t.Errorf("expected the message to be edited but received (%+v, %v)", message, err)
+				}
+			},
+		},
+		{
+			name: "partial message update evicts the message",
+			handle: func(srv *CitationService) error {
+				return srv.OnMessageUpdate(context.Background(), session, &discordgo.MessageUpdate{
+					Message: &discordgo.Message{ID: "m1", ChannelID: "c1"},
+				})
+			},
+			wantChannels: allChannels,
+			wantMessages: []string{"c1/m2", "c2/m3"},
+		},
+		{
+			name: "message delete evicts the message",
+			handle: func(srv *CitationService) error {
+				return srv.OnMessageDelete(context.Background(), session, &discordgo.MessageDelete{
+					Message: &discordgo.Message{ID: "m2", ChannelID: "c1"},
+				})
+			},
+			wantChannels: allChannels,
+			wantMessages: []string{"c1/m1", "c2/m3"},
+		},
+		{
+			name: "bulk message delete evicts the messages",
+			handle: func(srv *CitationService) error {
+				return srv.OnMessageDeleteBulk(context.Background(), session, &discordgo.MessageDeleteBulk{
+					ChannelID: "c1",
+					Messages:  []string{"m1", "m2", "m4"},
+				})
+			},
+			wantChannels: allChannels,
+			wantMessages: []string{"c2/m3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := newSeededService(t)
+			if err := tt.handle(srv); err != nil {
+				t.Fatalf("expected err to be nil but received %v", err)
+			}
+			if keys := srv.channelCache.Keys(); !slices.Equal(keys, tt.wantChannels) {
+				t.Errorf("expected the cached channels to be %v but received %v", tt.wantChannels, keys)
+			}
+			if keys := srv.messageCache.Keys(); !slices.Equal(keys, tt.wantMessages) {
+				t.Errorf("expected the cached messages to be %v but received %v", tt.wantMessages, keys)
+			}
+			if tt.check != nil {
+				tt.check(t, srv)
+			}
+		})
+	}
+}
+
+func TestNSFWGateFollowsChannelUpdate(t *testing.T) {
+	t.Parallel()
+
+	sink := audit.NewMemory()
+	srv := NewCitationService(WithAuditSink(sink))
+	if err := srv.channelCache.Set(channelCacheKey("1", "2"), discordgo.Channel{ID: "2", GuildID: "1"}); err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+	if err := srv.OnChannelUpdate(context.Background(), nil, &discordgo.ChannelUpdate{
+		Channel: &discordgo.Channel{ID: "2", GuildID: "1", NSFW: true},
+	}); err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+
+	// the cached channel is used without calling the API, so the citation must be skipped with the updated channel.
+	if err := srv.On(context.Background(), &discordgo.Session{}, &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        "4",
+		GuildID:   "1",
+		ChannelID: "5",
+		Content:   "https://discord.com/channels/1/2/3",
+		Author:    &discordgo.User{ID: "user"},
+	}}); err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+	records, err := sink.Query(context.Background(), audit.Query{GuildID: "1"})
+	if err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+	if len(records) != 1 || records[0].Decision != audit.DecisionSkipped || records[0].Reason != reasonNSFW {
+		t.Errorf("expected the citation to be skipped as NSFW but received %+v", records)
+	}
+}
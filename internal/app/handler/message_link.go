@@ -5,11 +5,15 @@
 	"errors"
 	"fmt"
 	"regexp"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/aqyuki/felm/pkg/audit"
 	"github.com/aqyuki/felm/pkg/cache"
 	"github.com/aqyuki/felm/pkg/discord"
 	"github.com/aqyuki/felm/pkg/logging"
+	"github.com/aqyuki/felm/pkg/ratelimit"
 	"github.com/aqyuki/felm/pkg/trace"
 	"github.com/bwmarrin/discordgo"
 	"github.com/samber/lo"
@@ -17,7 +21,11 @@
 	"go.uber.org/zap"
 )
 
-const embedColor = 0x7fffff
+// citationLoggerName is the name of the logger of CitationService, whose level can be overridden.
+const citationLoggerName = "handler.citation"
+
+// DefaultEmbedColor is the color of the embeds of citations.
+const DefaultEmbedColor = 0x7fffff
 
 var _ discord.MessageCreateHandler = (*CitationService)(nil).On
 
@@ -25,21 +33,241 @@
 
 type CitationService struct {
 	channelCache *cache.Cache[discordgo.Channel]
+	messageCache *cache.Cache[discordgo.Message]
 	messageRegex *regexp.Regexp
+	rest         *discord.REST
+
+	// limiter suppresses citations when users, channels or guilds exceed their limits.
+	limiter *ratelimit.Limiter
+
+	// settings can be replaced at runtime by Reconfigure, so it is read once per event.
+	settings atomic.Pointer[CitationSettings]
+
+	// events counts the messages handled since the service was created.
+	events eventCounters
+
+	// onForbidden is called when the bot is not allowed to reply in a channel.
+	onForbidden func(guildID, channelID string)
+
+	// auditSink receives a record of every message link posted in a guild. nil disables the audit log.
+	auditSink audit.Sink
+
+	// citations counts the citations sent on citationsDay, which is the local date.
+	citationsMu  sync.Mutex
+	citationsDay string
+	citations    int
+}
+
+// CitationSettings are the settings of CitationService which can be changed while it is running.
+type CitationSettings struct {
+	// SuppressedReaction is the emoji added to suppressed messages. Empty disables the reaction.
+	SuppressedReaction string
+
+	// StateFallback enables looking up channels in the discordgo state before calling the REST API.
+	StateFallback bool
+
+	// EmbedColor is the color of the embeds of citations.
+	EmbedColor int
+
+	// MentionAuthor mentions the author of the message containing the link in the reply.
+	MentionAuthor bool
+
+	// DemoteMessageLogs logs the events of every message at Debug instead of Info.
+	DemoteMessageLogs bool
+}
+
+type CitationOption func(*CitationService)
+
+// updateSettings replaces the settings with a copy changed by fn.
+func (srv *CitationService) updateSettings(fn func(*CitationSettings)) {
+	settings := *srv.settings.Load()
+	fn(&settings)
+	srv.settings.Store(&settings)
+}
+
+// WithRateLimiter sets the limiter checked before expanding a message link.
+func WithRateLimiter(limiter *ratelimit.Limiter) CitationOption {
+	return func(srv *CitationService) {
+		srv.limiter = limiter
+	}
+}
+
+// WithChannelCache replaces the cache of channel information.
+func WithChannelCache(channelCache *cache.Cache[discordgo.Channel]) CitationOption {
+	return func(srv *CitationService) {
+		if channelCache != nil {
+			srv.channelCache = channelCache
+		}
+	}
+}
+
+// WithMessageCache replaces the cache of cited messages.
+func WithMessageCache(messageCache *cache.Cache[discordgo.Message]) CitationOption {
+	return func(srv *CitationService) {
+		if messageCache != nil {
+			srv.messageCache = messageCache
+		}
+	}
+}
+
+// WithREST sets the client used to call the Discord REST API.
+func WithREST(rest *discord.REST) CitationOption {
+	return func(srv *CitationService) {
+		if rest != nil {
+			srv.rest = rest
+		}
+	}
+}
+
+// WithSuppressedReaction sets the emoji added to messages whose citation was suppressed by the limiter.
+func WithSuppressedReaction(emoji string) CitationOption {
+	return func(srv *CitationService) {
+		srv.updateSettings(func(s *CitationSettings) { s.SuppressedReaction = emoji })
+	}
+}
+
+// WithStateFallback enables looking up channels in the discordgo state before calling the REST API.
+func WithStateFallback(enabled bool) CitationOption {
+	return func(srv *CitationService) {
+		srv.updateSettings(func(s *CitationSettings) { s.StateFallback = enabled })
+	}
+}
+
+// WithEmbedColor sets the color of the embeds of citations.
+func WithEmbedColor(color int) CitationOption {
+	return func(srv *CitationService) {
+		srv.updateSettings(func(s *CitationSettings) { s.EmbedColor = color })
+	}
+}
+
+// WithMentionAuthor sets whether the reply mentions the author of the message containing the link.
+func WithMentionAuthor(enabled bool) CitationOption {
+	return func(srv *CitationService) {
+		srv.updateSettings(func(s *CitationSettings) { s.MentionAuthor = enabled })
+	}
+}
+
+// WithForbiddenHook sets the function called when the bot is not allowed to reply in a channel, e.g. to alert the operators.
+func WithForbiddenHook(hook func(guildID, channelID string)) CitationOption {
+	return func(srv *CitationService) {
+		if hook != nil {
+			srv.onForbidden = hook
+		}
+	}
+}
+
+// WithAuditSink sets the sink of the audit records of the message links.
+func WithAuditSink(sink audit.Sink) CitationOption {
+	return func(srv *CitationService) {
+		srv.auditSink = sink
+	}
+}
+
+// WithDemotedMessageLogs sets whether the events of every message are logged at Debug instead of Info.
+func WithDemotedMessageLogs(enabled bool) CitationOption {
+	return func(srv *CitationService) {
+		srv.updateSettings(func(s *CitationSettings) { s.DemoteMessageLogs = enabled })
+	}
+}
+
+// Settings returns the current settings.
+func (srv *CitationService) Settings() CitationSettings {
+	return *srv.settings.Load()
+}
+
+// Reconfigure replaces the settings. Events being handled keep the settings they started with.
+func (srv *CitationService) Reconfigure(settings CitationSettings) {
+	srv.settings.Store(&settings)
 }
 
-func NewCitationService() *CitationService {
-	return &CitationService{
-		channelCache: cache.New[discordgo.Channel](24 * time.Hour),
+// negativeCacheTTL is the duration to remember channels which can not be fetched.
+const negativeCacheTTL = 1 * time.Minute
+
+// NewChannelCache creates the cache of channel information which remembers inaccessible channels.
+func NewChannelCache(option ...cache.Option) *cache.Cache[discordgo.Channel] {
+	option = append([]cache.Option{cache.WithNegativeCache(negativeCacheTTL, skippable)}, option...)
+	return cache.New[discordgo.Channel](24*time.Hour, option...)
+}
+
+// messageCacheTTL is the duration to keep messages fetched from the API.
+// It is short because edits of messages are only noticed while the gateway delivers them.
+const messageCacheTTL = 5 * time.Minute
+
+// gatewayMessageCacheTTL is the duration to keep messages whose latest content was received from the gateway.
+const gatewayMessageCacheTTL = 1 * time.Hour
+
+// NewMessageCache creates the cache of cited messages which remembers inaccessible messages.
+func NewMessageCache(option ...cache.Option) *cache.Cache[discordgo.Message] {
+	option = append([]cache.Option{cache.WithNegativeCache(negativeCacheTTL, skippable)}, option...)
+	return cache.New[discordgo.Message](messageCacheTTL, option...)
+}
+
+// ChannelCacheStats returns the statistics of the channel cache.
+func (srv *CitationService) ChannelCacheStats() cache.Stats {
+	return srv.channelCache.Stats()
+}
+
+// MessageCacheStats returns the statistics of the message cache.
+func (srv *CitationService) MessageCacheStats() cache.Stats {
+	return srv.messageCache.Stats()
+}
+
+// RESTCallsSaved returns the number of REST API calls avoided by the caches.
+func (srv *CitationService) RESTCallsSaved() uint64 {
+	saved := uint64(0)
+	for _, stats := range []cache.Stats{srv.ChannelCacheStats(), srv.MessageCacheStats()} {
+		saved += stats.Hits + stats.NegativeHits + stats.Coalesced
+	}
+	return saved
+}
+
+// CitationsToday returns the number of citations sent today in the local time zone.
+func (srv *CitationService) CitationsToday() int {
+	srv.citationsMu.Lock()
+	defer srv.citationsMu.Unlock()
+
+	if srv.citationsDay != today() {
+		return 0
+	}
+	return srv.citations
+}
+
+func (srv *CitationService) recordCitation() {
+	srv.citationsMu.Lock()
+	defer srv.citationsMu.Unlock()
+
+	if day := today(); srv.citationsDay != day {
+		srv.citationsDay = day
+		srv.citations = 0
+	}
+	srv.citations++
+}
+
+func today() string {
+	return time.Now().Format(time.DateOnly)
+}
+
+func NewCitationService(option ...CitationOption) *CitationService {
+	srv := &CitationService{
+		channelCache: NewChannelCache(),
+		messageCache: NewMessageCache(),
 		messageRegex: regexp.MustCompile(`https://(?:ptb\.|canary\.)?discord\.com/channels/(?P<guild_id>\d+)/(?P<channel_id>\d+)/(?P<message_id>\d+)`),
+		rest:         discord.NewREST(),
+		onForbidden:  func(string, string) {},
 	}
+	srv.settings.Store(&CitationSettings{EmbedColor: DefaultEmbedColor, MentionAuthor: true})
+	for _, opt := range option {
+		opt(srv)
+	}
+	return srv
 }
 
 func (srv *CitationService) On(ctx context.Context, session *discordgo.Session, message *discordgo.MessageCreate) error {
-	logger := logging.FromContext(ctx)
+	logger := logging.Named(ctx, citationLoggerName)
+	logMessageEvent := srv.messageEventLogger(logger)
+	srv.events.messages.Add(1)
 
-	logger.Info("handler called",
-		zap.String("trace_id", trace.AcquireTraceID(ctx)),
+	logMessageEvent("handler called",
 		zap.Dict("message",
 			zap.String("guild_id", message.GuildID),
 			zap.String("channel_id", message.ChannelID),
@@ -63,48 +291,86 @@ func (srv *CitationService) On(ctx context.Context, session *discordgo.Session,
 		}
 		return oops.
 			Trace(trace.AcquireTraceID(ctx)).
-			With("message",
-				oops.With("guild_id", message.GuildID),
-				oops.With("channel_id", message.ChannelID),
-				oops.With("message_id", message.ID)).
+			With(
+				"guild_id", message.GuildID,
+				"channel_id", message.ChannelID,
+				"message_id", message.ID).
 			Wrapf(err, "error occurred while parsing message link (message_id = %s)", message.ID)
 	}
 
-	logger.Info("message link detected",
+	srv.events.links.Add(1)
+	logMessageEvent("message link detected",
 		zap.Dict("message_link",
 			zap.String("guild_id", ids.guildID),
 			zap.String("channel_id", ids.channelID),
 			zap.String("message_id", ids.messageID)))
 
+	record := newAuditRecord(ctx, message.Message, ids)
 	if message.GuildID != ids.guildID {
 		logger.Debug("skip processing message because it was sent from different guild")
+		srv.audit(ctx, record.Skipped(reasonCrossGuild))
 		return nil
 	}
 
-	citationChannel, err := srv.fetchChannel(ctx, session, ids.channelID)
+	if srv.limiter != nil {
+		decision := srv.limiter.Allow(ratelimit.Key{
+			GuildID:   message.GuildID,
+			ChannelID: message.ChannelID,
+			UserID:    message.Author.ID,
+		})
+		if !decision.Allowed {
+			srv.events.rateLimited.Add(1)
+			logMessageEvent("skip processing message because it was rate limited",
+				zap.String("message_id", message.ID),
+				zap.String("scope", string(decision.Scope)),
+				zap.Duration("retry_after", decision.RetryAfter))
+			srv.reactSuppressed(ctx, session, message.Message)
+			srv.audit(ctx, record.Skipped(reasonRateLimited+":"+string(decision.Scope)))
+			return nil
+		}
+	}
+
+	citationChannel, err := srv.fetchChannel(ctx, session, ids.guildID, ids.channelID)
 	if err != nil {
+		if skippable(err) {
+			logger.Debug("skip processing message because the cited channel is not accessible",
+				zap.String("message_id", message.ID),
+				zap.Error(err))
+			srv.audit(ctx, record.Skipped(reasonChannelInaccessible))
+			return nil
+		}
+		srv.audit(ctx, record.Failed(reasonError))
 		return oops.
 			Trace(trace.AcquireTraceID(ctx)).
-			With("message_detail",
-				oops.With("guild_id", message.GuildID),
-				oops.With("channel_id", message.ChannelID),
-				oops.With("message_id", message.ID)).
+			With(
+				"guild_id", message.GuildID,
+				"channel_id", message.ChannelID,
+				"message_id", message.ID).
 			Wrapf(err, "error occurred while fetching channel information (channel_id = %s)", ids.channelID)
 	}
 
 	if citationChannel.NSFW {
 		logger.Debug("skip processing message because it was sent from NSFW channel", zap.String("message_id", message.ID))
+		srv.audit(ctx, record.Skipped(reasonNSFW))
 		return nil
 	}
 
-	citationMessage, err := session.ChannelMessage(ids.channelID, ids.messageID)
+	citationMessage, err := srv.fetchMessage(ctx, session, ids.channelID, ids.messageID)
 	if err != nil {
+		if skippable(err) {
+			logger.Debug("skip processing message because the cited message is not accessible",
+				zap.String("message_id", message.ID),
+				zap.Error(err))
+			srv.audit(ctx, record.Skipped(reasonMessageInaccessible))
+			return nil
+		}
+		srv.audit(ctx, record.Failed(reasonError))
 		return oops.
 			Trace(trace.AcquireTraceID(ctx)).
-			With("message_detail",
-				oops.With("guild_id", message.GuildID),
-				oops.With("channel_id", message.ChannelID),
-				oops.With("message_id", message.ID)).
+			With(
+				"guild_id", message.GuildID,
+				"channel_id", message.ChannelID,
+				"message_id", message.ID).
 			Wrapf(err, "error occurred while fetching message information (channel_id = %s, message_id = %s)", ids.channelID, ids.messageID)
 	}
 
@@ -116,19 +382,30 @@ func (srv *CitationService) On(ctx context.Context, session *discordgo.Session,
 		// Embedも含まれていない場合は何もせずに処理を終了する
 		if len(citationMessage.Embeds) == 0 {
 			logger.Debug("skip processing message because it was not contains expandable content", zap.String("message_id", message.ID))
+			srv.audit(ctx, record.Skipped(reasonNoContent))
 			return nil
 		}
 
 		// Embedが含まれている場合はEmbedをそのまま返す
-		if err := srv.sendReply(session, message.ChannelID, srv.buildReply(message.Message, citationMessage.Embeds[0])); err != nil {
+		reply, err := srv.sendReply(ctx, session, message.ChannelID, srv.buildReply(message.Message, citationMessage.Embeds[0]))
+		if err != nil {
+			if errors.Is(err, discord.ErrForbidden) {
+				srv.events.forbidden.Add(1)
+				srv.onForbidden(message.GuildID, message.ChannelID)
+				logMessageEvent("skip replying because the bot is not allowed to send messages", zap.String("channel_id", message.ChannelID))
+				srv.audit(ctx, record.Skipped(reasonReplyForbidden))
+				return nil
+			}
+			srv.audit(ctx, record.Failed(reasonError))
 			return oops.
 				Trace(trace.AcquireTraceID(ctx)).
-				With("message_detail",
-					oops.With("guild_id", message.GuildID),
-					oops.With("channel_id", message.ChannelID),
-					oops.With("message_id", message.ID)).
+				With(
+					"guild_id", message.GuildID,
+					"channel_id", message.ChannelID,
+					"message_id", message.ID).
 				Wrapf(err, "error occurred while sending message (channel_id = %s)", message.ChannelID)
 		}
+		srv.audit(ctx, record.Cited(reply.ID))
 		return nil
 	}
 
@@ -146,6 +423,7 @@ func (srv *CitationService) On(ctx context.Context, session *discordgo.Session,
 			// もし､メッセージ本文が空で画像が含まれていない場合は何もしない
 			// e.g. 動画のみの場合や添付ファイルのみの場合
 			logger.Debug("skip processing message because it does not contain expandable content", zap.String("message_id", message.ID))
+			srv.audit(ctx, record.Skipped(reasonNoContent))
 			return nil
 		}
 	}
@@ -155,7 +433,7 @@ func (srv *CitationService) On(ctx context.Context, session *discordgo.Session,
 			Name:    message.Author.Username,
 			IconURL: message.Author.AvatarURL(""),
 		},
-		Color:       embedColor,
+		Color:       srv.settings.Load().EmbedColor,
 		Description: citationMessage.Content,
 		Image:       image,
 		Timestamp:   message.Timestamp.Format(time.RFC3339),
@@ -164,15 +442,25 @@ func (srv *CitationService) On(ctx context.Context, session *discordgo.Session,
 		},
 	}
 
-	if err := srv.sendReply(session, message.ChannelID, srv.buildReply(message.Message, embed)); err != nil {
+	reply, err := srv.sendReply(ctx, session, message.ChannelID, srv.buildReply(message.Message, embed))
+	if err != nil {
+		if errors.Is(err, discord.ErrForbidden) {
+			srv.events.forbidden.Add(1)
+			srv.onForbidden(message.GuildID, message.ChannelID)
+			logMessageEvent("skip replying because the bot is not allowed to send messages", zap.String("channel_id", message.ChannelID))
+			srv.audit(ctx, record.Skipped(reasonReplyForbidden))
+			return nil
+		}
+		srv.audit(ctx, record.Failed(reasonError))
 		return oops.
 			Trace(trace.AcquireTraceID(ctx)).
-			With("message_detail",
-				oops.With("guild_id", message.GuildID),
-				oops.With("channel_id", message.ChannelID),
-				oops.With("message_id", message.ID)).
+			With(
+				"guild_id", message.GuildID,
+				"channel_id", message.ChannelID,
+				"message_id", message.ID).
 			Wrapf(err, "error occurred while sending message (channel_id = %s)", message.ChannelID)
 	}
+	srv.audit(ctx, record.Cited(reply.ID))
 	return nil
 }
 
@@ -195,43 +483,96 @@ func (srv *CitationService) parseMessageLink(message string) (*messageLink, erro
 	}, nil
 }
 
-func (srv *CitationService) fetchChannel(ctx context.Context, session *discordgo.Session, channelID string) (*discordgo.Channel, error) {
-	logger := logging.FromContext(ctx)
+func (srv *CitationService) fetchChannel(ctx context.Context, session *discordgo.Session, guildID, channelID string) (*discordgo.Channel, error) {
+	logger := logging.Named(ctx, citationLoggerName)
 
-	citationChannel, err := srv.channelCache.Get(channelID)
-	if err == nil {
-		logger.Debug("channel information fetched from cache (cache hit)", zap.String("channel_id", channelID))
-		return lo.ToPtr(citationChannel), nil
-	}
-	if !errors.Is(err, cache.ErrNotFound) {
-		return nil, fmt.Errorf("error occurred while fetching channel information from cache (channel_id = %s)", channelID)
+	// the state is kept up to date by the gateway, so it is not necessary to cache the channel.
+	if srv.settings.Load().StateFallback && session.StateEnabled && session.State != nil {
+		if channel, err := session.State.Channel(channelID); err == nil && channel.GuildID == guildID {
+			logger.Debug("channel information fetched from state", zap.String("channel_id", channelID))
+			return channel, nil
+		}
 	}
 
-	channel, err := session.Channel(channelID)
+	// concurrent citations of the same channel share a single API call,
+	// and inaccessible channels are remembered for a while to avoid calling the API every time.
+	citationChannel, err := srv.channelCache.GetOrLoad(ctx, channelCacheKey(guildID, channelID), func(ctx context.Context, _ string) (discordgo.Channel, error) {
+		channel, err := srv.rest.Channel(ctx, session, channelID)
+		if err != nil {
+			return discordgo.Channel{}, err
+		}
+		// the channel is cached under the guild of the link, which must be its guild to be evicted with it.
+		if channel.GuildID != guildID {
+			return discordgo.Channel{}, fmt.Errorf("channel belongs to another guild (guild_id = %s): %w", channel.GuildID, discord.ErrNotFound)
+		}
+		logger.Debug("channel information fetched from API (cache miss)", zap.String("channel_id", channelID))
+		return lo.FromPtr(channel), nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error occurred while fetching channel information (channel_id = %s)", channelID)
+		return nil, fmt.Errorf("error occurred while fetching channel information (channel_id = %s): %w", channelID, err)
 	}
-	logger.Debug("channel information fetched from API (cache miss)", zap.String("channel_id", channelID))
+	return lo.ToPtr(citationChannel), nil
+}
+
+func (srv *CitationService) fetchMessage(ctx context.Context, session *discordgo.Session, channelID, messageID string) (*discordgo.Message, error) {
+	logger := logging.Named(ctx, citationLoggerName)
 
-	if err := srv.channelCache.Set(channelID, lo.FromPtr(channel)); err != nil {
-		return nil, fmt.Errorf("error occurred while caching channel information (channel_id = %s)", channelID)
+	citationMessage, err := srv.messageCache.GetOrLoad(ctx, messageCacheKey(channelID, messageID), func(ctx context.Context, _ string) (discordgo.Message, error) {
+		message, err := srv.rest.ChannelMessage(ctx, session, channelID, messageID)
+		if err != nil {
+			return discordgo.Message{}, err
+		}
+		logger.Debug("message fetched from API (cache miss)", zap.String("channel_id", channelID), zap.String("message_id", messageID))
+		return lo.FromPtr(message), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while fetching message (channel_id = %s, message_id = %s): %w", channelID, messageID, err)
 	}
-	logger.Debug("channel information cached", zap.String("channel_id", channelID))
+	return lo.ToPtr(citationMessage), nil
+}
 
-	return channel, nil
+// channelCacheKey returns the key of the channel, which starts with the guild so that the channels of a guild
+// can be evicted by prefix, even from the backend.
+func channelCacheKey(guildID, channelID string) string {
+	return guildID + "/" + channelID
+}
+
+func messageCacheKey(channelID, messageID string) string {
+	return channelID + "/" + messageID
 }
 
 func (srv *CitationService) buildReply(message *discordgo.Message, embed *discordgo.MessageEmbed) *discordgo.MessageSend {
 	return &discordgo.MessageSend{
 		Embed:           embed,
 		Reference:       message.Reference(),
-		AllowedMentions: &discordgo.MessageAllowedMentions{RepliedUser: true},
+		AllowedMentions: &discordgo.MessageAllowedMentions{RepliedUser: srv.settings.Load().MentionAuthor},
 	}
 }
 
-func (srv *CitationService) sendReply(session *discordgo.Session, channelID string, replyMsg *discordgo.MessageSend) error {
-	if _, err := session.ChannelMessageSendComplex(channelID, replyMsg); err != nil {
-		return fmt.Errorf("error occurred while sending message (channel_id = %s)", channelID)
+func (srv *CitationService) reactSuppressed(ctx context.Context, session *discordgo.Session, message *discordgo.Message) {
+	emoji := srv.settings.Load().SuppressedReaction
+	if emoji == "" {
+		return
 	}
-	return nil
+	if err := srv.rest.MessageReactionAdd(ctx, session, message.ChannelID, message.ID, emoji); err != nil {
+		logging.Named(ctx, citationLoggerName).Warn("failed to add reaction to suppressed message",
+			zap.String("message_id", message.ID),
+			zap.Error(err))
+	}
+}
+
+func (srv *CitationService) sendReply(ctx context.Context, session *discordgo.Session, channelID string, replyMsg *discordgo.MessageSend) (*discordgo.Message, error) {
+	reply, err := srv.rest.ChannelMessageSendComplex(ctx, session, channelID, replyMsg)
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while sending message (channel_id = %s): %w", channelID, err)
+	}
+	srv.recordCitation()
+	srv.events.citations.Add(1)
+	return reply, nil
+}
+
+// skippable reports whether the error means the cited resource can not be expanded,
+// rather than a failure which should be reported.
+func skippable(err error) bool {
+	return errors.Is(err, discord.ErrNotFound) || errors.Is(err, discord.ErrForbidden)
 }
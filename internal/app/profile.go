@@ -1,10 +0,0 @@
-package app
-
-import (
-	"time"
-)
-
-type Profile struct {
-	Token   string
-	Timeout time.Duration
-}
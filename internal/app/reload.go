@@ -0,0 +1,128 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// ErrNotReloadable is returned when a key which is only read on startup was changed.
+var ErrNotReloadable = errors.New("configuration can not be reloaded")
+
+// reloadableKeys are the keys, or the prefixes of the keys, which can be changed while felm is running.
+// The others, e.g. the token and the shards, require a new connection to the gateway.
+var reloadableKeys = []string{
+	"log.level",
+	"log.levels",
+	"log.demote_messages",
+	"state_fallback",
+	"presence.",
+	"ratelimit.",
+	"embed.",
+}
+
+// Change is a key whose value was changed. Secrets are redacted.
+type Change struct {
+	Key string
+	Old any
+	New any
+}
+
+// Reloadable reports whether the key can be changed while felm is running.
+func (c Change) Reloadable() bool {
+	for _, key := range reloadableKeys {
+		if c.Key == key || (strings.HasSuffix(key, ".") && strings.HasPrefix(c.Key, key)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff returns the keys changed from old to new in the order of the fields of Config.
+func Diff(old, new *Config) []Change {
+	changes := make([]Change, 0)
+	oldShown, newShown := old.Redacted(), new.Redacted()
+	diff(&changes, "",
+		reflect.ValueOf(*old), reflect.ValueOf(*new),
+		reflect.ValueOf(oldShown), reflect.ValueOf(newShown))
+	return changes
+}
+
+// diff compares the fields of the structs old and new, and appends the changes with the values of the shown structs.
+func diff(changes *[]Change, prefix string, old, new, oldShown, newShown reflect.Value) {
+	for i := range old.NumField() {
+		key := prefix + old.Type().Field(i).Tag.Get("mapstructure")
+		if old.Field(i).Kind() == reflect.Struct {
+			diff(changes, key+".", old.Field(i), new.Field(i), oldShown.Field(i), newShown.Field(i))
+			continue
+		}
+		if equal(old.Field(i), new.Field(i)) {
+			continue
+		}
+		*changes = append(*changes, Change{
+			Key: key,
+			Old: oldShown.Field(i).Interface(),
+			New: newShown.Field(i).Interface(),
+		})
+	}
+}
+
+// equal reports whether the values of a field are the same. Maps are compared by their entries, and an empty map equals nil.
+func equal(old, new reflect.Value) bool {
+	if old.Kind() == reflect.Map {
+		return (old.Len() == 0 && new.Len() == 0) || reflect.DeepEqual(old.Interface(), new.Interface())
+	}
+	return old.Equal(new)
+}
+
+// Reloader applies a new configuration while felm is running.
+type Reloader struct {
+	mu      sync.Mutex
+	current *Config
+	apply   func(*Config)
+}
+
+// NewReloader creates a reloader of the running configuration.
+// apply is called with every accepted configuration and must replace the reloadable settings.
+func NewReloader(current *Config, apply func(*Config)) *Reloader {
+	return &Reloader{
+		current: current,
+		apply:   apply,
+	}
+}
+
+// Current returns the running configuration.
+func (r *Reloader) Current() *Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.current
+}
+
+// Reload applies the validated configuration and returns the changes.
+// When a key which is not reloadable was changed, nothing is applied and ErrNotReloadable is returned with the changes,
+// so that a half applied configuration is never running.
+func (r *Reloader) Reload(cfg *Config) ([]Change, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	changes := Diff(r.current, cfg)
+	rejected := make([]string, 0)
+	for _, change := range changes {
+		if !change.Reloadable() {
+			rejected = append(rejected, change.Key)
+		}
+	}
+	if len(rejected) > 0 {
+		return changes, fmt.Errorf("%w: restart felm to change %s", ErrNotReloadable, strings.Join(rejected, ", "))
+	}
+	if len(changes) == 0 {
+		return changes, nil
+	}
+
+	r.apply(cfg)
+	r.current = cfg
+	return changes, nil
+}
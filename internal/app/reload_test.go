@@ -0,0 +1,111 @@
+package app
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aqyuki/felm/pkg/logging"
+)
+
+func runningConfig() *Config {
+	return &Config{
+		Token:  "token",
+		Shards: 2,
+		Log:    LogConfig{Level: "info"},
+		RateLimit: RateLimitConfig{
+			User:   LimitConfig{Burst: 3, Interval: time.Minute},
+			Guilds: map[string]GuildLimitConfig{"123": {User: &LimitConfig{Burst: 3, Interval: time.Minute}}},
+		},
+		Embed: EmbedConfig{Color: 0x7fffff},
+	}
+}
+
+func TestReloader(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		change  func(cfg *Config)
+		want    []Change
+		wantErr error
+	}{
+		{
+			name: "reloadable change",
+			change: func(cfg *Config) {
+				cfg.Log.Level = "debug"
+				cfg.RateLimit.User.Burst = 5
+			},
+			want: []Change{
+				{Key: "log.level", Old: "info", New: "debug"},
+				{Key: "ratelimit.user.burst", Old: 3, New: 5},
+			},
+		},
+		{
+			name: "guild overrides are compared by their entries",
+			change: func(cfg *Config) {
+				cfg.RateLimit.Guilds = map[string]GuildLimitConfig{"123": {User: &LimitConfig{Burst: 1, Interval: time.Minute}}}
+			},
+			want: []Change{{
+				Key: "ratelimit.guilds",
+				Old: map[string]GuildLimitConfig{"123": {User: &LimitConfig{Burst: 3, Interval: time.Minute}}},
+				New: map[string]GuildLimitConfig{"123": {User: &LimitConfig{Burst: 1, Interval: time.Minute}}},
+			}},
+		},
+		{
+			name:    "token is rejected",
+			change:  func(cfg *Config) { cfg.Token = "other" },
+			want:    []Change{{Key: "token", Old: logging.Redacted, New: logging.Redacted}},
+			wantErr: ErrNotReloadable,
+		},
+		{
+			name: "shards are rejected with the reloadable changes",
+			change: func(cfg *Config) {
+				cfg.Shards = 4
+				cfg.Embed.Color = 0xffffff
+			},
+			want: []Change{
+				{Key: "shards", Old: 2, New: 4},
+				{Key: "embed.color", Old: 0x7fffff, New: 0xffffff},
+			},
+			wantErr: ErrNotReloadable,
+		},
+		{
+			name:   "no change",
+			change: func(*Config) {},
+			want:   []Change{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			running := runningConfig()
+			applied := 0
+			reloader := NewReloader(running, func(*Config) { applied++ })
+
+			cfg := runningConfig()
+			tt.change(cfg)
+			changes, err := reloader.Reload(cfg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected err to be %v but received %v", tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(changes, tt.want) {
+				t.Errorf("expected %+v but received %+v", tt.want, changes)
+			}
+
+			// a configuration is applied only when it has changes and all of them are reloadable.
+			wantApplied, wantCurrent := 0, running
+			if tt.wantErr == nil && len(tt.want) > 0 {
+				wantApplied, wantCurrent = 1, cfg
+			}
+			if applied != wantApplied {
+				t.Errorf("expected the configuration to be applied %d times but applied %d times", wantApplied, applied)
+			}
+			if reloader.Current() != wantCurrent {
+				t.Errorf("expected the running configuration to be %+v but received %+v", wantCurrent, reloader.Current())
+			}
+		})
+	}
+}
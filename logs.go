@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aqyuki/felm/internal/app"
+	"github.com/aqyuki/felm/pkg/logging"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// newConfiguredLogger returns the logger writing to the outputs of the configuration and sharing levels,
+// and the function closing the log file. The configuration must have been validated.
+func newConfiguredLogger(cfg app.LogConfig, levels *logging.Levels) (*zap.Logger, func() error, error) {
+	closeFile := func() error { return nil }
+	outputs := make([]zapcore.WriteSyncer, 0, 2)
+	if cfg.Output == app.LogOutputStderr || cfg.Output == app.LogOutputBoth {
+		outputs = append(outputs, logging.Stderr())
+	}
+	if cfg.Output == app.LogOutputFile || cfg.Output == app.LogOutputBoth {
+		options := []logging.RotateOption{
+			logging.WithMaxAge(cfg.File.MaxAge),
+			logging.WithMaxBackups(cfg.File.MaxBackups),
+		}
+		if cfg.File.MaxSize > 0 {
+			options = append(options, logging.WithMaxSize(int64(cfg.File.MaxSize)*1024*1024))
+		}
+		file, err := logging.OpenRotatingFile(cfg.File.Path, options...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open the log file (path = %s): %w", cfg.File.Path, err)
+		}
+		outputs = append(outputs, file)
+		closeFile = file.Close
+	}
+
+	logger, _ := logging.NewLeveledLoggerFromEnv(
+		logging.WithLevels(levels),
+		logging.WithOutputs(outputs...),
+		logging.WithSampling(cfg.Sampling.Initial, cfg.Sampling.Thereafter),
+		logging.WithProfile(cfg.Profile()),
+		logging.WithTraceProject(cfg.TraceProject))
+	return logger, closeFile, nil
+}
+
+// newLevelsApplier returns the function applying the levels of a configuration to levels.
+// LOG_LEVEL and LOG_LEVELS, read when levels was created, are kept when the configuration does not set the levels.
+func newLevelsApplier(levels *logging.Levels) func(cfg *app.Config) {
+	envLevel, envOverrides := levels.Level(), levels.Overrides()
+	return func(cfg *app.Config) {
+		level, overrides := envLevel, envOverrides
+		if cfg.Log.Level != "" {
+			level, _ = logging.ParseLevel(cfg.Log.Level)
+		}
+		if cfg.Log.Levels != "" {
+			overrides = cfg.Log.LevelOverrides()
+		}
+		levels.SetLevel(level)
+		levels.SetOverrides(overrides)
+	}
+}
+
+// setUpLogger returns the logger of the configuration, which replaces logger and never logs the secrets,
+// and the function flushing and closing its outputs. The configuration must have been validated.
+func setUpLogger(logger *zap.Logger, cfg *app.Config, levels *logging.Levels) (*zap.Logger, func(), error) {
+	closeOutputs := func() {}
+	// the logger is replaced to write to the configured outputs in the configured format, keeping the levels.
+	if cfg.Log.Output != app.LogOutputStderr || cfg.Log.Sampling.Initial > 0 || cfg.Log.Format != "" || cfg.Log.TraceProject != "" {
+		configured, closeFile, err := newConfiguredLogger(cfg.Log, levels)
+		if err != nil {
+			return nil, nil, err
+		}
+		closeOutputs = func() {
+			_ = configured.Sync()
+			_ = closeFile()
+		}
+		logger = configured
+	}
+	// every log from here on, including the ones of discordgo errors, never contains the token.
+	logger = logging.RedactSecrets(logger, cfg.Secrets()...)
+	if cfg.Log.Privacy.Enabled {
+		logger = logging.ProtectPrivacy(logger, cfg.Log.Privacy.Policy(), cfg.Log.Privacy.Salt)
+		if cfg.Log.Privacy.Salt == "" {
+			logger.Warn("log privacy is enabled without a salt, the hashed user IDs can be recovered by hashing every ID")
+		}
+	}
+	return logger, closeOutputs, nil
+}
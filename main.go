@@ -2,6 +2,8 @@
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
@@ -9,8 +11,12 @@
 
 	"github.com/aqyuki/felm/internal/app"
 	"github.com/aqyuki/felm/internal/app/handler"
+	"github.com/aqyuki/felm/pkg/admin"
 	"github.com/aqyuki/felm/pkg/discord"
 	"github.com/aqyuki/felm/pkg/logging"
+	"github.com/aqyuki/felm/pkg/ratelimit"
+	"github.com/aqyuki/felm/pkg/report"
+	"github.com/aqyuki/felm/pkg/secret"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
@@ -19,62 +25,263 @@
 var rootCmd = &cobra.Command{
 	Use:   "felm",
 	Short: "felm is a discord bot",
-	RunE: func(_ *cobra.Command, _ []string) error {
-		logger := logging.NewLoggerFromEnv()
-		defer logger.Sync()
+	// the config file is read before any command so that `felm config` shows the same configuration as felm.
+	PersistentPreRunE: readConfigFile,
+	SilenceUsage:      true,
+	RunE:              run,
+}
 
-		ctx := logging.WithLogger(context.Background(), logger)
-		ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
-		defer cancel()
+// run sets felm up from the configuration and handles the events until a signal is received or the connection can not recover.
+func run(_ *cobra.Command, _ []string) error {
+	logger, levels := logging.NewLeveledLoggerFromEnv()
+	defer logger.Sync()
+	applyLogLevels := newLevelsApplier(levels)
 
-		logger.Info("felm is starting setup")
+	ctx := logging.WithLogger(context.Background(), logger)
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
 
-		logger.Info("try to load application profile")
-		profile := &app.Profile{
-			Token:   viper.GetString("token"),
-			Timeout: viper.GetDuration("timeout"),
-		}
-		logger.Info("application profile was loaded")
+	logger.Info("felm is starting setup")
 
-		conn := discord.NewConn(profile.Token,
-			discord.WithBaseContext(ctx),
-			discord.WithHandlerTimeout(profile.Timeout),
-			discord.WithMessageCreateHandler(handler.NewCitationService().On),
-		)
+	logger.Info("try to load application config")
+	resolver := secret.NewResolver()
+	cfg, err := app.LoadConfig(ctx, viper.GetViper(), resolver)
+	if err != nil {
+		logger.Error("failed to load application config", zap.Error(err))
+		return err
+	}
+	configured, closeLogger, err := setUpLogger(logger, cfg, levels)
+	if err != nil {
+		logger.Error("failed to set up the log outputs", zap.Error(err))
+		return err
+	}
+	defer closeLogger()
+	logger = configured
+	ctx = logging.WithLogger(ctx, logger)
+	logger.Info("application config was loaded", zap.String("config_file", viper.ConfigFileUsed()))
+	applyLogLevels(cfg)
 
-		logger.Info("starting application")
-		if err := conn.Open(); err != nil {
-			logger.Error("failed to open connection", zap.Error(err))
-			return err
+	backend := newCacheBackend(ctx, cfg)
+	if backend != nil {
+		defer backend.Close()
+	}
+	channelCache := handler.NewChannelCache(cacheOptions(cfg.Cache, backend, "felm:channel:")...)
+	messageCache := handler.NewMessageCache(cacheOptions(cfg.Cache, backend, "felm:message:")...)
+	if cfg.Cache.SnapshotPath != "" {
+		restoreSnapshot(ctx, channelCache, cfg.Cache.SnapshotPath)
+	}
+
+	reporter, err := newErrorReporter(cfg)
+	if err != nil {
+		logger.Error("failed to set up the error reporter", zap.Error(err))
+		return err
+	}
+	// the latest errors are kept for the admin API regardless of the limits of the reporter.
+	history := report.NewHistory(report.DefaultHistorySize)
+	reporter = report.Multi(reporter, history)
+
+	// the alerts are optional, so the detectors are registered only when an operator channel or webhook is set.
+	alerter, err := newAlerter(cfg)
+	if err != nil {
+		logger.Error("failed to set up the alerts", zap.Error(err))
+		return err
+	}
+	alerts := runAlerts(ctx, alerter, cfg.Alert)
+	if alerts.errors != nil {
+		reporter = report.Multi(reporter, alerts.errors)
+	}
+
+	auditSink, err := openAuditSink(ctx, cfg.Audit)
+	if err != nil {
+		logger.Error("failed to open the audit log", zap.String("path", cfg.Audit.Path), zap.Error(err))
+		return err
+	}
+	if auditSink != nil {
+		defer auditSink.Close()
+	}
+
+	limits := ratelimit.NewMemoryStore(cfg.RateLimit.Limits())
+	limits.SetGuildConfigs(cfg.RateLimit.GuildLimits())
+	settings := cfg.CitationSettings()
+	citation := handler.NewCitationService(
+		handler.WithREST(discord.NewREST(alerts.rest...)),
+		handler.WithRateLimiter(ratelimit.New(limits)),
+		handler.WithSuppressedReaction(settings.SuppressedReaction),
+		handler.WithStateFallback(settings.StateFallback),
+		handler.WithEmbedColor(settings.EmbedColor),
+		handler.WithMentionAuthor(settings.MentionAuthor),
+		handler.WithDemotedMessageLogs(settings.DemoteMessageLogs),
+		handler.WithForbiddenHook(alerts.onForbidden),
+		handler.WithAuditSink(auditSink),
+		handler.WithChannelCache(channelCache),
+		handler.WithMessageCache(messageCache),
+	)
+
+	options := connOptions(ctx, cfg, reporter, citation)
+	options = append(options, alerts.conn...)
+	var auditCommand *handler.AuditCommand
+	if auditSink != nil && cfg.Audit.Command {
+		auditCommand = handler.NewAuditCommand(auditSink)
+		options = append(options, discord.WithEventHandler(auditCommand.On))
+	}
+
+	conn := discord.NewConn(cfg.Token, options...)
+	if err := openConn(ctx, conn, cfg.Preflight); err != nil {
+		return err
+	}
+	if alerter != nil && cfg.Alert.Lifecycle {
+		alerter.Notify(startupAlert(len(conn.ShardStatuses())))
+	}
+	if auditCommand != nil {
+		// the audit log works without the command, so a failure does not stop felm.
+		if err := registerCommand(ctx, cfg.Token, auditCommand.ApplicationCommand()); err != nil {
+			logger.Warn("failed to register /felm audit", zap.Error(err))
 		}
+	}
+
+	// the reloadable settings are replaced one by one, each of them atomically, while the connection stays open.
+	reloader := app.NewReloader(cfg, func(cfg *app.Config) {
+		applyLogLevels(cfg)
+		limits.SetDefaults(cfg.RateLimit.Limits())
+		limits.SetGuildConfigs(cfg.RateLimit.GuildLimits())
+		citation.Reconfigure(cfg.CitationSettings())
+		conn.SetPresence(cfg.Presence.Parse(), cfg.Presence.Interval)
+	})
+	go watchConfig(ctx, resolver, reloader)
+	go serveAdmin(ctx, cfg.Admin,
+		admin.WithGateway(conn),
+		admin.WithCache("channel", channelCache),
+		admin.WithCache("message", messageCache),
+		admin.WithConfig(func() any { return configView(reloader.Current()) }),
+		admin.WithErrors(history),
+		admin.WithLevels(levels))
+	if cfg.Log.SummaryInterval > 0 {
+		go citation.LogEventSummary(ctx, cfg.Log.SummaryInterval)
+	}
 
-		<-ctx.Done()
+	// exit with an error when the connection can not recover, so that the orchestrator restarts the process.
+	var fatalErr error
+	select {
+	case <-ctx.Done():
 		logger.Info("signal received, closing application")
+	case fatalErr = <-conn.Fatal():
+		logger.Error("connection can not recover, closing application", zap.Error(fatalErr))
+	}
+
+	// the signal context is already cancelled, so drain the handlers with a fresh deadline.
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.WithoutCancel(ctx), cfg.ShutdownTimeout)
+	defer cancelShutdown()
+	if err := closeConn(shutdownCtx, conn); err != nil {
+		return err
+	}
+	logger.Info("cache statistics",
+		zap.Float64("channel_hit_rate", citation.ChannelCacheStats().HitRate()),
+		zap.Float64("message_hit_rate", citation.MessageCacheStats().HitRate()),
+		zap.Uint64("rest_calls_saved", citation.RESTCallsSaved()))
+	if cfg.Cache.SnapshotPath != "" {
+		saveSnapshot(ctx, channelCache, cfg.Cache.SnapshotPath)
+	}
+	// Run has stopped with the signal context, so the last alert is sent directly.
+	if alerter != nil && cfg.Alert.Lifecycle {
+		if err := alerter.Send(shutdownCtx, shutdownAlert(fatalErr)); err != nil {
+			logger.Warn("failed to send the shutdown alert", zap.Error(err))
+		}
+	}
+	if fatalErr != nil {
+		return fatalErr
+	}
+	logger.Info("application stopped successfully")
+	return nil
+}
+
+// connOptions returns the options of the connection handling the events with citation and reporting their errors to reporter.
+func connOptions(ctx context.Context, cfg *app.Config, reporter report.Reporter, citation *handler.CitationService) []discord.Option {
+	options := []discord.Option{
+		discord.WithBaseContext(ctx),
+		discord.WithErrorReporter(reporter),
+		discord.WithHandlerTimeout(cfg.Timeout),
+		discord.WithShardCount(cfg.Shards),
+		discord.WithIntents(cfg.GatewayIntents()),
+		discord.WithMaxDisconnection(cfg.MaxDisconnection),
+		discord.WithPresence(cfg.Presence.Parse(), cfg.Presence.Interval, func() map[string]any {
+			return map[string]any{"CitationsToday": citation.CitationsToday()}
+		}),
+		discord.WithMessageCreateHandler(citation.On),
+	}
+	return append(options, citation.InvalidationHandlers()...)
+}
 
-		if err := conn.Close(); err != nil {
-			logger.Error("failed to close connection", zap.Error(err))
+// openConn connects to the gateway, running the preflight before when preflight is set. The errors are logged.
+func openConn(ctx context.Context, conn *discord.Conn, preflight bool) error {
+	logger := logging.FromContext(ctx)
+	if preflight {
+		if err := runPreflight(ctx, conn); err != nil {
+			logger.Error("preflight failed", zap.Error(err))
 			return err
 		}
-		logger.Info("application stopped successfully")
-		return nil
-	},
+	}
+
+	logger.Info("starting application")
+	if err := conn.Open(); err != nil {
+		if errors.Is(err, discord.ErrDisallowedIntents) {
+			logger.Error("discord rejected the privileged intents, enable them for the bot or remove them from FELM_INTENTS", zap.Error(err))
+			return err
+		}
+		logger.Error("failed to open connection", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// closeConn closes conn, waiting for the running handlers until ctx is done.
+// Abandoning the handlers which did not finish in time is logged but is not an error.
+func closeConn(ctx context.Context, conn *discord.Conn) error {
+	logger := logging.FromContext(ctx)
+	if err := conn.Shutdown(ctx); err != nil {
+		if errors.Is(err, discord.ErrHandlersAbandoned) {
+			logger.Warn("some handlers did not finish before the shutdown timeout", zap.Error(err))
+			return nil
+		}
+		logger.Error("failed to close connection", zap.Error(err))
+		return err
+	}
+	return nil
 }
 
 func init() {
 	viper.SetDefault("timeout", 5*time.Second)
 
-	rootCmd.PersistentFlags().String("token", "", "token is a Discord bot token. It or FELM_DISCORD_TOKEN is required.")
-	rootCmd.PersistentFlags().Duration("timeout", 5*time.Second, "timeout is a duration for HTTP client timeout. It or FELM_TIMEOUT is optional.")
-
-	if err := viper.BindPFlag("token", rootCmd.PersistentFlags().Lookup("token")); err != nil {
+	app.AddFlags(rootCmd.PersistentFlags())
+	if err := app.Bind(viper.GetViper(), rootCmd.PersistentFlags()); err != nil {
 		panic(err)
 	}
-	if err := viper.BindPFlag("timeout", rootCmd.PersistentFlags().Lookup("timeout")); err != nil {
-		panic(err)
+
+	rootCmd.AddCommand(configCmd, preflightCmd)
+}
+
+// readConfigFile reads the config file given by --config or FELM_CONFIG.
+// Without it, felm.yaml, felm.toml or felm.json is searched in the working directory and /etc/felm,
+// and a missing file is not an error because every key can be set by flags and environment variables.
+func readConfigFile(_ *cobra.Command, _ []string) error {
+	if path := viper.GetString("config"); path != "" {
+		viper.SetConfigFile(path)
+		if err := viper.ReadInConfig(); err != nil {
+			return fmt.Errorf("failed to read the config file (path = %s): %w", path, err)
+		}
+		return nil
 	}
 
-	viper.SetEnvPrefix("felm")
-	viper.AutomaticEnv()
+	viper.SetConfigName("felm")
+	viper.AddConfigPath(".")
+	viper.AddConfigPath("/etc/felm")
+	if err := viper.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to read the config file: %w", err)
+	}
+	return nil
 }
 
 func main() {
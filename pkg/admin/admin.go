@@ -0,0 +1,417 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aqyuki/felm/pkg/cache"
+	"github.com/aqyuki/felm/pkg/discord"
+	"github.com/aqyuki/felm/pkg/logging"
+	"github.com/aqyuki/felm/pkg/report"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// loggerName is the name of the logger of the admin API, whose level can be overridden.
+const loggerName = "admin"
+
+const (
+	// readHeaderTimeout limits how long a client may take to send the headers.
+	readHeaderTimeout = 5 * time.Second
+
+	// shutdownTimeout is how long the running requests are waited for when the server stops.
+	shutdownTimeout = 5 * time.Second
+
+	// maxBodySize is the maximum size of a request body.
+	maxBodySize = 64 * 1024
+)
+
+// Gateway is the connection to Discord to be inspected, which *discord.Conn implements.
+type Gateway interface {
+	Guilds() []discord.Guild
+	ShardStatuses() []discord.ShardStatus
+	InflightHandlers() int
+}
+
+// Cache is a cache to be inspected and purged, which *cache.Cache implements.
+type Cache interface {
+	Keys() []string
+	Stats() cache.Stats
+	Shared() bool
+	Delete(key string)
+	Purge() error
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithGateway exposes the guilds, the shards and the running handlers of the gateway.
+func WithGateway(gateway Gateway) Option {
+	return func(s *Server) {
+		s.gateway = gateway
+	}
+}
+
+// WithCache exposes the cache under the name, e.g. channel.
+func WithCache(name string, c Cache) Option {
+	return func(s *Server) {
+		if c != nil {
+			s.caches[name] = c
+		}
+	}
+}
+
+// WithConfig exposes the configuration returned by fn, which must not contain secrets.
+func WithConfig(fn func() any) Option {
+	return func(s *Server) {
+		s.config = fn
+	}
+}
+
+// WithErrors exposes the latest errors kept by the history.
+func WithErrors(history *report.History) Option {
+	return func(s *Server) {
+		s.errors = history
+	}
+}
+
+// WithLevels exposes the log levels and allows to change them.
+func WithLevels(levels *logging.Levels) Option {
+	return func(s *Server) {
+		s.levels = levels
+	}
+}
+
+// Server is the HTTP API to inspect a running felm. Every request must have the token as a bearer token.
+type Server struct {
+	token   string
+	mux     *http.ServeMux
+	gateway Gateway
+	caches  map[string]Cache
+	config  func() any
+	errors  *report.History
+	levels  *logging.Levels
+}
+
+// New creates the server accepting the requests with the token. An empty token rejects every request.
+func New(token string, option ...Option) *Server {
+	s := &Server{
+		token:  token,
+		mux:    http.NewServeMux(),
+		caches: make(map[string]Cache),
+	}
+	for _, opt := range option {
+		opt(s)
+	}
+
+	if s.gateway != nil {
+		s.mux.HandleFunc("GET /guilds", s.handleGuilds)
+		s.mux.HandleFunc("GET /shards", s.handleShards)
+		s.mux.HandleFunc("GET /handlers", s.handleHandlers)
+	}
+	s.mux.HandleFunc("GET /caches", s.handleCaches)
+	s.mux.HandleFunc("GET /caches/{name}", s.handleCache)
+	s.mux.HandleFunc("DELETE /caches/{name}", s.handlePurgeCache)
+	s.mux.HandleFunc("DELETE /caches/{name}/{key...}", s.handleDeleteCacheEntry)
+	if s.config != nil {
+		s.mux.HandleFunc("GET /config", s.handleConfig)
+	}
+	if s.errors != nil {
+		s.mux.HandleFunc("GET /errors", s.handleErrors)
+	}
+	if s.levels != nil {
+		s.mux.HandleFunc("GET /log/level", s.handleLevels)
+		s.mux.HandleFunc("PUT /log/level", s.handleSetLevels)
+	}
+	return s
+}
+
+// ServeHTTP authenticates the request and serves it.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if s.token == "" || !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="felm"`)
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe serves the API on the address until ctx is done.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	logger := logging.Named(ctx, loggerName)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on the admin address (address = %s): %w", addr, err)
+	}
+	server := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: readHeaderTimeout,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+		ErrorLog:          zap.NewStdLog(logger),
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info("admin API is listening", zap.String("address", listener.Addr().String()))
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve the admin API: %w", err)
+	}
+	return nil
+}
+
+type guildResponse struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	ShardID     int    `json:"shard_id"`
+	MemberCount int    `json:"member_count"`
+	Unavailable bool   `json:"unavailable"`
+}
+
+func (s *Server) handleGuilds(w http.ResponseWriter, _ *http.Request) {
+	guilds := s.gateway.Guilds()
+	resp := make([]guildResponse, 0, len(guilds))
+	for _, g := range guilds {
+		resp = append(resp, guildResponse(g))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+type shardResponse struct {
+	ID         int       `json:"id"`
+	State      string    `json:"state"`
+	Guilds     int       `json:"guilds"`
+	Reconnects int       `json:"reconnects"`
+	Since      time.Time `json:"since"`
+}
+
+func (s *Server) handleShards(w http.ResponseWriter, _ *http.Request) {
+	statuses := s.gateway.ShardStatuses()
+	resp := make([]shardResponse, 0, len(statuses))
+	for _, status := range statuses {
+		resp = append(resp, shardResponse{
+			ID:         status.ID,
+			State:      string(status.State),
+			Guilds:     status.Guilds,
+			Reconnects: status.Reconnects,
+			Since:      status.Since,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+type handlersResponse struct {
+	Inflight int `json:"inflight"`
+}
+
+func (s *Server) handleHandlers(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, handlersResponse{Inflight: s.gateway.InflightHandlers()})
+}
+
+type cacheResponse struct {
+	Name         string  `json:"name"`
+	Entries      int     `json:"entries"`
+	Hits         uint64  `json:"hits"`
+	Misses       uint64  `json:"misses"`
+	HitRate      float64 `json:"hit_rate"`
+	Evictions    uint64  `json:"evictions"`
+	NegativeHits uint64  `json:"negative_hits"`
+	Loads        uint64  `json:"loads"`
+	Coalesced    uint64  `json:"coalesced"`
+	RemoteHits   uint64  `json:"remote_hits"`
+	RemoteErrors uint64  `json:"remote_errors"`
+	// Shared is whether the entries are shared through a backend, in which case the entries and the keys
+	// are only the ones held in the memory of this process.
+	Shared bool     `json:"shared"`
+	Keys   []string `json:"keys,omitempty"`
+}
+
+func newCacheResponse(name string, c Cache) cacheResponse {
+	stats := c.Stats()
+	return cacheResponse{
+		Name:         name,
+		Shared:       c.Shared(),
+		Entries:      stats.Entries,
+		Hits:         stats.Hits,
+		Misses:       stats.Misses,
+		HitRate:      stats.HitRate(),
+		Evictions:    stats.Evictions,
+		NegativeHits: stats.NegativeHits,
+		Loads:        stats.Loads,
+		Coalesced:    stats.Coalesced,
+		RemoteHits:   stats.RemoteHits,
+		RemoteErrors: stats.RemoteErrors,
+	}
+}
+
+func (s *Server) handleCaches(w http.ResponseWriter, _ *http.Request) {
+	names := make([]string, 0, len(s.caches))
+	for name := range s.caches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resp := make([]cacheResponse, 0, len(names))
+	for _, name := range names {
+		resp = append(resp, newCacheResponse(name, s.caches[name]))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleCache(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	c, ok := s.caches[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown cache: "+name)
+		return
+	}
+	resp := newCacheResponse(name, c)
+	resp.Keys = c.Keys()
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handlePurgeCache(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	c, ok := s.caches[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown cache: "+name)
+		return
+	}
+	if err := c.Purge(); err != nil {
+		logging.Named(r.Context(), loggerName).Warn("cache was purged from memory only", zap.String("cache", name), zap.Error(err))
+		writeError(w, http.StatusBadGateway, "the cache was purged from memory, but not from the shared backend: "+err.Error())
+		return
+	}
+	logging.Named(r.Context(), loggerName).Info("cache was purged", zap.String("cache", name))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleDeleteCacheEntry(w http.ResponseWriter, r *http.Request) {
+	name, key := r.PathValue("name"), r.PathValue("key")
+	c, ok := s.caches[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown cache: "+name)
+		return
+	}
+	c.Delete(key)
+	logging.Named(r.Context(), loggerName).Info("cache entry was deleted", zap.String("cache", name), zap.String("key", key))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleConfig(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.config())
+}
+
+type errorEventResponse struct {
+	Time        time.Time      `json:"time"`
+	Source      string         `json:"source"`
+	TraceID     string         `json:"trace_id"`
+	Message     string         `json:"message"`
+	Code        string         `json:"code,omitempty"`
+	Context     map[string]any `json:"context,omitempty"`
+	Fingerprint string         `json:"fingerprint"`
+}
+
+func (s *Server) handleErrors(w http.ResponseWriter, _ *http.Request) {
+	events := s.errors.Events()
+	resp := make([]errorEventResponse, 0, len(events))
+	for _, event := range events {
+		resp = append(resp, errorEventResponse{
+			Time:        event.Time,
+			Source:      event.Source,
+			TraceID:     event.TraceID,
+			Message:     event.Message,
+			Code:        event.Code,
+			Context:     event.Context,
+			Fingerprint: event.Fingerprint,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// levelsRequest changes the level and replaces the overrides when they are set.
+type levelsRequest struct {
+	Level     *string           `json:"level"`
+	Overrides map[string]string `json:"overrides"`
+}
+
+type levelsResponse struct {
+	Level     string            `json:"level"`
+	Overrides map[string]string `json:"overrides"`
+}
+
+func (s *Server) handleLevels(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.levelsResponse())
+}
+
+func (s *Server) handleSetLevels(w http.ResponseWriter, r *http.Request) {
+	var req levelsRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body: "+err.Error())
+		return
+	}
+
+	// every level is parsed before any is changed, so that an invalid request changes nothing.
+	level := s.levels.Level()
+	if req.Level != nil {
+		parsed, ok := logging.ParseLevel(*req.Level)
+		if !ok {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown level: %q", *req.Level))
+			return
+		}
+		level = parsed
+	}
+	overrides := make(map[string]zapcore.Level, len(req.Overrides))
+	for name, value := range req.Overrides {
+		parsed, ok := logging.ParseLevel(value)
+		if !ok {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown level of %s: %q", name, value))
+			return
+		}
+		overrides[name] = parsed
+	}
+
+	s.levels.SetLevel(level)
+	if req.Overrides != nil {
+		s.levels.SetOverrides(overrides)
+	}
+	resp := s.levelsResponse()
+	logging.Named(r.Context(), loggerName).Info("log levels were changed", zap.String("level", resp.Level), zap.Any("overrides", resp.Overrides))
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) levelsResponse() levelsResponse {
+	overrides := s.levels.Overrides()
+	resp := levelsResponse{Level: logging.LevelName(s.levels.Level()), Overrides: make(map[string]string, len(overrides))}
+	for name, level := range overrides {
+		resp.Overrides[name] = logging.LevelName(level)
+	}
+	return resp
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,209 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aqyuki/felm/pkg/cache"
+	"github.com/aqyuki/felm/pkg/discord"
+	"github.com/aqyuki/felm/pkg/logging"
+	"github.com/aqyuki/felm/pkg/report"
+	"go.uber.org/zap/zapcore"
+)
+
+type fakeGateway struct{}
+
+func (fakeGateway) Guilds() []discord.Guild {
+	return []discord.Guild{{ID: "1", Name: "guild", ShardID: 0, MemberCount: 2}}
+}
+
+func (fakeGateway) ShardStatuses() []discord.ShardStatus {
+	return []discord.ShardStatus{{ID: 0, State: discord.ShardConnected, Guilds: 1}}
+}
+
+func (fakeGateway) InflightHandlers() int {
+	return 3
+}
+
+func do(t *testing.T, handler http.Handler, method, path, body string, v any) int {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if v != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("expected a JSON body but received %q: %v", rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+func TestServerAuthentication(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		want   int
+	}{
+		{"valid token", "secret", "Bearer secret", http.StatusOK},
+		{"wrong token", "secret", "Bearer wrong", http.StatusUnauthorized},
+		{"no header", "secret", "", http.StatusUnauthorized},
+		{"not a bearer token", "secret", "secret", http.StatusUnauthorized},
+		{"empty token rejects everything", "", "Bearer ", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := New(tt.token)
+			req := httptest.NewRequest(http.MethodGet, "/caches", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("expected status %d but received %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
+
+func TestServerGateway(t *testing.T) {
+	t.Parallel()
+
+	server := New("secret", WithGateway(fakeGateway{}))
+
+	var guilds []guildResponse
+	if code := do(t, server, http.MethodGet, "/guilds", "", &guilds); code != http.StatusOK || len(guilds) != 1 || guilds[0].Name != "guild" {
+		t.Errorf("expected the guilds but received %d %+v", code, guilds)
+	}
+	var shards []shardResponse
+	if code := do(t, server, http.MethodGet, "/shards", "", &shards); code != http.StatusOK || len(shards) != 1 || shards[0].State != "connected" {
+		t.Errorf("expected the shards but received %d %+v", code, shards)
+	}
+	var handlers handlersResponse
+	if code := do(t, server, http.MethodGet, "/handlers", "", &handlers); code != http.StatusOK || handlers.Inflight != 3 {
+		t.Errorf("expected 3 handlers in flight but received %d %+v", code, handlers)
+	}
+}
+
+func TestServerCaches(t *testing.T) {
+	t.Parallel()
+
+	channels := cache.New[int](time.Minute)
+	_ = channels.Set("guild/a", 1)
+	_ = channels.Set("guild/b", 2)
+	server := New("secret", WithCache("channel", channels))
+
+	var caches []cacheResponse
+	if code := do(t, server, http.MethodGet, "/caches", "", &caches); code != http.StatusOK || len(caches) != 1 || caches[0].Entries != 2 {
+		t.Errorf("expected the stats of the cache but received %d %+v", code, caches)
+	}
+	var detail cacheResponse
+	if code := do(t, server, http.MethodGet, "/caches/channel", "", &detail); code != http.StatusOK || strings.Join(detail.Keys, ",") != "guild/a,guild/b" {
+		t.Errorf("expected the keys of the cache but received %d %+v", code, detail)
+	}
+	if code := do(t, server, http.MethodGet, "/caches/unknown", "", nil); code != http.StatusNotFound {
+		t.Errorf("expected status %d but received %d", http.StatusNotFound, code)
+	}
+
+	if code := do(t, server, http.MethodDelete, "/caches/channel/guild/a", "", nil); code != http.StatusNoContent || strings.Join(channels.Keys(), ",") != "guild/b" {
+		t.Errorf("expected the entry to be deleted but received %d with %v", code, channels.Keys())
+	}
+	if code := do(t, server, http.MethodDelete, "/caches/channel", "", nil); code != http.StatusNoContent || channels.Len() != 0 {
+		t.Errorf("expected the cache to be purged but received %d with %d entries", code, channels.Len())
+	}
+}
+
+// unreachableBackend fails to delete the entries as if the server were down.
+type unreachableBackend struct {
+	*cache.MemoryBackend
+}
+
+func (unreachableBackend) DeletePrefix(context.Context, string) (int, error) {
+	return 0, errors.New("unreachable")
+}
+
+func TestServerSharedCaches(t *testing.T) {
+	t.Parallel()
+
+	backend := cache.NewMemoryBackend()
+	channels := cache.New[int](time.Minute, cache.WithBackend(cache.PrefixBackend(backend, "channel:"), cache.JSONCodec{}))
+	_ = channels.Set("a", 1)
+	messages := cache.New[int](time.Minute, cache.WithBackend(unreachableBackend{cache.NewMemoryBackend()}, cache.JSONCodec{}))
+	_ = messages.Set("a", 1)
+	server := New("secret", WithCache("channel", channels), WithCache("message", messages))
+
+	var detail cacheResponse
+	if code := do(t, server, http.MethodGet, "/caches/channel", "", &detail); code != http.StatusOK || !detail.Shared {
+		t.Errorf("expected the cache to be reported as shared but received %d %+v", code, detail)
+	}
+
+	if code := do(t, server, http.MethodDelete, "/caches/channel", "", nil); code != http.StatusNoContent {
+		t.Errorf("expected status %d but received %d", http.StatusNoContent, code)
+	}
+	if _, _, err := backend.Get(context.Background(), "channel:a"); !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("expected the entry to be purged from the backend but received %v", err)
+	}
+
+	if code := do(t, server, http.MethodDelete, "/caches/message", "", nil); code != http.StatusBadGateway || messages.Len() != 0 {
+		t.Errorf("expected the failure of the backend to be reported but received %d with %d entries", code, messages.Len())
+	}
+}
+
+func TestServerConfigAndErrors(t *testing.T) {
+	t.Parallel()
+
+	history := report.NewHistory(10)
+	_ = history.Report(context.Background(), report.NewEvent("MessageCreate", "trace", errors.New("failed")))
+	server := New("secret",
+		WithConfig(func() any { return map[string]string{"token": "[REDACTED]"} }),
+		WithErrors(history))
+
+	var config map[string]string
+	if code := do(t, server, http.MethodGet, "/config", "", &config); code != http.StatusOK || config["token"] != "[REDACTED]" {
+		t.Errorf("expected the config but received %d %+v", code, config)
+	}
+	var events []errorEventResponse
+	if code := do(t, server, http.MethodGet, "/errors", "", &events); code != http.StatusOK || len(events) != 1 || events[0].Message != "failed" {
+		t.Errorf("expected the recent errors but received %d %+v", code, events)
+	}
+}
+
+func TestServerLevels(t *testing.T) {
+	t.Parallel()
+
+	levels := logging.NewLevels(zapcore.InfoLevel)
+	levels.SetOverride("cache", zapcore.WarnLevel)
+	server := New("secret", WithLevels(levels))
+
+	var resp levelsResponse
+	if code := do(t, server, http.MethodGet, "/log/level", "", &resp); code != http.StatusOK || resp.Level != "info" || resp.Overrides["cache"] != "warning" {
+		t.Errorf("expected the levels but received %d %+v", code, resp)
+	}
+
+	code := do(t, server, http.MethodPut, "/log/level", `{"level": "debug"}`, &resp)
+	if code != http.StatusOK || levels.Level() != zapcore.DebugLevel || levels.LevelOf("cache") != zapcore.WarnLevel {
+		t.Errorf("expected only the level to be changed but received %d %+v", code, resp)
+	}
+	code = do(t, server, http.MethodPut, "/log/level", `{"overrides": {"discord": "error"}}`, &resp)
+	if code != http.StatusOK || levels.LevelOf("discord") != zapcore.ErrorLevel || levels.LevelOf("cache") != zapcore.DebugLevel {
+		t.Errorf("expected the overrides to be replaced but received %d %+v", code, resp)
+	}
+
+	code = do(t, server, http.MethodPut, "/log/level", `{"level": "error", "overrides": {"discord": "verbose"}}`, nil)
+	if code != http.StatusBadRequest || levels.Level() != zapcore.DebugLevel {
+		t.Errorf("expected an invalid request to change nothing but received %d", code)
+	}
+}
@@ -0,0 +1,210 @@
+package alert
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aqyuki/felm/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// loggerName is the name of the logger of the alerts, whose level can be overridden.
+const loggerName = "alert"
+
+const (
+	// DefaultCooldown is the duration after an alert during which the same alert is not sent again.
+	DefaultCooldown = 30 * time.Minute
+
+	// queueSize is the number of alerts waiting to be sent. Alerts are dropped when it is full.
+	queueSize = 32
+)
+
+// Kind is the kind of the event an alert is sent for.
+type Kind string
+
+const (
+	KindStartup          Kind = "startup"
+	KindShutdown         Kind = "shutdown"
+	KindHandlerErrors    Kind = "handler_errors"
+	KindDisconnects      Kind = "disconnects"
+	KindRateLimits       Kind = "rate_limits"
+	KindPermissionDenied Kind = "permission_denied"
+)
+
+// Severity is how urgent an alert is.
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+// Alert is a notable event to be sent to the operators.
+type Alert struct {
+	Kind Kind
+
+	// Key distinguishes the alerts of the same kind which have their own cooldown, e.g. the guild ID.
+	Key string
+
+	Severity    Severity
+	Title       string
+	Description string
+	Fields      []Field
+	Time        time.Time
+
+	// Suppressed is the number of the same alerts which were not sent during the cooldown.
+	Suppressed int
+}
+
+// Field is a named value shown in an alert.
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Sender sends the alerts to the operators, e.g. to a channel.
+type Sender interface {
+	Send(ctx context.Context, alert Alert) error
+}
+
+// SenderFunc is a function which implements Sender.
+type SenderFunc func(ctx context.Context, alert Alert) error
+
+func (f SenderFunc) Send(ctx context.Context, alert Alert) error {
+	return f(ctx, alert)
+}
+
+// Option configures an Alerter.
+type Option func(*Alerter)
+
+// WithCooldown sets the cooldown of the alerts of every kind without its own cooldown.
+func WithCooldown(cooldown time.Duration) Option {
+	return func(a *Alerter) {
+		if cooldown >= 0 {
+			a.cooldown = cooldown
+		}
+	}
+}
+
+// WithKindCooldown sets the cooldown of the alerts of the kind.
+func WithKindCooldown(kind Kind, cooldown time.Duration) Option {
+	return func(a *Alerter) {
+		if cooldown >= 0 {
+			a.cooldowns[kind] = cooldown
+		}
+	}
+}
+
+// withClock sets the clock of the cooldowns. It is used in tests.
+func withClock(now func() time.Time) Option {
+	return func(a *Alerter) {
+		a.now = now
+	}
+}
+
+// Alerter sends the alerts, skipping the ones whose kind and key are in their cooldown.
+type Alerter struct {
+	sender    Sender
+	cooldown  time.Duration
+	cooldowns map[Kind]time.Duration
+	now       func() time.Time
+	queue     chan Alert
+
+	mu sync.Mutex
+
+	// sent is the last time the alerts were sent by kind and key, with the number of alerts suppressed since then.
+	sent map[cooldownKey]*cooldownState
+}
+
+type cooldownKey struct {
+	kind Kind
+	key  string
+}
+
+type cooldownState struct {
+	sent       time.Time
+	suppressed int
+}
+
+// New creates the alerter sending the alerts with sender.
+func New(sender Sender, option ...Option) *Alerter {
+	a := &Alerter{
+		sender:    sender,
+		cooldown:  DefaultCooldown,
+		cooldowns: make(map[Kind]time.Duration),
+		now:       time.Now,
+		queue:     make(chan Alert, queueSize),
+		sent:      make(map[cooldownKey]*cooldownState),
+	}
+	for _, opt := range option {
+		opt(a)
+	}
+	return a
+}
+
+// Notify queues the alert to be sent by Run unless it is in its cooldown. It never blocks,
+// so it can be called from the handlers of the gateway. It reports whether the alert was queued.
+func (a *Alerter) Notify(alert Alert) bool {
+	alert, ok := a.allow(alert)
+	if !ok {
+		return false
+	}
+	select {
+	case a.queue <- alert:
+		return true
+	default:
+		return false
+	}
+}
+
+// Send sends the alert now unless it is in its cooldown, e.g. on shutdown after Run has stopped.
+func (a *Alerter) Send(ctx context.Context, alert Alert) error {
+	alert, ok := a.allow(alert)
+	if !ok {
+		return nil
+	}
+	return a.sender.Send(ctx, alert)
+}
+
+// Run sends the queued alerts until ctx is done. The alerts which can not be sent are logged.
+func (a *Alerter) Run(ctx context.Context) {
+	logger := logging.Named(ctx, loggerName)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case alert := <-a.queue:
+			if err := a.sender.Send(ctx, alert); err != nil {
+				logger.Warn("failed to send the alert", zap.String("kind", string(alert.Kind)), zap.String("key", alert.Key), zap.Error(err))
+			}
+		}
+	}
+}
+
+// allow reports whether the alert is out of its cooldown, and returns it with the time and the number of suppressed alerts.
+func (a *Alerter) allow(alert Alert) (Alert, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	cooldown, ok := a.cooldowns[alert.Kind]
+	if !ok {
+		cooldown = a.cooldown
+	}
+	key := cooldownKey{kind: alert.Kind, key: alert.Key}
+	state, ok := a.sent[key]
+	if ok && now.Sub(state.sent) < cooldown {
+		state.suppressed++
+		return alert, false
+	}
+	if ok {
+		alert.Suppressed = state.suppressed
+	}
+	a.sent[key] = &cooldownState{sent: now}
+	if alert.Time.IsZero() {
+		alert.Time = now
+	}
+	return alert, true
+}
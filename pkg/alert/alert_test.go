@@ -0,0 +1,183 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aqyuki/felm/pkg/discord"
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestAlerterCooldown(t *testing.T) {
+	t.Parallel()
+
+	sent := make([]Alert, 0)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	alerter := New(SenderFunc(func(_ context.Context, alert Alert) error {
+		sent = append(sent, alert)
+		return nil
+	}), WithCooldown(time.Hour), WithKindCooldown(KindStartup, 0), withClock(func() time.Time { return now }))
+
+	ctx := context.Background()
+	for _, alert := range []Alert{
+		{Kind: KindPermissionDenied, Key: "1"},
+		{Kind: KindPermissionDenied, Key: "1"}, // in the cooldown
+		{Kind: KindPermissionDenied, Key: "1"}, // in the cooldown
+		{Kind: KindPermissionDenied, Key: "2"}, // another guild has its own cooldown
+		{Kind: KindStartup},
+		{Kind: KindStartup}, // the kind has no cooldown
+	} {
+		_ = alerter.Send(ctx, alert)
+	}
+	if len(sent) != 4 {
+		t.Fatalf("expected 4 alerts but received %d", len(sent))
+	}
+
+	now = now.Add(time.Hour)
+	_ = alerter.Send(ctx, Alert{Kind: KindPermissionDenied, Key: "1"})
+	if len(sent) != 5 || sent[4].Suppressed != 2 {
+		t.Errorf("expected the alert with 2 suppressed alerts but received %+v", sent[len(sent)-1])
+	}
+	if !sent[4].Time.Equal(now) {
+		t.Errorf("expected the time of the alert to be set but received %s", sent[4].Time)
+	}
+}
+
+func TestAlerterRun(t *testing.T) {
+	t.Parallel()
+
+	sent := make(chan Alert, 1)
+	alerter := New(SenderFunc(func(_ context.Context, alert Alert) error {
+		sent <- alert
+		return nil
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go alerter.Run(ctx)
+
+	if !alerter.Notify(Alert{Kind: KindDisconnects, Title: "disconnected"}) {
+		t.Fatal("expected the alert to be queued")
+	}
+	select {
+	case alert := <-sent:
+		if alert.Title != "disconnected" {
+			t.Errorf("expected the queued alert but received %+v", alert)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the alert to be sent")
+	}
+	if alerter.Notify(Alert{Kind: KindDisconnects}) {
+		t.Error("expected the alert in the cooldown not to be queued")
+	}
+}
+
+func TestSpike(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	spike := NewSpike(3, time.Minute)
+	spike.now = func() time.Time { return now }
+
+	spike.Add("a")
+	spike.Add("a")
+	spike.Add("b")
+	now = now.Add(time.Minute)
+	if _, ok := spike.Add("a"); ok {
+		t.Error("expected the events older than the window to be forgotten")
+	}
+	spike.Add("a")
+	if n, ok := spike.Add("a"); !ok || n != 3 {
+		t.Errorf("expected a spike of 3 events but received %d, %v", n, ok)
+	}
+	if _, ok := spike.Add("a"); ok {
+		t.Error("expected the events to be counted from zero after a spike")
+	}
+
+	if _, ok := NewSpike(0, time.Minute).Add("a"); ok {
+		t.Error("expected a threshold of 0 never to detect a spike")
+	}
+}
+
+func TestWebhookSend(t *testing.T) {
+	t.Parallel()
+
+	var params discordgo.WebhookParams
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&params)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	err := NewWebhook(server.URL, 0).Send(context.Background(), Alert{
+		Kind:       KindRateLimits,
+		Severity:   SeverityCritical,
+		Title:      "rate limited",
+		Fields:     []Field{{Name: "Last URL", Value: "/channels/1/messages"}},
+		Suppressed: 2,
+		Time:       time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+	if len(params.Embeds) != 1 {
+		t.Fatalf("expected 1 embed but received %d", len(params.Embeds))
+	}
+	embed := params.Embeds[0]
+	if embed.Title != "rate limited" || embed.Color != severityColors[SeverityCritical] || len(embed.Fields) != 2 {
+		t.Errorf("expected the alert in the embed but received %+v", embed)
+	}
+}
+
+// rateLimitedTransport answers every request with 429 as Discord does when the bot is rate limited.
+type rateLimitedTransport struct{}
+
+func (rateLimitedTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Status:     "429 Too Many Requests",
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"message":"You are being rate limited.","retry_after":1.5,"global":false}`)),
+		Request:    r,
+	}, nil
+}
+
+func TestRateLimitDetector(t *testing.T) {
+	t.Parallel()
+
+	sent := make(chan Alert, 1)
+	alerter := New(SenderFunc(func(_ context.Context, alert Alert) error {
+		sent <- alert
+		return nil
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go alerter.Run(ctx)
+
+	session, err := discordgo.New("Bot token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Client = &http.Client{Transport: rateLimitedTransport{}}
+	rest := discord.NewREST(discord.WithMaxAttempts(1), discord.WithRateLimitHook(alerter.RateLimits(2, time.Minute).OnREST))
+
+	for i := 0; i < 2; i++ {
+		if _, err := rest.Channel(ctx, session, "1"); !errors.Is(err, discord.ErrRateLimited) {
+			t.Fatalf("expected err to be %v but received %v", discord.ErrRateLimited, err)
+		}
+	}
+	select {
+	case alert := <-sent:
+		if alert.Kind != KindRateLimits || len(alert.Fields) != 2 || !strings.HasSuffix(alert.Fields[0].Value, "/channels/1") || alert.Fields[1].Value != "1.5s" {
+			t.Errorf("expected the alert of the rate limits but received %+v", alert)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the rate limits of the REST calls to be alerted")
+	}
+}
@@ -0,0 +1,149 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aqyuki/felm/pkg/discord"
+	"github.com/aqyuki/felm/pkg/report"
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxDescriptionLength is the length of the descriptions taken from errors, which Discord limits to 4096.
+const maxDescriptionLength = 1000
+
+// HandlerErrors returns the reporter alerting when the handlers return threshold errors within the window.
+func (a *Alerter) HandlerErrors(threshold int, window time.Duration) report.Reporter {
+	spike := NewSpike(threshold, window)
+	return report.ReporterFunc(func(_ context.Context, event report.Event) error {
+		if n, ok := spike.Add(""); ok {
+			a.Notify(Alert{
+				Kind:        KindHandlerErrors,
+				Severity:    SeverityWarning,
+				Title:       "Handlers are failing repeatedly",
+				Description: fmt.Sprintf("%d errors within %s. The last one is:\n%s", n, window, truncate(event.Message, maxDescriptionLength)),
+				Fields: []Field{
+					{Name: "Event", Value: event.Source},
+					{Name: "Trace ID", Value: orNone(event.TraceID)},
+				},
+			})
+		}
+		return nil
+	})
+}
+
+// Disconnects returns the lifecycle handler alerting when the shards are disconnected threshold times within the window.
+func (a *Alerter) Disconnects(threshold int, window time.Duration) discord.LifecycleHandler {
+	spike := NewSpike(threshold, window)
+	return func(event discord.LifecycleEvent) {
+		if event.Type != discord.LifecycleDisconnected {
+			return
+		}
+		n, ok := spike.Add("")
+		if !ok {
+			return
+		}
+		fields := []Field{{Name: "Last shard", Value: strconv.Itoa(event.ShardID)}}
+		if event.CloseCode != 0 {
+			fields = append(fields, Field{Name: "Close code", Value: strconv.Itoa(event.CloseCode)})
+		}
+		if event.Err != nil {
+			fields = append(fields, Field{Name: "Error", Value: truncate(event.Err.Error(), maxDescriptionLength)})
+		}
+		a.Notify(Alert{
+			Kind:        KindDisconnects,
+			Severity:    SeverityCritical,
+			Title:       "Gateway is disconnecting repeatedly",
+			Description: fmt.Sprintf("The shards were disconnected %d times within %s.", n, window),
+			Fields:      fields,
+		})
+	}
+}
+
+// RateLimitDetector alerts when Discord rate limits threshold requests within the window.
+// The requests of discord.REST are reported by OnREST, and the ones retried by discordgo itself by OnEvent.
+type RateLimitDetector struct {
+	alerter *Alerter
+	spike   *Spike
+	window  time.Duration
+}
+
+// RateLimits returns the detector of the rate limits.
+func (a *Alerter) RateLimits(threshold int, window time.Duration) *RateLimitDetector {
+	return &RateLimitDetector{alerter: a, spike: NewSpike(threshold, window), window: window}
+}
+
+// OnREST is the hook given to discord.WithRateLimitHook.
+func (d *RateLimitDetector) OnREST(err error) {
+	var rateLimitErr *discordgo.RateLimitError
+	if errors.As(err, &rateLimitErr) && rateLimitErr.RateLimit != nil {
+		d.add(rateLimitErr.RateLimit)
+		return
+	}
+	d.add(&discordgo.RateLimit{})
+}
+
+// OnEvent is the handler of the *discordgo.RateLimit events.
+func (d *RateLimitDetector) OnEvent(_ context.Context, _ *discordgo.Session, event *discordgo.RateLimit) error {
+	d.add(event)
+	return nil
+}
+
+func (d *RateLimitDetector) add(rateLimit *discordgo.RateLimit) {
+	n, ok := d.spike.Add("")
+	if !ok {
+		return
+	}
+	fields := []Field{{Name: "Last URL", Value: orNone(rateLimit.URL)}}
+	if rateLimit.TooManyRequests != nil {
+		fields = append(fields, Field{Name: "Retry after", Value: rateLimit.TooManyRequests.RetryAfter.String()})
+	}
+	d.alerter.Notify(Alert{
+		Kind:        KindRateLimits,
+		Severity:    SeverityWarning,
+		Title:       "Discord is rate limiting the bot",
+		Description: fmt.Sprintf("%d requests were rate limited within %s.", n, d.window),
+		Fields:      fields,
+	})
+}
+
+// PermissionDenied returns the function to be called when the bot is not allowed to reply in a channel,
+// alerting when it happens threshold times in a guild within the window. Each guild has its own cooldown.
+func (a *Alerter) PermissionDenied(threshold int, window time.Duration) func(guildID, channelID string) {
+	spike := NewSpike(threshold, window)
+	return func(guildID, channelID string) {
+		n, ok := spike.Add(guildID)
+		if !ok {
+			return
+		}
+		a.Notify(Alert{
+			Kind:        KindPermissionDenied,
+			Key:         guildID,
+			Severity:    SeverityWarning,
+			Title:       "Bot lacks permissions in a guild",
+			Description: fmt.Sprintf("The bot was not allowed to reply %d times within %s.", n, window),
+			Fields: []Field{
+				{Name: "Guild ID", Value: guildID},
+				{Name: "Last channel ID", Value: channelID},
+			},
+		})
+	}
+}
+
+// truncate returns s shortened to n characters.
+func truncate(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n-1]) + "…"
+	}
+	return s
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
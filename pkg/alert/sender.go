@@ -0,0 +1,107 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// DefaultHTTPTimeout is the timeout of the requests to the webhook.
+const DefaultHTTPTimeout = 10 * time.Second
+
+// severityColors are the colors of the embeds by severity.
+var severityColors = map[Severity]int{
+	SeverityInfo:     0x3498db,
+	SeverityWarning:  0xf1c40f,
+	SeverityCritical: 0xe74c3c,
+}
+
+// Webhook sends the alerts to a Discord webhook, e.g. of a channel only the operators can read.
+type Webhook struct {
+	client *http.Client
+	url    string
+}
+
+// NewWebhook creates the sender posting the alerts to the URL of the webhook with the timeout. 0 uses DefaultHTTPTimeout.
+func NewWebhook(url string, timeout time.Duration) *Webhook {
+	if timeout <= 0 {
+		timeout = DefaultHTTPTimeout
+	}
+	return &Webhook{client: &http.Client{Timeout: timeout}, url: url}
+}
+
+func (w *Webhook) Send(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(discordgo.WebhookParams{
+		Username:        "felm",
+		Embeds:          []*discordgo.MessageEmbed{newEmbed(alert)},
+		AllowedMentions: &discordgo.MessageAllowedMentions{Parse: []discordgo.AllowedMentionType{}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode the webhook message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create the webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send the alert to the webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to send the alert to the webhook: %s", resp.Status)
+	}
+	return nil
+}
+
+// Channel sends the alerts to a channel with the bot.
+type Channel struct {
+	session   *discordgo.Session
+	channelID string
+}
+
+// NewChannel creates the sender posting the alerts to the channel. session is only used to call the REST API.
+func NewChannel(session *discordgo.Session, channelID string) *Channel {
+	return &Channel{session: session, channelID: channelID}
+}
+
+func (c *Channel) Send(ctx context.Context, alert Alert) error {
+	_, err := c.session.ChannelMessageSendComplex(c.channelID, &discordgo.MessageSend{
+		Embeds:          []*discordgo.MessageEmbed{newEmbed(alert)},
+		AllowedMentions: &discordgo.MessageAllowedMentions{Parse: []discordgo.AllowedMentionType{}},
+	}, discordgo.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to send the alert to the channel (channel_id = %s): %w", c.channelID, err)
+	}
+	return nil
+}
+
+// newEmbed creates the embed showing the alert to the operators.
+func newEmbed(alert Alert) *discordgo.MessageEmbed {
+	fields := make([]*discordgo.MessageEmbedField, 0, len(alert.Fields)+1)
+	for _, field := range alert.Fields {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: field.Name, Value: field.Value, Inline: true})
+	}
+	if alert.Suppressed > 0 {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   "Suppressed",
+			Value:  fmt.Sprintf("%d alerts during the cooldown", alert.Suppressed),
+			Inline: true,
+		})
+	}
+	return &discordgo.MessageEmbed{
+		Title:       alert.Title,
+		Description: alert.Description,
+		Color:       severityColors[alert.Severity],
+		Fields:      fields,
+		Footer:      &discordgo.MessageEmbedFooter{Text: string(alert.Kind)},
+		Timestamp:   alert.Time.UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,60 @@
+package alert
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Spike detects when the events of a key reach the threshold within the window, e.g. errors in a guild.
+type Spike struct {
+	threshold int
+	window    time.Duration
+	now       func() time.Time
+
+	mu     sync.Mutex
+	events map[string][]time.Time
+}
+
+// NewSpike creates the detector of threshold events within the window. A threshold of 0 never detects a spike.
+func NewSpike(threshold int, window time.Duration) *Spike {
+	return &Spike{
+		threshold: threshold,
+		window:    window,
+		now:       time.Now,
+		events:    make(map[string][]time.Time),
+	}
+}
+
+// Add records an event of the key and reports whether the events reached the threshold, with their number.
+// The events are forgotten when the threshold is reached, so that the next spike is counted from zero.
+func (s *Spike) Add(key string) (int, bool) {
+	if s.threshold <= 0 {
+		return 0, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+	events := append(s.events[key], now)
+	if len(events) < s.threshold {
+		s.events[key] = events
+		return len(events), false
+	}
+	delete(s.events, key)
+	return len(events), true
+}
+
+// sweep forgets the events older than the window.
+func (s *Spike) sweep(now time.Time) {
+	for key, events := range s.events {
+		events = slices.DeleteFunc(events, func(t time.Time) bool { return now.Sub(t) >= s.window })
+		if len(events) == 0 {
+			delete(s.events, key)
+			continue
+		}
+		s.events[key] = events
+	}
+}
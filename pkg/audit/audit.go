@@ -0,0 +1,205 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aqyuki/felm/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// loggerName is the name of the logger of the audit log, whose level can be overridden.
+const loggerName = "audit"
+
+const (
+	// DefaultRetention is how long the records are kept.
+	DefaultRetention = 90 * 24 * time.Hour
+
+	// DefaultPruneInterval is the interval at which the records older than the retention are deleted.
+	DefaultPruneInterval = time.Hour
+
+	// DefaultQueryLimit is the number of records returned by a query without a limit.
+	DefaultQueryLimit = 10
+)
+
+// ErrClosed is returned when a record is written to a closed sink.
+var ErrClosed = errors.New("audit sink is closed")
+
+// Decision is what felm decided to do with a message link.
+type Decision string
+
+const (
+	// DecisionCited means the message was quoted in a reply.
+	DecisionCited Decision = "cited"
+
+	// DecisionSkipped means the message was intentionally not quoted, e.g. because of the rate limits.
+	DecisionSkipped Decision = "skipped"
+
+	// DecisionFailed means quoting the message failed with an error which was reported.
+	DecisionFailed Decision = "failed"
+)
+
+// Record is the audit record of a message link posted in a guild.
+type Record struct {
+	Time    time.Time `json:"time"`
+	TraceID string    `json:"trace_id"`
+
+	// PosterID is the user who posted the message link.
+	PosterID string `json:"poster_id"`
+
+	// GuildID, ChannelID and MessageID are where the message link was posted, which is where the reply is sent.
+	GuildID   string `json:"guild_id"`
+	ChannelID string `json:"channel_id"`
+	MessageID string `json:"message_id"`
+
+	// SourceGuildID, SourceChannelID and SourceMessageID are the quoted message.
+	SourceGuildID   string `json:"source_guild_id"`
+	SourceChannelID string `json:"source_channel_id"`
+	SourceMessageID string `json:"source_message_id"`
+
+	// ReplyMessageID is the reply quoting the message. It is empty unless the decision is DecisionCited.
+	ReplyMessageID string `json:"reply_message_id,omitempty"`
+
+	Decision Decision `json:"decision"`
+
+	// Reason is why the message was skipped or failed, e.g. rate_limited.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Cited returns the record of the message quoted in the reply.
+func (r Record) Cited(replyMessageID string) Record {
+	r.Decision = DecisionCited
+	r.ReplyMessageID = replyMessageID
+	r.Reason = ""
+	return r
+}
+
+// Skipped returns the record of the message not quoted for the reason.
+func (r Record) Skipped(reason string) Record {
+	r.Decision = DecisionSkipped
+	r.Reason = reason
+	return r
+}
+
+// Failed returns the record of the message which could not be quoted because of the reason.
+func (r Record) Failed(reason string) Record {
+	r.Decision = DecisionFailed
+	r.Reason = reason
+	return r
+}
+
+// Query selects the latest records. Empty fields match every record.
+type Query struct {
+	// GuildID matches the records of the message links posted in the guild.
+	GuildID string
+
+	// ChannelID matches the records of the message links posted in the channel or quoting a message of the channel.
+	ChannelID string
+
+	PosterID string
+
+	// Since matches the records at or after the time.
+	Since time.Time
+
+	// Limit is the maximum number of records. 0 or less uses DefaultQueryLimit.
+	Limit int
+}
+
+// Match reports whether the record is selected by the query.
+func (q Query) Match(r Record) bool {
+	if q.GuildID != "" && r.GuildID != q.GuildID {
+		return false
+	}
+	if q.ChannelID != "" && r.ChannelID != q.ChannelID && r.SourceChannelID != q.ChannelID {
+		return false
+	}
+	if q.PosterID != "" && r.PosterID != q.PosterID {
+		return false
+	}
+	return q.Since.IsZero() || !r.Time.Before(q.Since)
+}
+
+// limit returns the maximum number of records of the query.
+func (q Query) limit() int {
+	if q.Limit <= 0 {
+		return DefaultQueryLimit
+	}
+	return q.Limit
+}
+
+// Sink stores the audit records, e.g. in a JSON lines file. Records are only appended,
+// and deleted by Prune when they are older than the retention.
+type Sink interface {
+	// Write appends the record.
+	Write(ctx context.Context, record Record) error
+
+	// Query returns the latest records selected by the query, the latest first.
+	Query(ctx context.Context, query Query) ([]Record, error)
+
+	// Prune deletes the records older than before and returns their number.
+	Prune(ctx context.Context, before time.Time) (int, error)
+
+	Close() error
+}
+
+const (
+	// SinkJSONLines is the kind of the JSONLines sink.
+	SinkJSONLines = "jsonl"
+
+	// SinkSQLite is the kind of the SQLite sink.
+	SinkSQLite = "sqlite"
+)
+
+// ValidSink reports whether the kind of sink is known to Open.
+func ValidSink(kind string) bool {
+	return kind == SinkJSONLines || kind == SinkSQLite
+}
+
+// Open opens the sink of the kind, jsonl or sqlite, stored at the path.
+func Open(kind, path string) (Sink, error) {
+	switch kind {
+	case SinkJSONLines:
+		return OpenJSONLines(path)
+	case SinkSQLite:
+		return OpenSQLite(path)
+	default:
+		return nil, fmt.Errorf("unknown audit sink: %q", kind)
+	}
+}
+
+// RunRetention deletes the records older than retention from the sink at every interval until ctx is done.
+// The records are pruned once when it starts. A retention of 0 keeps the records forever.
+func RunRetention(ctx context.Context, sink Sink, retention, interval time.Duration) {
+	if retention <= 0 {
+		return
+	}
+	if interval <= 0 {
+		interval = DefaultPruneInterval
+	}
+
+	logger := logging.Named(ctx, loggerName)
+	prune := func() {
+		n, err := sink.Prune(ctx, time.Now().Add(-retention))
+		if err != nil {
+			logger.Warn("failed to delete the expired audit records", zap.Error(err))
+			return
+		}
+		if n > 0 {
+			logger.Info("expired audit records were deleted", zap.Int("records", n), zap.Duration("retention", retention))
+		}
+	}
+
+	prune()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			prune()
+		}
+	}
+}
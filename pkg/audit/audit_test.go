@@ -0,0 +1,227 @@
+package audit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryMatch(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	record := Record{Time: now, PosterID: "user", GuildID: "guild", ChannelID: "dest", SourceChannelID: "source"}
+	tests := []struct {
+		name  string
+		query Query
+		want  bool
+	}{
+		{"empty query", Query{}, true},
+		{"guild", Query{GuildID: "guild"}, true},
+		{"other guild", Query{GuildID: "other"}, false},
+		{"destination channel", Query{ChannelID: "dest"}, true},
+		{"source channel", Query{ChannelID: "source"}, true},
+		{"other channel", Query{ChannelID: "other"}, false},
+		{"poster", Query{PosterID: "user"}, true},
+		{"other poster", Query{PosterID: "other"}, false},
+		{"since the time", Query{Since: now}, true},
+		{"after the time", Query{Since: now.Add(time.Second)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.query.Match(record); got != tt.want {
+				t.Errorf("expected %v but received %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// testSink checks the behavior shared by every sink.
+func testSink(t *testing.T, sink Sink) {
+	t.Helper()
+
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, poster := range []string{"a", "b", "a", "a"} {
+		record := Record{Time: start.Add(time.Duration(i) * time.Hour), PosterID: poster, GuildID: "guild", MessageID: string(rune('0' + i))}
+		if err := sink.Write(ctx, record.Cited("reply")); err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+	}
+
+	records, err := sink.Query(ctx, Query{PosterID: "a", Limit: 2})
+	if err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+	if len(records) != 2 || records[0].MessageID != "3" || records[1].MessageID != "2" {
+		t.Errorf("expected the latest 2 records of the poster, the latest first, but received %+v", records)
+	}
+	if records[0].Decision != DecisionCited || records[0].ReplyMessageID != "reply" {
+		t.Errorf("expected the decision to be kept but received %+v", records[0])
+	}
+
+	pruned, err := sink.Prune(ctx, start.Add(2*time.Hour))
+	if err != nil || pruned != 2 {
+		t.Errorf("expected 2 records to be pruned but received %d, %v", pruned, err)
+	}
+	if err := sink.Write(ctx, Record{Time: start.Add(4 * time.Hour), MessageID: "4"}); err != nil {
+		t.Errorf("expected the sink to accept records after pruning but received %v", err)
+	}
+	records, _ = sink.Query(ctx, Query{Limit: 10})
+	if len(records) != 3 || records[0].MessageID != "4" || records[2].MessageID != "2" {
+		t.Errorf("expected the records after the time but received %+v", records)
+	}
+
+	if err := sink.Close(); err != nil {
+		t.Errorf("expected err to be nil but received %v", err)
+	}
+	if err := sink.Write(ctx, Record{}); err != ErrClosed {
+		t.Errorf("expected err to be %v but received %v", ErrClosed, err)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	t.Parallel()
+
+	testSink(t, NewMemory())
+}
+
+func TestJSONLines(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit", "felm.jsonl")
+	sink, err := OpenJSONLines(path)
+	if err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+	testSink(t, sink)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("expected the file to be readable by its owner only but received %s", perm)
+	}
+}
+
+func TestSQLite(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit", "felm.db")
+	sink, err := Open(SinkSQLite, path)
+	if err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+	testSink(t, sink)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("expected the file to be readable by its owner only but received %s", perm)
+	}
+
+	// the records are kept in the file when it is opened again.
+	reopened, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+	defer reopened.Close()
+	records, err := reopened.Query(context.Background(), Query{})
+	if err != nil || len(records) != 3 || !records[0].Time.Equal(time.Date(2024, 1, 1, 4, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the records to be kept but received %+v, %v", records, err)
+	}
+}
+
+func TestJSONLinesUnknownLines(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "felm.jsonl")
+	content := `{"time":"2024-01-01T00:00:00Z","message_id":"old"}
+not a record
+{"time":"2024-01-03T00:00:00Z","message_id":"new"}
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sink, err := OpenJSONLines(path)
+	if err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+	defer sink.Close()
+
+	ctx := context.Background()
+	records, err := sink.Query(ctx, Query{})
+	if err != nil || len(records) != 2 {
+		t.Errorf("expected the unknown line to be skipped but received %+v, %v", records, err)
+	}
+	if _, err := sink.Prune(ctx, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "old") || !strings.Contains(string(data), "not a record") {
+		t.Errorf("expected the unknown line to be kept but received %q", data)
+	}
+}
+
+func TestJSONLinesConcurrentPrune(t *testing.T) {
+	t.Parallel()
+
+	sink, err := OpenJSONLines(filepath.Join(t.TempDir(), "felm.jsonl"))
+	if err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+	defer sink.Close()
+
+	ctx := context.Background()
+	old := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 1000; i++ {
+		_ = sink.Write(ctx, Record{Time: old, MessageID: "old"})
+	}
+
+	// the records written while the file is scanned must survive the replacement of the file.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			if err := sink.Write(ctx, Record{Time: old.Add(time.Hour), MessageID: "new"}); err != nil {
+				t.Errorf("expected err to be nil but received %v", err)
+			}
+		}
+	}()
+	if _, err := sink.Prune(ctx, old.Add(time.Minute)); err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+	if _, err := sink.Query(ctx, Query{}); err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+	<-done
+
+	records, _ := sink.Query(ctx, Query{Limit: 1000})
+	if len(records) != 100 {
+		t.Errorf("expected the 100 new records to be kept but received %d", len(records))
+	}
+}
+
+func TestRunRetention(t *testing.T) {
+	t.Parallel()
+
+	sink := NewMemory()
+	_ = sink.Write(context.Background(), Record{Time: time.Now().Add(-2 * time.Hour)})
+	_ = sink.Write(context.Background(), Record{Time: time.Now()})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	RunRetention(ctx, sink, time.Hour, time.Minute)
+
+	records, _ := sink.Query(context.Background(), Query{})
+	if len(records) != 1 {
+		t.Errorf("expected the expired record to be pruned when it starts but received %+v", records)
+	}
+}
@@ -0,0 +1,218 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// maxLineSize is the maximum size of a line read from the file. A record is far smaller.
+const maxLineSize = 64 * 1024
+
+// JSONLines is the sink appending the records to a file, one JSON object per line.
+// Lines which can not be decoded, e.g. written by a newer version, are skipped by Query and kept by Prune.
+// The file is read through its own handles, so that Query and Prune do not block Write while they scan it.
+type JSONLines struct {
+	path string
+
+	// mu guards the file appended to, and is held by Prune only while it replaces the file.
+	mu   sync.Mutex
+	file *os.File
+
+	// pruneMu serializes Prune.
+	pruneMu sync.Mutex
+}
+
+// OpenJSONLines opens the file to append the records to, creating it and its directory if needed.
+// The file is only readable by its owner because the records identify users.
+func OpenJSONLines(path string) (*JSONLines, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create the directory of the audit log: %w", err)
+	}
+	j := &JSONLines{path: path}
+	if err := j.open(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// open opens the file to append to. The caller must hold the lock, unless it is called by OpenJSONLines.
+func (j *JSONLines) open() error {
+	file, err := os.OpenFile(j.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open the audit log: %w", err)
+	}
+	j.file = file
+	return nil
+}
+
+func (j *JSONLines) Write(_ context.Context, record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode the audit record: %w", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return ErrClosed
+	}
+	// the line is written at once, so that a crash never leaves half of a record before the next one.
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write the audit record: %w", err)
+	}
+	return nil
+}
+
+func (j *JSONLines) Query(ctx context.Context, query Query) ([]Record, error) {
+	f, err := os.Open(j.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the audit log: %w", err)
+	}
+	defer f.Close()
+
+	// the file is read from the start and the latest matches are kept, because the lines have no index.
+	// A line being appended meanwhile may be read partially, in which case it is skipped as an unknown line.
+	latest := make([]Record, 0, query.limit())
+	err = scan(ctx, f, func(_ []byte, record Record, ok bool) error {
+		if !ok || !query.Match(record) {
+			return nil
+		}
+		if len(latest) == query.limit() {
+			latest = latest[1:]
+		}
+		latest = append(latest, record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(latest)
+	return latest, nil
+}
+
+// Prune rewrites the file without the old records. The file is scanned without blocking Write,
+// and only the records appended during the scan are copied while Write is blocked.
+func (j *JSONLines) Prune(ctx context.Context, before time.Time) (int, error) {
+	j.pruneMu.Lock()
+	defer j.pruneMu.Unlock()
+
+	// the lines up to the current size are complete, because each record is appended by a single write under the lock.
+	j.mu.Lock()
+	if j.file == nil {
+		j.mu.Unlock()
+		return 0, ErrClosed
+	}
+	info, err := j.file.Stat()
+	j.mu.Unlock()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat the audit log: %w", err)
+	}
+	scanned := info.Size()
+
+	src, err := os.Open(j.path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open the audit log: %w", err)
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(j.path), filepath.Base(j.path)+".*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create a temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	pruned := 0
+	w := bufio.NewWriter(tmp)
+	err = scan(ctx, io.LimitReader(src, scanned), func(line []byte, record Record, ok bool) error {
+		if ok && record.Time.Before(before) {
+			pruned++
+			return nil
+		}
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+		return w.WriteByte('\n')
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to write the pruned audit log: %w", err)
+	}
+	if pruned == 0 {
+		return 0, nil
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return 0, ErrClosed
+	}
+	// the records appended during the scan follow the scanned part, which src has been read up to.
+	if _, err := io.Copy(w, src); err != nil {
+		return 0, fmt.Errorf("failed to copy the latest audit records: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		return 0, fmt.Errorf("failed to write the pruned audit log: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write the pruned audit log: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o600); err != nil {
+		return 0, fmt.Errorf("failed to restrict the pruned audit log: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), j.path); err != nil {
+		return 0, fmt.Errorf("failed to replace the audit log: %w", err)
+	}
+	// the file was replaced, so the records are appended to the new one from now on.
+	_ = j.file.Close()
+	if err := j.open(); err != nil {
+		j.file = nil
+		return pruned, err
+	}
+	return pruned, nil
+}
+
+// scan calls fn with every line read from r and its record, which is not ok when the line can not be decoded.
+func scan(ctx context.Context, r io.Reader, fn func(line []byte, record Record, ok bool) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineSize)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var record Record
+		err := json.Unmarshal(line, &record)
+		if err := fn(line, record, err == nil); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read the audit log: %w", err)
+	}
+	return nil
+}
+
+func (j *JSONLines) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
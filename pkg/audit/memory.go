@@ -0,0 +1,64 @@
+package audit
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Memory is the sink keeping the records in memory. The records are lost when felm stops.
+type Memory struct {
+	mu      sync.Mutex
+	records []Record
+	closed  bool
+}
+
+// NewMemory creates an empty sink in memory.
+func NewMemory() *Memory {
+	return &Memory{records: make([]Record, 0)}
+}
+
+func (m *Memory) Write(_ context.Context, record Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+	m.records = append(m.records, record)
+	return nil
+}
+
+func (m *Memory) Query(_ context.Context, query Query) ([]Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	records := make([]Record, 0, query.limit())
+	for _, record := range slices.Backward(m.records) {
+		if len(records) >= query.limit() {
+			break
+		}
+		if query.Match(record) {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (m *Memory) Prune(_ context.Context, before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := len(m.records)
+	m.records = slices.DeleteFunc(m.records, func(r Record) bool { return r.Time.Before(before) })
+	return n - len(m.records), nil
+}
+
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.closed = true
+	return nil
+}
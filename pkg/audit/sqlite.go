@@ -0,0 +1,153 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	// the driver of SQLite, which requires cgo.
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteSchema creates the table of the records. The time is stored in Unix nanoseconds, so that it sorts and compares as integers.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS audit_records (
+	id                INTEGER PRIMARY KEY AUTOINCREMENT,
+	time              INTEGER NOT NULL,
+	trace_id          TEXT NOT NULL,
+	poster_id         TEXT NOT NULL,
+	guild_id          TEXT NOT NULL,
+	channel_id        TEXT NOT NULL,
+	message_id        TEXT NOT NULL,
+	source_guild_id   TEXT NOT NULL,
+	source_channel_id TEXT NOT NULL,
+	source_message_id TEXT NOT NULL,
+	reply_message_id  TEXT NOT NULL,
+	decision          TEXT NOT NULL,
+	reason            TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS audit_records_guild_time ON audit_records (guild_id, time);
+CREATE INDEX IF NOT EXISTS audit_records_time ON audit_records (time);
+`
+
+// SQLite is the sink storing the records in a SQLite database, which is indexed for the queries of a guild.
+// The database is in WAL mode, so that queries do not block the records being written.
+type SQLite struct {
+	db     *sql.DB
+	closed atomic.Bool
+}
+
+// OpenSQLite opens the database, creating it, its directory and the table if needed.
+// The file is only readable by its owner because the records identify users.
+func OpenSQLite(path string) (*SQLite, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create the directory of the audit log: %w", err)
+	}
+	// SQLite creates the file readable by everyone, so it is created beforehand.
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the audit log: %w", err)
+	}
+	_ = file.Close()
+
+	params := url.Values{}
+	params.Set("_journal_mode", "WAL")
+	params.Set("_busy_timeout", "5000")
+	db, err := sql.Open("sqlite3", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the audit log: %w", err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to create the table of the audit log: %w", err)
+	}
+	return &SQLite{db: db}, nil
+}
+
+func (s *SQLite) Write(ctx context.Context, record Record) error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO audit_records (
+			time, trace_id, poster_id, guild_id, channel_id, message_id,
+			source_guild_id, source_channel_id, source_message_id, reply_message_id, decision, reason
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		record.Time.UnixNano(), record.TraceID, record.PosterID, record.GuildID, record.ChannelID, record.MessageID,
+		record.SourceGuildID, record.SourceChannelID, record.SourceMessageID, record.ReplyMessageID, string(record.Decision), record.Reason)
+	if err != nil {
+		return fmt.Errorf("failed to write the audit record: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLite) Query(ctx context.Context, query Query) ([]Record, error) {
+	if s.closed.Load() {
+		return nil, ErrClosed
+	}
+	since := int64(0)
+	if !query.Since.IsZero() {
+		since = query.Since.UnixNano()
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT
+			time, trace_id, poster_id, guild_id, channel_id, message_id,
+			source_guild_id, source_channel_id, source_message_id, reply_message_id, decision, reason
+		FROM audit_records
+		WHERE (?1 = '' OR guild_id = ?1)
+			AND (?2 = '' OR channel_id = ?2 OR source_channel_id = ?2)
+			AND (?3 = '' OR poster_id = ?3)
+			AND time >= ?4
+		ORDER BY time DESC, id DESC
+		LIMIT ?5`,
+		query.GuildID, query.ChannelID, query.PosterID, since, query.limit())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the audit log: %w", err)
+	}
+	defer rows.Close()
+
+	records := make([]Record, 0, query.limit())
+	for rows.Next() {
+		var record Record
+		var nanos int64
+		var decision string
+		if err := rows.Scan(&nanos, &record.TraceID, &record.PosterID, &record.GuildID, &record.ChannelID, &record.MessageID,
+			&record.SourceGuildID, &record.SourceChannelID, &record.SourceMessageID, &record.ReplyMessageID, &decision, &record.Reason); err != nil {
+			return nil, fmt.Errorf("failed to read the audit record: %w", err)
+		}
+		record.Time = time.Unix(0, nanos).UTC()
+		record.Decision = Decision(decision)
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the audit log: %w", err)
+	}
+	return records, nil
+}
+
+func (s *SQLite) Prune(ctx context.Context, before time.Time) (int, error) {
+	if s.closed.Load() {
+		return 0, ErrClosed
+	}
+	result, err := s.db.ExecContext(ctx, `DELETE FROM audit_records WHERE time < ?`, before.UnixNano())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete the expired audit records: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count the expired audit records: %w", err)
+	}
+	return int(n), nil
+}
+
+func (s *SQLite) Close() error {
+	if s.closed.Swap(true) {
+		return nil
+	}
+	return s.db.Close()
+}
@@ -0,0 +1,197 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Backend stores encoded entries outside of the in-memory cache, e.g. in Redis,
+// so that they can be shared between processes.
+type Backend interface {
+	// Get returns the value and the remaining TTL of the key.
+	// A TTL of 0 or less means the entry never expires. It returns ErrNotFound if the key does not exist.
+	Get(ctx context.Context, key string) ([]byte, time.Duration, error)
+
+	// Set stores the value with the TTL. A TTL of 0 or less means the entry never expires.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Delete removes the keys. Missing keys are ignored.
+	Delete(ctx context.Context, keys ...string) error
+
+	// DeletePrefix removes every key starting with the prefix and returns their number.
+	// An empty prefix removes every key of the backend.
+	DeletePrefix(ctx context.Context, prefix string) (int, error)
+
+	// Close releases the resources of the backend.
+	Close() error
+}
+
+// Codec converts values to bytes stored in a Backend.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	_ Codec = JSONCodec{}
+	_ Codec = GobCodec{}
+)
+
+// JSONCodec encodes values with encoding/json.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// GobCodec encodes values with encoding/gob.
+// Values containing interfaces require their concrete types to be registered with gob.Register.
+type GobCodec struct{}
+
+func (GobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// ParseCodec converts the name of a codec such as "json" into a Codec.
+func ParseCodec(name string) (Codec, bool) {
+	switch name {
+	case "json":
+		return JSONCodec{}, true
+	case "gob":
+		return GobCodec{}, true
+	default:
+		return nil, false
+	}
+}
+
+var _ Backend = (*MemoryBackend)(nil)
+
+// MemoryBackend is a Backend which keeps entries in the memory of the process.
+type MemoryBackend struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	now     func() time.Time
+}
+
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		entries: make(map[string]memoryEntry),
+		now:     time.Now,
+	}
+}
+
+func (b *MemoryBackend) Get(_ context.Context, key string) ([]byte, time.Duration, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entry, ok := b.entries[key]
+	if !ok {
+		return nil, 0, ErrNotFound
+	}
+	if entry.expiresAt.IsZero() {
+		return bytes.Clone(entry.value), 0, nil
+	}
+	ttl := entry.expiresAt.Sub(b.now())
+	if ttl <= 0 {
+		delete(b.entries, key)
+		return nil, 0, ErrNotFound
+	}
+	return bytes.Clone(entry.value), ttl, nil
+}
+
+func (b *MemoryBackend) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entry := memoryEntry{value: bytes.Clone(value)}
+	if ttl > 0 {
+		entry.expiresAt = b.now().Add(ttl)
+	}
+	b.entries[key] = entry
+	return nil
+}
+
+func (b *MemoryBackend) Delete(_ context.Context, keys ...string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, key := range keys {
+		delete(b.entries, key)
+	}
+	return nil
+}
+
+func (b *MemoryBackend) DeletePrefix(_ context.Context, prefix string) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	deleted := 0
+	for key := range b.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(b.entries, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (b *MemoryBackend) Close() error {
+	return nil
+}
+
+// prefixBackend adds a prefix to every key so that several caches can share a backend.
+type prefixBackend struct {
+	backend Backend
+	prefix  string
+}
+
+// PrefixBackend returns a Backend which adds the prefix to every key before passing it to the backend.
+func PrefixBackend(backend Backend, prefix string) Backend {
+	return &prefixBackend{backend: backend, prefix: prefix}
+}
+
+func (b *prefixBackend) Get(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	return b.backend.Get(ctx, b.prefix+key)
+}
+
+func (b *prefixBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return b.backend.Set(ctx, b.prefix+key, value, ttl)
+}
+
+func (b *prefixBackend) Delete(ctx context.Context, keys ...string) error {
+	prefixed := make([]string, 0, len(keys))
+	for _, key := range keys {
+		prefixed = append(prefixed, b.prefix+key)
+	}
+	return b.backend.Delete(ctx, prefixed...)
+}
+
+func (b *prefixBackend) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	return b.backend.DeletePrefix(ctx, b.prefix+prefix)
+}
+
+func (b *prefixBackend) Close() error {
+	return b.backend.Close()
+}
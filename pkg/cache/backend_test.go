@@ -0,0 +1,228 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type sample struct {
+	Name  string
+	Count int
+}
+
+func TestCodec(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"json", "gob"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			codec, ok := ParseCodec(name)
+			if !ok {
+				t.Fatalf("expected codec %s to be found", name)
+			}
+			data, err := codec.Marshal(sample{Name: "felm", Count: 3})
+			if err != nil {
+				t.Fatalf("expected err to be nil but received %v", err)
+			}
+
+			var actual sample
+			if err := codec.Unmarshal(data, &actual); err != nil {
+				t.Fatalf("expected err to be nil but received %v", err)
+			}
+			if actual != (sample{Name: "felm", Count: 3}) {
+				t.Errorf("expected value to survive the round trip but received %+v", actual)
+			}
+		})
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		t.Parallel()
+		if _, ok := ParseCodec("xml"); ok {
+			t.Error("expected xml codec not to be found")
+		}
+	})
+}
+
+func TestMemoryBackend(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	backend := NewMemoryBackend()
+	backend.now = clock.Now
+	ctx := context.Background()
+
+	if err := backend.Set(ctx, "short", []byte("a"), 1*time.Second); err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+	if err := backend.Set(ctx, "forever", []byte("b"), 0); err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+
+	value, ttl, err := backend.Get(ctx, "short")
+	if err != nil || string(value) != "a" || ttl != 1*time.Second {
+		t.Errorf("expected (a, 1s, nil) but received (%s, %v, %v)", value, ttl, err)
+	}
+
+	clock.Advance(1 * time.Second)
+	if _, _, err := backend.Get(ctx, "short"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected err to be %v but received %v", ErrNotFound, err)
+	}
+	if value, ttl, err := backend.Get(ctx, "forever"); err != nil || string(value) != "b" || ttl != 0 {
+		t.Errorf("expected (b, 0, nil) but received (%s, %v, %v)", value, ttl, err)
+	}
+
+	if err := backend.Delete(ctx, "forever", "missing"); err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+	if _, _, err := backend.Get(ctx, "forever"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected err to be %v but received %v", ErrNotFound, err)
+	}
+}
+
+func TestPrefixBackend(t *testing.T) {
+	t.Parallel()
+
+	backend := NewMemoryBackend()
+	prefixed := PrefixBackend(backend, "channel:")
+	ctx := context.Background()
+
+	if err := prefixed.Set(ctx, "1", []byte("a"), 0); err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+	if _, _, err := backend.Get(ctx, "channel:1"); err != nil {
+		t.Errorf("expected key to be prefixed but received %v", err)
+	}
+	if err := prefixed.Delete(ctx, "1"); err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+	if _, _, err := backend.Get(ctx, "channel:1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected err to be %v but received %v", ErrNotFound, err)
+	}
+
+	_ = prefixed.Set(ctx, "guild/1", []byte("a"), 0)
+	_ = prefixed.Set(ctx, "guild/2", []byte("b"), 0)
+	_ = backend.Set(ctx, "message:guild/1", []byte("c"), 0)
+	if deleted, err := prefixed.DeletePrefix(ctx, "guild/"); err != nil || deleted != 2 {
+		t.Errorf("expected 2 keys to be deleted but received (%d, %v)", deleted, err)
+	}
+	if _, _, err := backend.Get(ctx, "message:guild/1"); err != nil {
+		t.Errorf("expected the keys of the other prefix to be kept but received %v", err)
+	}
+}
+
+type failingBackend struct {
+	calls int
+}
+
+func (b *failingBackend) Get(context.Context, string) ([]byte, time.Duration, error) {
+	b.calls++
+	return nil, 0, errors.New("unreachable")
+}
+
+func (b *failingBackend) Set(context.Context, string, []byte, time.Duration) error {
+	b.calls++
+	return errors.New("unreachable")
+}
+
+func (b *failingBackend) Delete(context.Context, ...string) error {
+	b.calls++
+	return errors.New("unreachable")
+}
+
+func (b *failingBackend) DeletePrefix(context.Context, string) (int, error) {
+	b.calls++
+	return 0, errors.New("unreachable")
+}
+
+func (b *failingBackend) Close() error {
+	return nil
+}
+
+func TestCacheWithBackend(t *testing.T) {
+	t.Parallel()
+
+	t.Run("entries are shared through the backend", func(t *testing.T) {
+		t.Parallel()
+
+		backend := NewMemoryBackend()
+		writer := New[sample](1*time.Minute, WithBackend(backend, JSONCodec{}))
+		reader := New[sample](1*time.Minute, WithBackend(backend, JSONCodec{}))
+
+		if err := writer.Set("key", sample{Name: "felm"}); err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		value, err := reader.Get("key")
+		if err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		if value.Name != "felm" {
+			t.Errorf("expected value to be shared but received %+v", value)
+		}
+		if stats := reader.Stats(); stats.RemoteHits != 1 || stats.Entries != 1 {
+			t.Errorf("expected the remote hit to be cached in memory but received %+v", stats)
+		}
+
+		writer.Delete("key")
+		reader.Purge()
+		if _, err := reader.Get("key"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected deletion to be shared but received %v", err)
+		}
+	})
+
+	t.Run("refresh keeps the entries it does not hold in the backend", func(t *testing.T) {
+		t.Parallel()
+
+		backend := NewMemoryBackend()
+		writer := New[sample](1*time.Minute, WithBackend(backend, JSONCodec{}))
+		refresher := New[sample](1*time.Minute, WithBackend(backend, JSONCodec{}))
+
+		if err := writer.Set("key", sample{Name: "shared"}); err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		if err := refresher.Refresh("key", sample{Name: "refreshed"}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected err to be %v but received %v", ErrNotFound, err)
+		}
+		if value, _, err := backend.Get(context.Background(), "key"); err != nil || !bytes.Contains(value, []byte("shared")) {
+			t.Errorf("expected the entry to be kept in the backend but received (%s, %v)", value, err)
+		}
+
+		// the entries held in memory are refreshed in the backend too.
+		if _, err := refresher.Get("key"); err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		if err := refresher.Refresh("key", sample{Name: "refreshed"}); err != nil {
+			t.Errorf("expected err to be nil but received %v", err)
+		}
+		reader := New[sample](1*time.Minute, WithBackend(backend, JSONCodec{}))
+		if value, err := reader.Get("key"); err != nil || value.Name != "refreshed" {
+			t.Errorf("expected the refreshed value to be shared but received (%+v, %v)", value, err)
+		}
+	})
+
+	t.Run("memory is used while the backend is unreachable", func(t *testing.T) {
+		t.Parallel()
+
+		backend := &failingBackend{}
+		cache := New[int](1*time.Minute, WithBackend(backend, JSONCodec{}), WithBackendTimeout(10*time.Millisecond, 1*time.Hour))
+
+		if err := cache.Set("key", 1); err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		if value, err := cache.Get("key"); err != nil || value != 1 {
+			t.Errorf("expected (1, nil) but received (%v, %v)", value, err)
+		}
+		if _, err := cache.Get("missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected err to be %v but received %v", ErrNotFound, err)
+		}
+		if backend.calls != 1 {
+			t.Errorf("expected the backend to be skipped after the failure but called %d times", backend.calls)
+		}
+		if stats := cache.Stats(); stats.RemoteErrors != 1 {
+			t.Errorf("expected 1 remote error but received %d", stats.RemoteErrors)
+		}
+	})
+}
@@ -1,38 +1,603 @@
 package cache
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/patrickmn/go-cache"
 	"github.com/samber/lo"
 )
 
 var (
 	ErrNotFound = errors.New("not found")
+
+	// ErrBackendUnavailable is returned when the backend is skipped because it failed recently.
+	ErrBackendUnavailable = errors.New("cache backend is unavailable")
+)
+
+const (
+	// DefaultExpiration uses the expiration given to New.
+	DefaultExpiration time.Duration = 0
+
+	// NoExpiration keeps the entry until it is deleted or evicted.
+	NoExpiration time.Duration = -1
 )
 
+// EvictionReason describes why an entry was removed from the cache.
+type EvictionReason int
+
+const (
+	// ReasonExpired means the entry outlived its TTL.
+	ReasonExpired EvictionReason = iota
+
+	// ReasonCapacity means the entry was evicted by the policy to make room for another one.
+	ReasonCapacity
+
+	// ReasonDeleted means the entry was removed by Delete, DeleteFunc or Purge.
+	ReasonDeleted
+)
+
+func (r EvictionReason) String() string {
+	switch r {
+	case ReasonExpired:
+		return "expired"
+	case ReasonCapacity:
+		return "capacity"
+	case ReasonDeleted:
+		return "deleted"
+	default:
+		return "unknown"
+	}
+}
+
+// Stats is a snapshot of the cache counters.
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+
+	// NegativeHits is the number of GetOrLoad calls answered by a cached error.
+	NegativeHits uint64
+
+	// Loads is the number of loaders called by GetOrLoad.
+	Loads uint64
+
+	// Coalesced is the number of GetOrLoad calls which waited for a load started by another call.
+	Coalesced uint64
+
+	// RemoteHits is the number of hits answered by the backend. They are also counted in Hits.
+	RemoteHits uint64
+
+	// RemoteErrors is the number of failed calls to the backend.
+	RemoteErrors uint64
+}
+
+// HitRate returns the ratio of hits to lookups, or 0 if there was no lookup.
+func (s Stats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+type settings struct {
+	capacity int
+	policy   Policy
+	onEvict  func(key string, value any, reason EvictionReason)
+	now      func() time.Time
+
+	negativeTTL       time.Duration
+	negativeCacheable func(error) bool
+	loadTimeout       time.Duration
+
+	backend        Backend
+	codec          Codec
+	backendTimeout time.Duration
+	backendBackoff time.Duration
+}
+
+// Option is a function that configures a Cache.
+type Option func(*settings)
+
+// WithCapacity bounds the number of entries. A capacity of 0 or less means unbounded.
+func WithCapacity(capacity int) Option {
+	return func(s *settings) {
+		s.capacity = capacity
+	}
+}
+
+// WithPolicy sets the policy used to evict entries when the cache is full.
+func WithPolicy(policy Policy) Option {
+	return func(s *settings) {
+		s.policy = policy
+	}
+}
+
+// WithOnEvict sets the function called after an entry is removed from the cache.
+// The function is called while the cache is locked, so it must not call the cache.
+func WithOnEvict[T any](fn func(key string, value T, reason EvictionReason)) Option {
+	return func(s *settings) {
+		if fn == nil {
+			s.onEvict = nil
+			return
+		}
+		s.onEvict = func(key string, value any, reason EvictionReason) {
+			fn(key, value.(T), reason)
+		}
+	}
+}
+
+// WithBackend shares the entries through the backend, encoding them with the codec.
+// Entries are written through to the backend, and misses of the in-memory cache are looked up in it.
+// While the backend is unreachable, the cache keeps working with the in-memory entries only.
+func WithBackend(backend Backend, codec Codec) Option {
+	return func(s *settings) {
+		s.backend = backend
+		s.codec = codec
+	}
+}
+
+// WithBackendTimeout sets the timeout of each call to the backend,
+// and the duration to stop calling the backend after a failure.
+func WithBackendTimeout(timeout, backoff time.Duration) Option {
+	return func(s *settings) {
+		if timeout > 0 {
+			s.backendTimeout = timeout
+		}
+		if backoff >= 0 {
+			s.backendBackoff = backoff
+		}
+	}
+}
+
+// WithClock replaces the clock used to expire entries.
+func WithClock(now func() time.Time) Option {
+	return func(s *settings) {
+		if now != nil {
+			s.now = now
+		}
+	}
+}
+
+type item[T any] struct {
+	value T
+
+	// expiresAt is the time the entry expires. The zero value means the entry never expires.
+	expiresAt time.Time
+}
+
+func (i item[T]) expired(now time.Time) bool {
+	return !i.expiresAt.IsZero() && !now.Before(i.expiresAt)
+}
+
 type Cache[T any] struct {
-	cache             *cache.Cache
+	mu                sync.Mutex
+	items             map[string]item[T]
+	tracker           tracker
 	defaultExpiration time.Duration
+	settings          settings
+
+	// calls are the loads in progress started by GetOrLoad.
+	calls map[string]*call[T]
+
+	// negatives are the errors cached by GetOrLoad.
+	negatives map[string]negative
+
+	stats     Stats
+	lastSweep time.Time
+
+	// backendDownUntil is the time until which the backend is skipped after a failure.
+	backendDownUntil time.Time
 }
 
-func New[T any](exp time.Duration) *Cache[T] {
+func New[T any](exp time.Duration, option ...Option) *Cache[T] {
+	s := settings{
+		policy:         PolicyLRU,
+		now:            time.Now,
+		codec:          JSONCodec{},
+		backendTimeout: 200 * time.Millisecond,
+		backendBackoff: 30 * time.Second,
+		loadTimeout:    10 * time.Second,
+	}
+	for _, opt := range option {
+		opt(&s)
+	}
+	if s.policy == PolicyTTL {
+		s.capacity = 0
+	}
 	return &Cache[T]{
-		cache:             cache.New(exp, exp),
+		items:             make(map[string]item[T]),
+		tracker:           newTracker(s.policy),
 		defaultExpiration: exp,
+		settings:          s,
+		calls:             make(map[string]*call[T]),
+		negatives:         make(map[string]negative),
+		lastSweep:         s.now(),
 	}
 }
 
 func (c *Cache[T]) Set(key string, value T) error {
-	c.cache.Set(key, value, c.defaultExpiration)
+	return c.SetWithTTL(key, value, DefaultExpiration)
+}
+
+// SetWithTTL caches the value with the TTL instead of the default expiration.
+// DefaultExpiration and NoExpiration can be used as the TTL.
+func (c *Cache[T]) SetWithTTL(key string, value T, ttl time.Duration) error {
+	c.mu.Lock()
+	expiresAt := c.setLocal(key, value, ttl)
+	c.invalidateCall(key)
+	c.mu.Unlock()
+
+	c.setRemote(key, value, expiresAt)
 	return nil
 }
 
+// setLocal caches the value in memory and returns its expiration. The caller must hold the lock.
+func (c *Cache[T]) setLocal(key string, value T, ttl time.Duration) time.Time {
+	now := c.settings.now()
+	c.sweep(now)
+
+	if _, ok := c.items[key]; !ok && c.settings.capacity > 0 {
+		for len(c.items) >= c.settings.capacity {
+			victim, ok := c.tracker.victim()
+			if !ok {
+				break
+			}
+			c.remove(victim, ReasonCapacity)
+		}
+	}
+
+	expiresAt := c.expiresAt(now, ttl)
+	c.items[key] = item[T]{value: value, expiresAt: expiresAt}
+	c.tracker.add(key)
+	delete(c.negatives, key)
+	return expiresAt
+}
+
 func (c *Cache[T]) Get(key string) (T, error) {
-	value, found := c.cache.Get(key)
-	if !found {
-		return lo.Empty[T](), ErrNotFound
+	c.mu.Lock()
+	it, ok := c.items[key]
+	if ok && it.expired(c.settings.now()) {
+		c.remove(key, ReasonExpired)
+		ok = false
+	}
+	if ok {
+		c.stats.Hits++
+		c.tracker.touch(key)
+		c.mu.Unlock()
+		return it.value, nil
+	}
+	c.mu.Unlock()
+
+	if value, ttl, ok := c.getRemote(key); ok {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.stats.Hits++
+		c.stats.RemoteHits++
+		c.setLocal(key, value, ttl)
+		return value, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.Misses++
+	return lo.Empty[T](), ErrNotFound
+}
+
+// Replace updates the value only if the key is already cached.
+func (c *Cache[T]) Replace(key string, value T) error {
+	return c.ReplaceWithTTL(key, value, DefaultExpiration)
+}
+
+// ReplaceWithTTL updates the value and its TTL only if the key is already cached.
+// When the key is not cached in memory, it is deleted from the backend, because the entry there,
+// e.g. cached by another process or before a restart, is older than the value.
+func (c *Cache[T]) ReplaceWithTTL(key string, value T, ttl time.Duration) error {
+	return c.replace(key, value, ttl, true)
+}
+
+// Refresh updates the value only if the key is cached in memory.
+// Unlike Replace, the backend is left untouched when the key is not cached in memory,
+// so that the entries shared by other processes are kept when the value may not be newer than them.
+func (c *Cache[T]) Refresh(key string, value T) error {
+	return c.replace(key, value, DefaultExpiration, false)
+}
+
+// replace updates the value of the key cached in memory, and deletes it from the backend on a miss if deleteMissing is set.
+func (c *Cache[T]) replace(key string, value T, ttl time.Duration, deleteMissing bool) error {
+	c.mu.Lock()
+
+	now := c.settings.now()
+	it, ok := c.items[key]
+	c.invalidateCall(key)
+	if !ok || it.expired(now) {
+		c.mu.Unlock()
+		if deleteMissing {
+			c.deleteRemote(key)
+		}
+		return ErrNotFound
+	}
+	expiresAt := c.expiresAt(now, ttl)
+	c.items[key] = item[T]{value: value, expiresAt: expiresAt}
+	c.mu.Unlock()
+
+	c.setRemote(key, value, expiresAt)
+	return nil
+}
+
+func (c *Cache[T]) Delete(key string) {
+	c.mu.Lock()
+	if _, ok := c.items[key]; ok {
+		c.remove(key, ReasonDeleted)
+	}
+	delete(c.negatives, key)
+	c.invalidateCall(key)
+	c.mu.Unlock()
+
+	c.deleteRemote(key)
+}
+
+// DeleteFunc deletes every entry for which fn returns true and returns the number of deleted entries.
+// Only the entries held in memory are examined, but they are deleted from the backend too.
+func (c *Cache[T]) DeleteFunc(fn func(key string, value T) bool) int {
+	c.mu.Lock()
+	deleted := make([]string, 0)
+	for key, it := range c.items {
+		if fn(key, it.value) {
+			c.remove(key, ReasonDeleted)
+			deleted = append(deleted, key)
+		}
+	}
+	// the values being loaded can not be examined, so none of them is cached.
+	c.invalidateCalls(func(string) bool { return true })
+	c.mu.Unlock()
+
+	c.deleteRemote(deleted...)
+	return len(deleted)
+}
+
+// DeletePrefix deletes every entry whose key starts with the prefix, in memory and in the backend,
+// and returns the number of entries deleted from memory.
+func (c *Cache[T]) DeletePrefix(prefix string) int {
+	c.mu.Lock()
+	deleted := 0
+	for key := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.remove(key, ReasonDeleted)
+			deleted++
+		}
+	}
+	for key := range c.negatives {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.negatives, key)
+		}
+	}
+	c.invalidateCalls(func(key string) bool { return strings.HasPrefix(key, prefix) })
+	c.mu.Unlock()
+
+	_ = c.deletePrefixRemote(prefix)
+	return deleted
+}
+
+// Purge deletes every entry, in memory and in the backend.
+// Every key of the backend is deleted, so caches sharing a backend must use PrefixBackend.
+// The error is the failure of the backend, in which case the entries in memory are deleted anyway.
+func (c *Cache[T]) Purge() error {
+	c.mu.Lock()
+	for key := range c.items {
+		c.remove(key, ReasonDeleted)
+	}
+	c.tracker.reset()
+	clear(c.negatives)
+	c.invalidateCalls(func(string) bool { return true })
+	c.mu.Unlock()
+
+	return c.deletePrefixRemote("")
+}
+
+// DeleteExpired removes every expired entry.
+func (c *Cache[T]) DeleteExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deleteExpired(c.settings.now())
+}
+
+// Len returns the number of entries including the expired ones which have not been removed yet.
+func (c *Cache[T]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.items)
+}
+
+// Keys returns the sorted keys of the entries held in memory which have not expired.
+func (c *Cache[T]) Keys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.settings.now()
+	keys := make([]string, 0, len(c.items))
+	for key, it := range c.items {
+		if !it.expired(now) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Shared reports whether the entries are shared with other processes through a backend.
+func (c *Cache[T]) Shared() bool {
+	return c.settings.backend != nil
+}
+
+// Stats returns a snapshot of the counters.
+func (c *Cache[T]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = len(c.items)
+	return stats
+}
+
+func (c *Cache[T]) expiresAt(now time.Time, ttl time.Duration) time.Time {
+	if ttl == DefaultExpiration {
+		ttl = c.defaultExpiration
+	}
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
+
+// remove deletes the entry and notifies the callback. The caller must hold the lock.
+func (c *Cache[T]) remove(key string, reason EvictionReason) {
+	it := c.items[key]
+	delete(c.items, key)
+	c.tracker.remove(key)
+	if reason != ReasonDeleted {
+		c.stats.Evictions++
+	}
+	if c.settings.onEvict != nil {
+		c.settings.onEvict(key, it.value, reason)
+	}
+}
+
+// sweep removes expired entries at most once per default expiration. The caller must hold the lock.
+func (c *Cache[T]) sweep(now time.Time) {
+	if c.defaultExpiration <= 0 || now.Sub(c.lastSweep) < c.defaultExpiration {
+		return
+	}
+	c.deleteExpired(now)
+}
+
+func (c *Cache[T]) deleteExpired(now time.Time) {
+	c.lastSweep = now
+	for key, it := range c.items {
+		if it.expired(now) {
+			c.remove(key, ReasonExpired)
+		}
+	}
+	for key, neg := range c.negatives {
+		if !now.Before(neg.expiresAt) {
+			delete(c.negatives, key)
+		}
+	}
+}
+
+// backendAvailable reports whether the backend should be called.
+func (c *Cache[T]) backendAvailable() bool {
+	if c.settings.backend == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return !c.settings.now().Before(c.backendDownUntil)
+}
+
+// backendFailed records the failure and skips the backend for a while.
+func (c *Cache[T]) backendFailed() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.RemoteErrors++
+	c.backendDownUntil = c.settings.now().Add(c.settings.backendBackoff)
+}
+
+func (c *Cache[T]) getRemote(key string) (T, time.Duration, bool) {
+	var value T
+	if !c.backendAvailable() {
+		return value, 0, false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.settings.backendTimeout)
+	defer cancel()
+
+	data, ttl, err := c.settings.backend.Get(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		return value, 0, false
+	}
+	if err != nil {
+		c.backendFailed()
+		return value, 0, false
+	}
+	if err := c.settings.codec.Unmarshal(data, &value); err != nil {
+		// a broken entry is not a failure of the backend, so just ignore it.
+		return value, 0, false
+	}
+	if ttl <= 0 {
+		ttl = NoExpiration
+	}
+	return value, ttl, true
+}
+
+func (c *Cache[T]) setRemote(key string, value T, expiresAt time.Time) {
+	if !c.backendAvailable() {
+		return
+	}
+
+	ttl := time.Duration(0)
+	if !expiresAt.IsZero() {
+		ttl = expiresAt.Sub(c.settings.now())
+		if ttl <= 0 {
+			return
+		}
+	}
+	data, err := c.settings.codec.Marshal(value)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.settings.backendTimeout)
+	defer cancel()
+
+	if err := c.settings.backend.Set(ctx, key, data, ttl); err != nil {
+		c.backendFailed()
+	}
+}
+
+// bulkDeleteTimeout is the timeout to delete the keys by prefix, which scans the keys of the backend.
+const bulkDeleteTimeout = 5 * time.Second
+
+// deletePrefixRemote deletes the keys starting with the prefix from the backend.
+func (c *Cache[T]) deletePrefixRemote(prefix string) error {
+	if c.settings.backend == nil {
+		return nil
+	}
+	if !c.backendAvailable() {
+		return ErrBackendUnavailable
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), max(c.settings.backendTimeout, bulkDeleteTimeout))
+	defer cancel()
+
+	if _, err := c.settings.backend.DeletePrefix(ctx, prefix); err != nil {
+		c.backendFailed()
+		return fmt.Errorf("failed to delete the entries from the backend: %w", err)
+	}
+	return nil
+}
+
+func (c *Cache[T]) deleteRemote(keys ...string) {
+	if len(keys) == 0 || !c.backendAvailable() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.settings.backendTimeout)
+	defer cancel()
+
+	if err := c.settings.backend.Delete(ctx, keys...); err != nil {
+		c.backendFailed()
 	}
-	return value.(T), nil
 }
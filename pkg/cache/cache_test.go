@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"slices"
 	"testing"
 	"time"
 )
@@ -60,3 +61,325 @@ func TestGet(t *testing.T) {
 		}
 	})
 }
+
+func TestReplace(t *testing.T) {
+	t.Parallel()
+
+	t.Run("found key", func(t *testing.T) {
+		t.Parallel()
+
+		cache := New[int](1 * time.Minute)
+		if err := cache.Set("key", 1); err != nil {
+			t.Errorf("expected err to be nil but received %v", err)
+		}
+		if err := cache.Replace("key", 2); err != nil {
+			t.Errorf("expected err to be nil but received %v", err)
+		}
+
+		value, _ := cache.Get("key")
+		if value != 2 {
+			t.Errorf("expected value to be 2 but received %v", value)
+		}
+	})
+
+	t.Run("not found key", func(t *testing.T) {
+		t.Parallel()
+
+		cache := New[int](1 * time.Minute)
+		if err := cache.Replace("key", 2); err != ErrNotFound {
+			t.Errorf("expected err to be %v but received %v", ErrNotFound, err)
+		}
+		if _, err := cache.Get("key"); err != ErrNotFound {
+			t.Errorf("expected key not to be cached but received %v", err)
+		}
+	})
+}
+
+func TestReplaceWithTTL(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	cache := New[int](1*time.Minute, WithClock(clock.Now))
+	if err := cache.Set("key", 1); err != nil {
+		t.Errorf("expected err to be nil but received %v", err)
+	}
+	if err := cache.ReplaceWithTTL("key", 2, 1*time.Hour); err != nil {
+		t.Errorf("expected err to be nil but received %v", err)
+	}
+
+	clock.Advance(30 * time.Minute)
+	value, err := cache.Get("key")
+	if err != nil {
+		t.Errorf("expected key to be cached but received %v", err)
+	}
+	if value != 2 {
+		t.Errorf("expected value to be 2 but received %v", value)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	t.Parallel()
+
+	cache := New[int](1 * time.Minute)
+	if err := cache.Set("key", 1); err != nil {
+		t.Errorf("expected err to be nil but received %v", err)
+	}
+	cache.Delete("key")
+
+	if _, err := cache.Get("key"); err != ErrNotFound {
+		t.Errorf("expected err to be %v but received %v", ErrNotFound, err)
+	}
+}
+
+func TestDeleteFunc(t *testing.T) {
+	t.Parallel()
+
+	cache := New[int](1 * time.Minute)
+	for i, key := range []string{"a", "b", "c", "d"} {
+		if err := cache.Set(key, i); err != nil {
+			t.Errorf("expected err to be nil but received %v", err)
+		}
+	}
+
+	deleted := cache.DeleteFunc(func(_ string, value int) bool {
+		return value%2 == 0
+	})
+	if deleted != 2 {
+		t.Errorf("expected 2 entries to be deleted but received %d", deleted)
+	}
+	if _, err := cache.Get("a"); err != ErrNotFound {
+		t.Errorf("expected a to be deleted but received %v", err)
+	}
+	if _, err := cache.Get("b"); err != nil {
+		t.Errorf("expected b to remain but received %v", err)
+	}
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestExpiration(t *testing.T) {
+	t.Parallel()
+
+	t.Run("default expiration", func(t *testing.T) {
+		t.Parallel()
+
+		clock := &fakeClock{now: time.Unix(0, 0)}
+		cache := New[int](1*time.Minute, WithClock(clock.Now))
+		if err := cache.Set("key", 1); err != nil {
+			t.Errorf("expected err to be nil but received %v", err)
+		}
+
+		clock.Advance(59 * time.Second)
+		if _, err := cache.Get("key"); err != nil {
+			t.Errorf("expected key to be cached but received %v", err)
+		}
+
+		clock.Advance(1 * time.Second)
+		if _, err := cache.Get("key"); err != ErrNotFound {
+			t.Errorf("expected err to be %v but received %v", ErrNotFound, err)
+		}
+	})
+
+	t.Run("per entry ttl", func(t *testing.T) {
+		t.Parallel()
+
+		clock := &fakeClock{now: time.Unix(0, 0)}
+		cache := New[int](1*time.Minute, WithClock(clock.Now))
+		if err := cache.SetWithTTL("short", 1, 1*time.Second); err != nil {
+			t.Errorf("expected err to be nil but received %v", err)
+		}
+		if err := cache.SetWithTTL("forever", 2, NoExpiration); err != nil {
+			t.Errorf("expected err to be nil but received %v", err)
+		}
+
+		clock.Advance(1 * time.Hour)
+		if _, err := cache.Get("short"); err != ErrNotFound {
+			t.Errorf("expected short to be expired but received %v", err)
+		}
+		if _, err := cache.Get("forever"); err != nil {
+			t.Errorf("expected forever to be cached but received %v", err)
+		}
+	})
+
+	t.Run("expired entries are swept", func(t *testing.T) {
+		t.Parallel()
+
+		clock := &fakeClock{now: time.Unix(0, 0)}
+		cache := New[int](1*time.Minute, WithClock(clock.Now))
+		for _, key := range []string{"a", "b", "c"} {
+			if err := cache.Set(key, 1); err != nil {
+				t.Errorf("expected err to be nil but received %v", err)
+			}
+		}
+
+		clock.Advance(1 * time.Minute)
+		if err := cache.Set("d", 1); err != nil {
+			t.Errorf("expected err to be nil but received %v", err)
+		}
+		if cache.Len() != 1 {
+			t.Errorf("expected 1 entry but received %d", cache.Len())
+		}
+	})
+}
+
+func TestCapacity(t *testing.T) {
+	t.Parallel()
+
+	t.Run("lru evicts the least recently used entry", func(t *testing.T) {
+		t.Parallel()
+
+		cache := New[int](1*time.Minute, WithCapacity(2), WithPolicy(PolicyLRU))
+		_ = cache.Set("a", 1)
+		_ = cache.Set("b", 2)
+		_, _ = cache.Get("a")
+		_ = cache.Set("c", 3)
+
+		if _, err := cache.Get("b"); err != ErrNotFound {
+			t.Errorf("expected b to be evicted but received %v", err)
+		}
+		if _, err := cache.Get("a"); err != nil {
+			t.Errorf("expected a to remain but received %v", err)
+		}
+	})
+
+	t.Run("lfu evicts the least frequently used entry", func(t *testing.T) {
+		t.Parallel()
+
+		cache := New[int](1*time.Minute, WithCapacity(2), WithPolicy(PolicyLFU))
+		_ = cache.Set("a", 1)
+		_ = cache.Set("b", 2)
+		_, _ = cache.Get("a")
+		_, _ = cache.Get("a")
+		_, _ = cache.Get("b")
+		_ = cache.Set("c", 3)
+
+		if _, err := cache.Get("b"); err != ErrNotFound {
+			t.Errorf("expected b to be evicted but received %v", err)
+		}
+		if _, err := cache.Get("a"); err != nil {
+			t.Errorf("expected a to remain but received %v", err)
+		}
+	})
+
+	t.Run("ttl policy ignores capacity", func(t *testing.T) {
+		t.Parallel()
+
+		cache := New[int](1*time.Minute, WithCapacity(1), WithPolicy(PolicyTTL))
+		_ = cache.Set("a", 1)
+		_ = cache.Set("b", 2)
+
+		if cache.Len() != 2 {
+			t.Errorf("expected 2 entries but received %d", cache.Len())
+		}
+	})
+
+	t.Run("updating a key does not evict", func(t *testing.T) {
+		t.Parallel()
+
+		cache := New[int](1*time.Minute, WithCapacity(2))
+		_ = cache.Set("a", 1)
+		_ = cache.Set("b", 2)
+		_ = cache.Set("a", 3)
+
+		if cache.Len() != 2 {
+			t.Errorf("expected 2 entries but received %d", cache.Len())
+		}
+	})
+}
+
+func TestPurge(t *testing.T) {
+	t.Parallel()
+
+	cache := New[int](1 * time.Minute)
+	_ = cache.Set("a", 1)
+	_ = cache.Set("b", 2)
+	cache.Purge()
+
+	if cache.Len() != 0 {
+		t.Errorf("expected 0 entries but received %d", cache.Len())
+	}
+}
+
+func TestKeys(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	cache := New[int](1*time.Minute, WithClock(clock.Now))
+	_ = cache.Set("b", 1)
+	_ = cache.Set("a", 2)
+	_ = cache.SetWithTTL("expired", 3, 1*time.Second)
+	clock.Advance(1 * time.Second)
+
+	if keys := cache.Keys(); !slices.Equal(keys, []string{"a", "b"}) {
+		t.Errorf("expected [a b] but received %v", keys)
+	}
+}
+
+func TestOnEvict(t *testing.T) {
+	t.Parallel()
+
+	type eviction struct {
+		key    string
+		value  int
+		reason EvictionReason
+	}
+	evictions := make([]eviction, 0)
+
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	cache := New[int](1*time.Minute,
+		WithClock(clock.Now),
+		WithCapacity(1),
+		WithOnEvict(func(key string, value int, reason EvictionReason) {
+			evictions = append(evictions, eviction{key, value, reason})
+		}))
+
+	_ = cache.Set("a", 1)
+	_ = cache.Set("b", 2)
+	cache.Delete("b")
+	_ = cache.Set("c", 3)
+	clock.Advance(1 * time.Minute)
+	_, _ = cache.Get("c")
+
+	expected := []eviction{
+		{"a", 1, ReasonCapacity},
+		{"b", 2, ReasonDeleted},
+		{"c", 3, ReasonExpired},
+	}
+	if len(evictions) != len(expected) {
+		t.Fatalf("expected %v but received %v", expected, evictions)
+	}
+	for i := range expected {
+		if evictions[i] != expected[i] {
+			t.Errorf("expected %v but received %v", expected[i], evictions[i])
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	t.Parallel()
+
+	cache := New[int](1*time.Minute, WithCapacity(1))
+	_ = cache.Set("a", 1)
+	_, _ = cache.Get("a")
+	_, _ = cache.Get("b")
+	_ = cache.Set("b", 2)
+
+	stats := cache.Stats()
+	expected := Stats{Hits: 1, Misses: 1, Evictions: 1, Entries: 1}
+	if stats != expected {
+		t.Errorf("expected %+v but received %+v", expected, stats)
+	}
+	if stats.HitRate() != 0.5 {
+		t.Errorf("expected hit rate to be 0.5 but received %v", stats.HitRate())
+	}
+}
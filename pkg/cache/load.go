@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Loader loads the value of a key missing from the cache.
+type Loader[T any] func(ctx context.Context, key string) (T, error)
+
+// WithNegativeCache caches errors returned by loaders for the TTL when cacheable reports true,
+// so that GetOrLoad returns them without calling the loader again.
+// Context errors are never cached.
+func WithNegativeCache(ttl time.Duration, cacheable func(error) bool) Option {
+	return func(s *settings) {
+		s.negativeTTL = ttl
+		s.negativeCacheable = cacheable
+	}
+}
+
+// WithLoadTimeout sets the timeout of the loads of GetOrLoad, which are not canceled with their callers.
+func WithLoadTimeout(timeout time.Duration) Option {
+	return func(s *settings) {
+		if timeout > 0 {
+			s.loadTimeout = timeout
+		}
+	}
+}
+
+type negative struct {
+	err       error
+	expiresAt time.Time
+}
+
+// call is a load in progress shared by every caller of the key.
+type call[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+
+	// invalidated is whether the key was set or deleted during the load, in which case the result is not cached
+	// because it may be older. It is guarded by the lock of the cache.
+	invalidated bool
+}
+
+// GetOrLoad returns the cached value of the key, or loads it with the loader and caches it.
+// Concurrent calls for the same key share a single load.
+// The loader receives the values of the context of the caller which started the load, but it is neither
+// canceled with it nor with any other caller, so that the remaining callers can still use the result.
+// It is bounded by the load timeout instead, and each caller stops waiting when its own context is done.
+func (c *Cache[T]) GetOrLoad(ctx context.Context, key string, loader Loader[T]) (T, error) {
+	if value, err := c.Get(key); err == nil {
+		return value, nil
+	}
+
+	c.mu.Lock()
+	if neg, ok := c.negatives[key]; ok {
+		if c.settings.now().Before(neg.expiresAt) {
+			c.stats.NegativeHits++
+			c.mu.Unlock()
+			var zero T
+			return zero, neg.err
+		}
+		delete(c.negatives, key)
+	}
+	cl, inflight := c.calls[key]
+	if !inflight {
+		cl = &call[T]{done: make(chan struct{})}
+		c.calls[key] = cl
+		c.stats.Loads++
+	} else {
+		c.stats.Coalesced++
+	}
+	c.mu.Unlock()
+
+	if !inflight {
+		go c.load(ctx, key, loader, cl)
+	}
+
+	select {
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	case <-cl.done:
+		return cl.value, cl.err
+	}
+}
+
+func (c *Cache[T]) load(ctx context.Context, key string, loader Loader[T], cl *call[T]) {
+	defer close(cl.done)
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.settings.loadTimeout)
+	defer cancel()
+	cl.value, cl.err = loader(ctx, key)
+
+	c.mu.Lock()
+	delete(c.calls, key)
+	if cl.invalidated {
+		c.mu.Unlock()
+		return
+	}
+	var expiresAt time.Time
+	if cl.err == nil {
+		expiresAt = c.setLocal(key, cl.value, DefaultExpiration)
+	} else if c.cacheableError(cl.err) {
+		c.negatives[key] = negative{
+			err:       cl.err,
+			expiresAt: c.settings.now().Add(c.settings.negativeTTL),
+		}
+	}
+	c.mu.Unlock()
+
+	if cl.err == nil {
+		c.setRemote(key, cl.value, expiresAt)
+	}
+}
+
+// invalidateCalls prevents the results of the loads in progress of the keys from being cached.
+// The caller must hold the lock.
+func (c *Cache[T]) invalidateCalls(match func(key string) bool) {
+	for key, cl := range c.calls {
+		if match(key) {
+			cl.invalidated = true
+		}
+	}
+}
+
+// invalidateCall prevents the result of the load in progress of the key from being cached.
+// The caller must hold the lock.
+func (c *Cache[T]) invalidateCall(key string) {
+	if cl, ok := c.calls[key]; ok {
+		cl.invalidated = true
+	}
+}
+
+func (c *Cache[T]) cacheableError(err error) bool {
+	if c.settings.negativeTTL <= 0 || c.settings.negativeCacheable == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	return c.settings.negativeCacheable(err)
+}
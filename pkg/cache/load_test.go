@@ -0,0 +1,243 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errForbidden = errors.New("forbidden")
+
+func TestGetOrLoad(t *testing.T) {
+	t.Parallel()
+
+	t.Run("loaded value is cached", func(t *testing.T) {
+		t.Parallel()
+
+		cache := New[int](1 * time.Minute)
+		calls := 0
+		loader := func(context.Context, string) (int, error) {
+			calls++
+			return 1, nil
+		}
+
+		for i := 0; i < 3; i++ {
+			value, err := cache.GetOrLoad(context.Background(), "key", loader)
+			if err != nil {
+				t.Errorf("expected err to be nil but received %v", err)
+			}
+			if value != 1 {
+				t.Errorf("expected value to be 1 but received %v", value)
+			}
+		}
+		if calls != 1 {
+			t.Errorf("expected loader to be called once but called %d times", calls)
+		}
+	})
+
+	t.Run("concurrent loads are coalesced", func(t *testing.T) {
+		t.Parallel()
+
+		cache := New[int](1 * time.Minute)
+		release := make(chan struct{})
+		var calls atomic.Int32
+		loader := func(context.Context, string) (int, error) {
+			calls.Add(1)
+			<-release
+			return 1, nil
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := cache.GetOrLoad(context.Background(), "key", loader); err != nil {
+					t.Errorf("expected err to be nil but received %v", err)
+				}
+			}()
+		}
+
+		// wait until every caller has joined the load.
+		for cache.Stats().Loads+cache.Stats().Coalesced < 10 {
+			time.Sleep(time.Millisecond)
+		}
+		close(release)
+		wg.Wait()
+
+		if calls.Load() != 1 {
+			t.Errorf("expected loader to be called once but called %d times", calls.Load())
+		}
+	})
+
+	t.Run("errors are cached when cacheable", func(t *testing.T) {
+		t.Parallel()
+
+		clock := &fakeClock{now: time.Unix(0, 0)}
+		cache := New[int](1*time.Minute,
+			WithClock(clock.Now),
+			WithNegativeCache(10*time.Second, func(err error) bool {
+				return errors.Is(err, errForbidden)
+			}))
+		calls := 0
+		loader := func(context.Context, string) (int, error) {
+			calls++
+			return 0, errForbidden
+		}
+
+		for i := 0; i < 3; i++ {
+			if _, err := cache.GetOrLoad(context.Background(), "key", loader); !errors.Is(err, errForbidden) {
+				t.Errorf("expected err to be %v but received %v", errForbidden, err)
+			}
+		}
+		if calls != 1 {
+			t.Errorf("expected loader to be called once but called %d times", calls)
+		}
+
+		clock.Advance(10 * time.Second)
+		_, _ = cache.GetOrLoad(context.Background(), "key", loader)
+		if calls != 2 {
+			t.Errorf("expected loader to be called again after the negative ttl but called %d times", calls)
+		}
+	})
+
+	t.Run("other errors are not cached", func(t *testing.T) {
+		t.Parallel()
+
+		cache := New[int](1*time.Minute, WithNegativeCache(10*time.Second, func(err error) bool {
+			return errors.Is(err, errForbidden)
+		}))
+		calls := 0
+		loader := func(context.Context, string) (int, error) {
+			calls++
+			return 0, errors.New("temporary")
+		}
+
+		_, _ = cache.GetOrLoad(context.Background(), "key", loader)
+		_, _ = cache.GetOrLoad(context.Background(), "key", loader)
+		if calls != 2 {
+			t.Errorf("expected loader to be called twice but called %d times", calls)
+		}
+	})
+
+	t.Run("delete forgets the cached error", func(t *testing.T) {
+		t.Parallel()
+
+		cache := New[int](1*time.Minute, WithNegativeCache(10*time.Second, func(error) bool { return true }))
+		_, _ = cache.GetOrLoad(context.Background(), "key", func(context.Context, string) (int, error) {
+			return 0, errForbidden
+		})
+		cache.Delete("key")
+
+		value, err := cache.GetOrLoad(context.Background(), "key", func(context.Context, string) (int, error) {
+			return 1, nil
+		})
+		if err != nil || value != 1 {
+			t.Errorf("expected (1, nil) but received (%v, %v)", value, err)
+		}
+	})
+
+	t.Run("caller stops waiting when its context is done", func(t *testing.T) {
+		t.Parallel()
+
+		cache := New[int](1 * time.Minute)
+		release := make(chan struct{})
+		defer close(release)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err := cache.GetOrLoad(ctx, "key", func(context.Context, string) (int, error) {
+			<-release
+			return 1, nil
+		})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected err to be %v but received %v", context.DeadlineExceeded, err)
+		}
+	})
+	t.Run("load is not canceled with the caller which started it", func(t *testing.T) {
+		t.Parallel()
+
+		cache := New[int](1 * time.Minute)
+		started, release := make(chan struct{}), make(chan struct{})
+		loader := func(ctx context.Context, _ string) (int, error) {
+			close(started)
+			<-release
+			return 1, ctx.Err()
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		first := make(chan error)
+		go func() {
+			_, err := cache.GetOrLoad(ctx, "key", loader)
+			first <- err
+		}()
+		<-started
+		second := make(chan error)
+		go func() {
+			_, err := cache.GetOrLoad(context.Background(), "key", loader)
+			second <- err
+		}()
+		for cache.Stats().Coalesced < 1 {
+			time.Sleep(time.Millisecond)
+		}
+
+		cancel()
+		if err := <-first; !errors.Is(err, context.Canceled) {
+			t.Errorf("expected err to be %v but received %v", context.Canceled, err)
+		}
+		close(release)
+		if err := <-second; err != nil {
+			t.Errorf("expected the other caller to receive the value but received %v", err)
+		}
+	})
+
+	t.Run("load is bounded by the load timeout", func(t *testing.T) {
+		t.Parallel()
+
+		cache := New[int](1*time.Minute, WithLoadTimeout(10*time.Millisecond))
+		_, err := cache.GetOrLoad(context.Background(), "key", func(ctx context.Context, _ string) (int, error) {
+			<-ctx.Done()
+			return 0, ctx.Err()
+		})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected err to be %v but received %v", context.DeadlineExceeded, err)
+		}
+	})
+
+	t.Run("value loaded while the key is invalidated is not cached", func(t *testing.T) {
+		t.Parallel()
+
+		for name, invalidate := range map[string]func(c *Cache[int]){
+			"delete":  func(c *Cache[int]) { c.Delete("key") },
+			"replace": func(c *Cache[int]) { _ = c.Replace("key", 2) },
+			"prefix":  func(c *Cache[int]) { c.DeletePrefix("k") },
+			"purge":   func(c *Cache[int]) { _ = c.Purge() },
+		} {
+			cache := New[int](1 * time.Minute)
+			started, release := make(chan struct{}), make(chan struct{})
+			done := make(chan int)
+			go func() {
+				value, _ := cache.GetOrLoad(context.Background(), "key", func(context.Context, string) (int, error) {
+					close(started)
+					<-release
+					return 1, nil
+				})
+				done <- value
+			}()
+
+			<-started
+			invalidate(cache)
+			close(release)
+			if value := <-done; value != 1 {
+				t.Errorf("%s: expected the caller to receive the loaded value but received %v", name, value)
+			}
+			if _, err := cache.Get("key"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("%s: expected the loaded value not to be cached but received %v", name, err)
+			}
+		}
+	})
+}
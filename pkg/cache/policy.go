@@ -0,0 +1,211 @@
+package cache
+
+import (
+	"container/list"
+)
+
+// Policy selects which entry is evicted when the cache is full.
+type Policy int
+
+const (
+	// PolicyTTL removes entries only when they expire. The capacity is ignored.
+	PolicyTTL Policy = iota
+
+	// PolicyLRU evicts the least recently used entry.
+	PolicyLRU
+
+	// PolicyLFU evicts the least frequently used entry. Ties are broken by recency.
+	PolicyLFU
+)
+
+func (p Policy) String() string {
+	switch p {
+	case PolicyTTL:
+		return "ttl"
+	case PolicyLRU:
+		return "lru"
+	case PolicyLFU:
+		return "lfu"
+	default:
+		return "unknown"
+	}
+}
+
+// ParsePolicy converts the name of a policy such as "lru" into a Policy.
+func ParsePolicy(name string) (Policy, bool) {
+	for _, p := range []Policy{PolicyTTL, PolicyLRU, PolicyLFU} {
+		if p.String() == name {
+			return p, true
+		}
+	}
+	return PolicyTTL, false
+}
+
+// tracker records the usage of keys and chooses the victim of an eviction.
+type tracker interface {
+	add(key string)
+	touch(key string)
+	remove(key string)
+	victim() (string, bool)
+	reset()
+}
+
+func newTracker(p Policy) tracker {
+	switch p {
+	case PolicyLRU:
+		return newLRU()
+	case PolicyLFU:
+		return newLFU()
+	default:
+		return nopTracker{}
+	}
+}
+
+type nopTracker struct{}
+
+func (nopTracker) add(string)             {}
+func (nopTracker) touch(string)           {}
+func (nopTracker) remove(string)          {}
+func (nopTracker) victim() (string, bool) { return "", false }
+func (nopTracker) reset()                 {}
+
+// lru keeps keys ordered from the most recently used to the least recently used.
+type lru struct {
+	order    *list.List
+	elements map[string]*list.Element
+}
+
+func newLRU() *lru {
+	return &lru{
+		order:    list.New(),
+		elements: make(map[string]*list.Element),
+	}
+}
+
+func (l *lru) add(key string) {
+	if elem, ok := l.elements[key]; ok {
+		l.order.MoveToFront(elem)
+		return
+	}
+	l.elements[key] = l.order.PushFront(key)
+}
+
+func (l *lru) touch(key string) {
+	if elem, ok := l.elements[key]; ok {
+		l.order.MoveToFront(elem)
+	}
+}
+
+func (l *lru) remove(key string) {
+	if elem, ok := l.elements[key]; ok {
+		l.order.Remove(elem)
+		delete(l.elements, key)
+	}
+}
+
+func (l *lru) victim() (string, bool) {
+	elem := l.order.Back()
+	if elem == nil {
+		return "", false
+	}
+	return elem.Value.(string), true
+}
+
+func (l *lru) reset() {
+	l.order.Init()
+	clear(l.elements)
+}
+
+// lfu keeps a list of keys per use count, each ordered from the most recently used.
+type lfu struct {
+	nodes   map[string]*lfuNode
+	freqs   map[uint64]*list.List
+	minFreq uint64
+}
+
+type lfuNode struct {
+	freq uint64
+	elem *list.Element
+}
+
+func newLFU() *lfu {
+	return &lfu{
+		nodes: make(map[string]*lfuNode),
+		freqs: make(map[uint64]*list.List),
+	}
+}
+
+func (l *lfu) push(key string, freq uint64) *list.Element {
+	bucket, ok := l.freqs[freq]
+	if !ok {
+		bucket = list.New()
+		l.freqs[freq] = bucket
+	}
+	return bucket.PushFront(key)
+}
+
+func (l *lfu) unlink(node *lfuNode) {
+	bucket := l.freqs[node.freq]
+	bucket.Remove(node.elem)
+	if bucket.Len() == 0 {
+		delete(l.freqs, node.freq)
+		if l.minFreq == node.freq {
+			l.minFreq++
+		}
+	}
+}
+
+func (l *lfu) add(key string) {
+	if _, ok := l.nodes[key]; ok {
+		l.touch(key)
+		return
+	}
+	l.nodes[key] = &lfuNode{freq: 1, elem: l.push(key, 1)}
+	l.minFreq = 1
+}
+
+func (l *lfu) touch(key string) {
+	node, ok := l.nodes[key]
+	if !ok {
+		return
+	}
+	l.unlink(node)
+	node.freq++
+	node.elem = l.push(key, node.freq)
+}
+
+func (l *lfu) remove(key string) {
+	node, ok := l.nodes[key]
+	if !ok {
+		return
+	}
+	l.unlink(node)
+	delete(l.nodes, key)
+	if len(l.nodes) == 0 {
+		l.minFreq = 0
+		return
+	}
+	// removing an arbitrary key may leave minFreq pointing to a missing bucket.
+	if _, ok := l.freqs[l.minFreq]; !ok {
+		l.minFreq = 0
+		for freq := range l.freqs {
+			if l.minFreq == 0 || freq < l.minFreq {
+				l.minFreq = freq
+			}
+		}
+	}
+}
+
+func (l *lfu) victim() (string, bool) {
+	bucket, ok := l.freqs[l.minFreq]
+	if !ok || bucket.Len() == 0 {
+		return "", false
+	}
+	return bucket.Back().Value.(string), true
+}
+
+func (l *lfu) reset() {
+	clear(l.nodes)
+	clear(l.freqs)
+	l.minFreq = 0
+}
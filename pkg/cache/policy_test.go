@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"testing"
+)
+
+func TestParsePolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		want Policy
+		ok   bool
+	}{
+		{"ttl", PolicyTTL, true},
+		{"lru", PolicyLRU, true},
+		{"lfu", PolicyLFU, true},
+		{"fifo", PolicyTTL, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			actual, ok := ParsePolicy(tt.name)
+			if actual != tt.want || ok != tt.ok {
+				t.Errorf("expected (%v, %v) but received (%v, %v)", tt.want, tt.ok, actual, ok)
+			}
+		})
+	}
+}
+
+func TestLFU(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ties are broken by recency", func(t *testing.T) {
+		t.Parallel()
+
+		l := newLFU()
+		l.add("a")
+		l.add("b")
+		l.add("c")
+
+		if victim, _ := l.victim(); victim != "a" {
+			t.Errorf("expected a to be the victim but received %s", victim)
+		}
+	})
+
+	t.Run("removing the least frequent key updates the minimum", func(t *testing.T) {
+		t.Parallel()
+
+		l := newLFU()
+		l.add("a")
+		l.add("b")
+		l.touch("b")
+		l.touch("b")
+		l.add("c")
+		l.touch("c")
+		l.remove("a")
+
+		if victim, _ := l.victim(); victim != "c" {
+			t.Errorf("expected c to be the victim but received %s", victim)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		t.Parallel()
+
+		l := newLFU()
+		if _, ok := l.victim(); ok {
+			t.Error("expected no victim")
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var _ Backend = (*RedisBackend)(nil)
+
+// RedisOption is a function that configures a RedisBackend.
+type RedisOption func(*redis.Options)
+
+// WithRedisPassword sets the password sent with AUTH after connecting.
+func WithRedisPassword(password string) RedisOption {
+	return func(o *redis.Options) {
+		o.Password = password
+	}
+}
+
+// WithRedisDB sets the database selected after connecting.
+func WithRedisDB(db int) RedisOption {
+	return func(o *redis.Options) {
+		o.DB = db
+	}
+}
+
+// WithRedisDialTimeout sets the timeout to connect to the server.
+func WithRedisDialTimeout(timeout time.Duration) RedisOption {
+	return func(o *redis.Options) {
+		if timeout > 0 {
+			o.DialTimeout = timeout
+		}
+	}
+}
+
+// WithRedisPoolSize sets the maximum number of connections to the server.
+func WithRedisPoolSize(size int) RedisOption {
+	return func(o *redis.Options) {
+		if size > 0 {
+			o.PoolSize = size
+		}
+	}
+}
+
+// RedisBackend is a Backend which stores the entries in Redis.
+// Connections are established lazily, so creating the backend never fails.
+type RedisBackend struct {
+	client *redis.Client
+}
+
+func NewRedisBackend(addr string, option ...RedisOption) *RedisBackend {
+	opts := &redis.Options{
+		Addr:        addr,
+		DialTimeout: 1 * time.Second,
+	}
+	for _, opt := range option {
+		opt(opts)
+	}
+	return &RedisBackend{client: redis.NewClient(opts)}
+}
+
+func (b *RedisBackend) Get(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	pipe := b.client.Pipeline()
+	get := pipe.Get(ctx, key)
+	pttl := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, 0, err
+	}
+
+	value, err := get.Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, 0, ErrNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	ttl, err := pttl.Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	switch {
+	case ttl == -2:
+		// the key expired between GET and PTTL.
+		return nil, 0, ErrNotFound
+	case ttl < 0:
+		// the key has no expiration.
+		ttl = 0
+	}
+	return value, ttl, nil
+}
+
+func (b *RedisBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	// go-redis keeps the current expiration for a negative ttl, while the entries without ttl never expire.
+	return b.client.Set(ctx, key, value, max(ttl, 0)).Err()
+}
+
+func (b *RedisBackend) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return b.client.Del(ctx, keys...).Err()
+}
+
+// scanCount is the number of keys examined by each SCAN of DeletePrefix.
+const scanCount = 500
+
+// DeletePrefix removes the keys matching the prefix with SCAN and DEL, so that the server is never blocked
+// as long as with KEYS. Keys added while it runs may be left.
+func (b *RedisBackend) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	pattern := redisGlobEscaper.Replace(prefix) + "*"
+	deleted := 0
+	var cursor uint64
+	for {
+		keys, next, err := b.client.Scan(ctx, cursor, pattern, scanCount).Result()
+		if err != nil {
+			return deleted, err
+		}
+		if len(keys) > 0 {
+			n, err := b.client.Del(ctx, keys...).Result()
+			if err != nil {
+				return deleted, err
+			}
+			deleted += int(n)
+		}
+		if next == 0 {
+			return deleted, nil
+		}
+		cursor = next
+	}
+}
+
+// redisGlobEscaper escapes the characters which have a meaning in the patterns of Redis.
+var redisGlobEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+// Ping checks the connection to the server.
+func (b *RedisBackend) Ping(ctx context.Context) error {
+	return b.client.Ping(ctx).Err()
+}
+
+func (b *RedisBackend) Close() error {
+	return b.client.Close()
+}
@@ -0,0 +1,217 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisBackend(t *testing.T) {
+	t.Parallel()
+
+	t.Run("set, get and delete", func(t *testing.T) {
+		t.Parallel()
+
+		srv := miniredis.RunT(t)
+		backend := NewRedisBackend(srv.Addr())
+		defer backend.Close()
+		ctx := context.Background()
+
+		if err := backend.Ping(ctx); err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		if err := backend.Set(ctx, "key", []byte("value\r\nwith crlf"), 1*time.Minute); err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+
+		value, ttl, err := backend.Get(ctx, "key")
+		if err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		if string(value) != "value\r\nwith crlf" {
+			t.Errorf("expected value to survive the round trip but received %q", value)
+		}
+		if ttl <= 0 || ttl > 1*time.Minute {
+			t.Errorf("expected ttl to be in (0, 1m] but received %v", ttl)
+		}
+
+		if err := backend.Delete(ctx, "key"); err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		if _, _, err := backend.Get(ctx, "key"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected err to be %v but received %v", ErrNotFound, err)
+		}
+	})
+
+	t.Run("entries without ttl", func(t *testing.T) {
+		t.Parallel()
+
+		srv := miniredis.RunT(t)
+		backend := NewRedisBackend(srv.Addr())
+		defer backend.Close()
+
+		if err := backend.Set(context.Background(), "key", []byte("value"), 0); err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		if _, ttl, err := backend.Get(context.Background(), "key"); err != nil || ttl != 0 {
+			t.Errorf("expected (0, nil) but received (%v, %v)", ttl, err)
+		}
+	})
+
+	t.Run("expired entries", func(t *testing.T) {
+		t.Parallel()
+
+		srv := miniredis.RunT(t)
+		backend := NewRedisBackend(srv.Addr())
+		defer backend.Close()
+
+		if err := backend.Set(context.Background(), "key", []byte("value"), 1*time.Minute); err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		srv.FastForward(2 * time.Minute)
+		if _, _, err := backend.Get(context.Background(), "key"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected err to be %v but received %v", ErrNotFound, err)
+		}
+	})
+
+	t.Run("authentication", func(t *testing.T) {
+		t.Parallel()
+
+		srv := miniredis.RunT(t)
+		srv.RequireAuth("secret")
+
+		backend := NewRedisBackend(srv.Addr(), WithRedisPassword("secret"), WithRedisDB(1))
+		defer backend.Close()
+		if err := backend.Set(context.Background(), "key", []byte("value"), 0); err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		if value, err := srv.DB(1).Get("key"); err != nil || value != "value" {
+			t.Errorf("expected the entry to be stored in the database 1 but received (%q, %v)", value, err)
+		}
+
+		wrong := NewRedisBackend(srv.Addr(), WithRedisPassword("wrong"))
+		defer wrong.Close()
+		var redisErr redis.Error
+		if err := wrong.Ping(context.Background()); !errors.As(err, &redisErr) {
+			t.Errorf("expected a redis error but received %v", err)
+		}
+	})
+
+	t.Run("unreachable server", func(t *testing.T) {
+		t.Parallel()
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("failed to listen: %v", err)
+		}
+		addr := listener.Addr().String()
+		_ = listener.Close()
+
+		backend := NewRedisBackend(addr, WithRedisDialTimeout(100*time.Millisecond))
+		if _, _, err := backend.Get(context.Background(), "key"); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("expected a connection error but received %v", err)
+		}
+	})
+
+	t.Run("cache falls back to memory", func(t *testing.T) {
+		t.Parallel()
+
+		srv := miniredis.RunT(t)
+		backend := NewRedisBackend(srv.Addr())
+		defer backend.Close()
+
+		writer := New[sample](1*time.Minute, WithBackend(PrefixBackend(backend, "test:"), GobCodec{}))
+		reader := New[sample](1*time.Minute, WithBackend(PrefixBackend(backend, "test:"), GobCodec{}))
+		if err := writer.Set("key", sample{Name: "felm", Count: 1}); err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		if value, err := reader.Get("key"); err != nil || value.Count != 1 {
+			t.Errorf("expected the entry to be shared through redis but received (%+v, %v)", value, err)
+		}
+
+		srv.Close()
+		_ = backend.Close()
+		if err := writer.Set("other", sample{Name: "offline"}); err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		if value, err := writer.Get("other"); err != nil || value.Name != "offline" {
+			t.Errorf("expected the entry to be served from memory but received (%+v, %v)", value, err)
+		}
+	})
+	t.Run("delete by prefix", func(t *testing.T) {
+		t.Parallel()
+
+		srv := miniredis.RunT(t)
+		backend := NewRedisBackend(srv.Addr())
+		defer backend.Close()
+		ctx := context.Background()
+
+		for _, key := range []string{"guild/1", "guild/2", "guild/3", "guild*/4", "other/1"} {
+			if err := backend.Set(ctx, key, []byte("value"), 0); err != nil {
+				t.Fatalf("expected err to be nil but received %v", err)
+			}
+		}
+		deleted, err := backend.DeletePrefix(ctx, "guild/")
+		if err != nil || deleted != 3 {
+			t.Errorf("expected 3 keys to be deleted but received (%d, %v)", deleted, err)
+		}
+		for key, want := range map[string]error{"guild/1": ErrNotFound, "guild/3": ErrNotFound, "guild*/4": nil, "other/1": nil} {
+			if _, _, err := backend.Get(ctx, key); !errors.Is(err, want) {
+				t.Errorf("expected err of %s to be %v but received %v", key, want, err)
+			}
+		}
+	})
+
+	t.Run("replace after restart", func(t *testing.T) {
+		t.Parallel()
+
+		srv := miniredis.RunT(t)
+		backend := NewRedisBackend(srv.Addr())
+		defer backend.Close()
+
+		before := New[sample](1*time.Minute, WithBackend(PrefixBackend(backend, "test:"), GobCodec{}))
+		if err := before.Set("key", sample{Name: "stale"}); err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+
+		// the process restarted, so the entry is only in redis when the update arrives.
+		after := New[sample](1*time.Minute, WithBackend(PrefixBackend(backend, "test:"), GobCodec{}))
+		if err := after.Replace("key", sample{Name: "fresh"}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected err to be %v but received %v", ErrNotFound, err)
+		}
+		if value, err := after.Get("key"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected the stale entry to be deleted from redis but received (%+v, %v)", value, err)
+		}
+	})
+
+	t.Run("purge deletes the entries of the cache only", func(t *testing.T) {
+		t.Parallel()
+
+		srv := miniredis.RunT(t)
+		backend := NewRedisBackend(srv.Addr())
+		defer backend.Close()
+
+		cache := New[sample](1*time.Minute, WithBackend(PrefixBackend(backend, "test:"), GobCodec{}))
+		other := New[sample](1*time.Minute, WithBackend(PrefixBackend(backend, "other:"), GobCodec{}))
+		for _, key := range []string{"1", "2", "3"} {
+			_ = cache.Set(key, sample{Name: key})
+		}
+		_ = other.Set("1", sample{Name: "other"})
+
+		if err := cache.Purge(); err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		restarted := New[sample](1*time.Minute, WithBackend(PrefixBackend(backend, "test:"), GobCodec{}))
+		if value, err := restarted.Get("2"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected the entry to be purged from redis but received (%+v, %v)", value, err)
+		}
+		if _, _, err := backend.Get(context.Background(), "other:1"); err != nil {
+			t.Errorf("expected the entries of the other cache to be kept but received %v", err)
+		}
+	})
+}
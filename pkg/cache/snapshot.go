@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotVersion is the version of the snapshot format.
+// It must be incremented whenever the format changes incompatibly.
+const snapshotVersion = 1
+
+// ErrIncompatibleSnapshot is returned when a snapshot was written by an incompatible version or for another type.
+var ErrIncompatibleSnapshot = errors.New("incompatible snapshot")
+
+type snapshot struct {
+	Version int             `json:"version"`
+	Type    string          `json:"type"`
+	SavedAt time.Time       `json:"saved_at"`
+	Entries []snapshotEntry `json:"entries"`
+}
+
+type snapshotEntry struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+
+	// ExpiresAt is omitted for entries which never expire.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+func typeName[T any]() string {
+	var zero T
+	return fmt.Sprintf("%T", zero)
+}
+
+// Snapshot writes the unexpired entries held in memory to w.
+func (c *Cache[T]) Snapshot(w io.Writer) (int, error) {
+	c.mu.Lock()
+	now := c.settings.now()
+	snap := snapshot{
+		Version: snapshotVersion,
+		Type:    typeName[T](),
+		SavedAt: now,
+		Entries: make([]snapshotEntry, 0, len(c.items)),
+	}
+	for key, it := range c.items {
+		if it.expired(now) {
+			continue
+		}
+		value, err := json.Marshal(it.value)
+		if err != nil {
+			c.mu.Unlock()
+			return 0, fmt.Errorf("failed to encode the entry (key = %s): %w", key, err)
+		}
+		entry := snapshotEntry{Key: key, Value: value}
+		if !it.expiresAt.IsZero() {
+			expiresAt := it.expiresAt
+			entry.ExpiresAt = &expiresAt
+		}
+		snap.Entries = append(snap.Entries, entry)
+	}
+	c.mu.Unlock()
+
+	if err := json.NewEncoder(w).Encode(snap); err != nil {
+		return 0, fmt.Errorf("failed to write the snapshot: %w", err)
+	}
+	return len(snap.Entries), nil
+}
+
+// Restore loads the entries written by Snapshot, keeping their remaining TTLs.
+// Entries which have expired since the snapshot was taken are skipped.
+// It returns ErrIncompatibleSnapshot without restoring anything if the snapshot can not be read.
+func (c *Cache[T]) Restore(r io.Reader) (int, error) {
+	var snap snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrIncompatibleSnapshot, err)
+	}
+	if snap.Version != snapshotVersion {
+		return 0, fmt.Errorf("%w: version %d is not supported", ErrIncompatibleSnapshot, snap.Version)
+	}
+	if snap.Type != typeName[T]() {
+		return 0, fmt.Errorf("%w: snapshot of %s can not be restored to %s", ErrIncompatibleSnapshot, snap.Type, typeName[T]())
+	}
+
+	// decode every entry before touching the cache so that a broken snapshot is ignored as a whole.
+	type decoded struct {
+		key       string
+		value     T
+		expiresAt *time.Time
+	}
+	entries := make([]decoded, 0, len(snap.Entries))
+	for _, entry := range snap.Entries {
+		var value T
+		if err := json.Unmarshal(entry.Value, &value); err != nil {
+			return 0, fmt.Errorf("%w: failed to decode the entry (key = %s): %w", ErrIncompatibleSnapshot, entry.Key, err)
+		}
+		entries = append(entries, decoded{key: entry.Key, value: value, expiresAt: entry.ExpiresAt})
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.settings.now()
+	restored := 0
+	for _, entry := range entries {
+		ttl := NoExpiration
+		if entry.expiresAt != nil {
+			ttl = entry.expiresAt.Sub(now)
+			if ttl <= 0 {
+				continue
+			}
+		}
+		c.setLocal(entry.key, entry.value, ttl)
+		restored++
+	}
+	return restored, nil
+}
+
+// SaveFile writes the snapshot to the file. The file is replaced atomically.
+func (c *Cache[T]) SaveFile(path string) (int, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create a temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := c.Snapshot(tmp)
+	if err != nil {
+		_ = tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close the temporary file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, fmt.Errorf("failed to replace the snapshot: %w", err)
+	}
+	return n, nil
+}
+
+// LoadFile restores the snapshot from the file.
+// A missing file is not an error because the cache has never been saved.
+func (c *Cache[T]) LoadFile(path string) (int, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open the snapshot: %w", err)
+	}
+	defer f.Close()
+
+	return c.Restore(f)
+}
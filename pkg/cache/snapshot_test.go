@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	t.Parallel()
+
+	t.Run("remaining ttl is preserved", func(t *testing.T) {
+		t.Parallel()
+
+		clock := &fakeClock{now: time.Unix(0, 0)}
+		src := New[sample](1*time.Minute, WithClock(clock.Now))
+		_ = src.Set("short", sample{Name: "short"})
+		_ = src.SetWithTTL("long", sample{Name: "long"}, 1*time.Hour)
+		_ = src.SetWithTTL("forever", sample{Name: "forever"}, NoExpiration)
+
+		var buf bytes.Buffer
+		n, err := src.Snapshot(&buf)
+		if err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		if n != 3 {
+			t.Errorf("expected 3 entries to be saved but received %d", n)
+		}
+
+		clock.Advance(30 * time.Minute)
+		dst := New[sample](1*time.Minute, WithClock(clock.Now))
+		n, err = dst.Restore(&buf)
+		if err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		if n != 2 {
+			t.Errorf("expected 2 entries to be restored but received %d", n)
+		}
+
+		if _, err := dst.Get("short"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected short to be skipped but received %v", err)
+		}
+		if value, err := dst.Get("long"); err != nil || value.Name != "long" {
+			t.Errorf("expected long to be restored but received (%+v, %v)", value, err)
+		}
+
+		clock.Advance(30 * time.Minute)
+		if _, err := dst.Get("long"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected long to expire at its original time but received %v", err)
+		}
+		if _, err := dst.Get("forever"); err != nil {
+			t.Errorf("expected forever to be restored but received %v", err)
+		}
+	})
+
+	t.Run("incompatible snapshots are rejected", func(t *testing.T) {
+		t.Parallel()
+
+		tests := []struct {
+			name string
+			data string
+		}{
+			{"broken", `{"version":`},
+			{"unknown version", `{"version":999,"type":"cache.sample","entries":[]}`},
+			{"other type", `{"version":1,"type":"int","entries":[]}`},
+			{"broken entry", `{"version":1,"type":"cache.sample","entries":[{"key":"a","value":{"Name":1}}]}`},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				t.Parallel()
+
+				cache := New[sample](1 * time.Minute)
+				n, err := cache.Restore(strings.NewReader(tt.data))
+				if !errors.Is(err, ErrIncompatibleSnapshot) {
+					t.Errorf("expected err to be %v but received %v", ErrIncompatibleSnapshot, err)
+				}
+				if n != 0 || cache.Len() != 0 {
+					t.Errorf("expected nothing to be restored but received %d", cache.Len())
+				}
+			})
+		}
+	})
+}
+
+func TestSnapshotFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "cache.json")
+
+	empty := New[int](1 * time.Minute)
+	if n, err := empty.LoadFile(path); err != nil || n != 0 {
+		t.Errorf("expected a missing file to be ignored but received (%d, %v)", n, err)
+	}
+
+	src := New[int](1 * time.Minute)
+	_ = src.Set("key", 1)
+	if _, err := src.SaveFile(path); err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected temporary files to be removed but received %d files", len(entries))
+	}
+
+	dst := New[int](1 * time.Minute)
+	if n, err := dst.LoadFile(path); err != nil || n != 1 {
+		t.Errorf("expected 1 entry to be restored but received (%d, %v)", n, err)
+	}
+}
@@ -2,17 +2,29 @@
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aqyuki/felm/pkg/logging"
+	"github.com/aqyuki/felm/pkg/report"
 	"github.com/aqyuki/felm/pkg/trace"
 	"github.com/bwmarrin/discordgo"
 	"go.uber.org/zap"
 )
 
+// EventHandler is a function that handles a gateway event such as *discordgo.MessageCreate.
+type EventHandler[T any] func(context.Context, *discordgo.Session, T) error
+
 // MessageCreateHandler is a function that handles a message creation event.
-type MessageCreateHandler func(context.Context, *discordgo.Session, *discordgo.MessageCreate) error
+type MessageCreateHandler = EventHandler[*discordgo.MessageCreate]
+
+// loggerName is the name of the logger of the connection, whose level can be overridden.
+const loggerName = "discord"
 
 // MinimumHandlerTimeout is the minimum timeout for the handler.
 const MinimumHandlerTimeout = 5 * time.Second
@@ -32,8 +44,29 @@ func WithHandlerTimeout(timeout time.Duration) Option {
 
 // WithMessageCreateHandler adds a handler for the message creation event.
 func WithMessageCreateHandler(handler MessageCreateHandler) Option {
+	return WithEventHandler(handler)
+}
+
+// WithEventHandler adds a handler for the gateway event T.
+// T must be a pointer to an event type which discordgo dispatches, e.g. *discordgo.ChannelUpdate.
+func WithEventHandler[T any](handler EventHandler[T]) Option {
+	return func(c *Conn) {
+		var event T
+		c.handlers = append(c.handlers, registration{
+			intents: intentsFor(event),
+			add: func(c *Conn, s *discordgo.Session) func() {
+				return s.AddHandler(buildEventHandler(c.inflight, c.handlerDeadline, c.reporter, handler))
+			},
+		})
+	}
+}
+
+// WithErrorReporter sets the reporter of the errors returned by the handlers, which logs them by default.
+func WithErrorReporter(reporter report.Reporter) Option {
 	return func(c *Conn) {
-		c.messageCreateHandlers = append(c.messageCreateHandlers, handler)
+		if reporter != nil {
+			c.reporter = reporter
+		}
 	}
 }
 
@@ -46,123 +79,407 @@ func WithBaseContext(ctx context.Context) Option {
 	}
 }
 
-// Conn manages the session with the Discord API.
+// registration is a handler waiting to be registered to the session.
+type registration struct {
+	// intents are the intents required to receive the event.
+	intents discordgo.Intent
+
+	// add registers the handler and returns the function to unregister it.
+	add func(*Conn, *discordgo.Session) func()
+}
+
+// Conn manages the sessions of the shards with the Discord API.
 type Conn struct {
-	session               *discordgo.Session
-	messageCreateHandlers []MessageCreateHandler
-	preClose              []func()
+	token    string
+	shards   []*shard
+	handlers []registration
+
+	// shardCount is the number of shards. ShardCountAuto uses the number recommended by Discord.
+	shardCount int
+
+	// intents are the intents requested in addition to the ones required by the handlers.
+	intents discordgo.Intent
+
+	// ratelimiter is shared by the sessions of every shard, because REST rate limits are per bot.
+	ratelimiter *discordgo.RateLimiter
 
 	// handlerDeadline is the timeout for the handler.
 	handlerDeadline time.Duration
 
+	// reporter receives the errors returned by the handlers.
+	reporter report.Reporter
+
 	// baseContext is the base context for the handler.
 	baseContext context.Context
+
+	// inflight tracks the running handlers. It is created from baseContext when the options are applied.
+	inflight *inflight
+
+	// lifetime is cancelled when the connection is closed, to stop reconnecting.
+	lifetime     context.Context
+	stopLifetime context.CancelFunc
+
+	// reconnects tracks the running reconnect loops, so that Close waits for them before closing the sessions.
+	reconnects sync.WaitGroup
+
+	lifecycleHandlers []LifecycleHandler
+
+	// maxDisconnection is the duration a shard can stay disconnected before Fatal receives an error. 0 disables it.
+	maxDisconnection time.Duration
+	fatal            chan error
+
+	// presence is shown in the member list when it is not nil, and refreshed every presenceInterval.
+	// They are guarded by mu because SetPresence replaces them at runtime.
+	presence         *Presence
+	presenceInterval time.Duration
+	presenceVars     PresenceVars
+	presenceChanged  chan struct{}
+
+	mu       sync.Mutex
+	openedAt time.Time
 }
 
 func defaultConn() *Conn {
 	return &Conn{
-		session:               nil,
-		messageCreateHandlers: make([]MessageCreateHandler, 0),
-		preClose:              make([]func(), 0),
-		handlerDeadline:       MinimumHandlerTimeout,
-		baseContext:           context.Background(),
+		shards:          make([]*shard, 0),
+		handlers:        make([]registration, 0),
+		shardCount:      1,
+		ratelimiter:     discordgo.NewRatelimiter(),
+		handlerDeadline: MinimumHandlerTimeout,
+		reporter:        report.Log(),
+		baseContext:     context.Background(),
+		fatal:           make(chan error, 1),
+		presenceChanged: make(chan struct{}, 1),
 	}
 }
 
 func NewConn(token string, option ...Option) *Conn {
 	conn := defaultConn()
-
-	// discordgo#New does not return an error, so don't handle it.
-	session, _ := discordgo.New("Bot " + token)
-	conn.session = session
+	conn.token = token
 
 	// apply options
 	for _, opt := range option {
 		opt(conn)
 	}
+	conn.inflight = newInflight(conn.baseContext)
+	conn.lifetime, conn.stopLifetime = context.WithCancel(conn.baseContext)
 	return conn
 }
 
-// Open opens a connection to the Discord API.
+// newSession creates a session for the shard.
+func (c *Conn) newSession(shardID, shardCount int) *discordgo.Session {
+	// discordgo#New does not return an error, so don't handle it.
+	session, _ := discordgo.New("Bot " + c.token)
+	session.ShardID = shardID
+	session.ShardCount = shardCount
+	session.Ratelimiter = c.ratelimiter
+	session.Identify.Intents = c.Intents()
+	return session
+}
+
+// Open opens a connection to the Discord API for every shard.
 func (c *Conn) Open() error {
-	// register handlers and save the function to unregister them later.
-	for _, handler := range c.messageCreateHandlers {
-		fn := c.session.AddHandler(buildMessageCreateHandler(c.baseContext, handler))
-		c.preClose = append(c.preClose, fn)
+	logger := logging.Named(c.baseContext, loggerName)
+
+	plan, err := planShards(c.shardCount, func() (*discordgo.GatewayBotResponse, error) {
+		return c.newSession(0, 1).GatewayBot(discordgo.WithContext(c.baseContext))
+	})
+	if err != nil {
+		return err
+	}
+	intents := c.Intents()
+	logger.Info("opening shards",
+		zap.Int("shard_count", plan.count),
+		zap.Int("max_concurrency", plan.maxConcurrency),
+		zap.Strings("intents", IntentNames(intents)))
+	if privileged := intents & PrivilegedIntents; privileged != 0 {
+		logger.Info("privileged intents are requested, they must be enabled in the Discord Developer Portal",
+			zap.Strings("privileged_intents", IntentNames(privileged)))
 	}
 
-	if err := c.session.Open(); err != nil {
-		return fmt.Errorf("error was occurred when trying to connect to discord: %w", err)
+	c.shards = make([]*shard, 0, plan.count)
+	for id := 0; id < plan.count; id++ {
+		s := newShard(id, c.newSession(id, plan.count))
+		c.track(s)
+
+		// register handlers and save the function to unregister them later.
+		for _, handler := range c.handlers {
+			s.preClose = append(s.preClose, handler.add(c, s.session))
+		}
+		c.shards = append(c.shards, s)
+	}
+
+	open := func(s *shard) error {
+		s.setState(ShardConnecting)
+		if err := s.session.Open(); err != nil {
+			s.setState(ShardDisconnected)
+			return fmt.Errorf("error was occurred when trying to connect to discord (shard_id = %d): %w", s.id, classifyOpenError(err, intents))
+		}
+		return nil
+	}
+	if err := openShards(c.shards, plan.maxConcurrency, open, waitContext(c.baseContext, identifyInterval)); err != nil {
+		return err
 	}
+
+	c.mu.Lock()
+	c.openedAt = time.Now()
+	c.mu.Unlock()
+
+	// the loop runs without a presence too, so that SetPresence can set one later.
+	go c.refreshPresence()
 	return nil
 }
 
-// Close closes the connection to the Discord API.
+// Close closes the connection of every shard to the Discord API immediately.
+// The contexts of the running handlers are cancelled. Use Shutdown to wait for them.
 func (c *Conn) Close() error {
-	// unregister handlers
-	for _, fn := range c.preClose {
-		fn()
+	c.stopLifetime()
+	c.unregisterHandlers()
+	c.inflight.close()
+	c.inflight.cancel()
+	return c.closeShards()
+}
+
+// unregisterHandlers unregisters the handlers of every shard so that no more events are dispatched.
+func (c *Conn) unregisterHandlers() {
+	for _, s := range c.shards {
+		s.unregister()
 	}
-	if err := c.session.Close(); err != nil {
-		return fmt.Errorf("error was occurred when trying to disconnect from discord: %w", err)
+}
+
+// closeShards closes the session of every shard after the reconnect loops have stopped.
+// Shards are closed concurrently because closing a session waits for Discord to close the websocket.
+func (c *Conn) closeShards() error {
+	c.mu.Lock()
+	c.stopLifetime()
+	c.mu.Unlock()
+	c.reconnects.Wait()
+
+	errs := make([]error, len(c.shards))
+	var wg sync.WaitGroup
+	for i, s := range c.shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.setState(ShardClosed)
+			s.stopDisconnectTimer()
+			s.unregister()
+			if err := s.session.Close(); err != nil {
+				errs[i] = fmt.Errorf("error was occurred when trying to disconnect from discord (shard_id = %d): %w", s.id, err)
+			}
+		}()
 	}
-	return nil
+	wg.Wait()
+	return errors.Join(errs...)
 }
 
-// buildMessageCreateHandler creates a handler for the message creation event.
-func buildMessageCreateHandler(ctx context.Context, handler MessageCreateHandler) func(*discordgo.Session, *discordgo.MessageCreate) {
-	return func(s *discordgo.Session, m *discordgo.MessageCreate) {
+// ShardStatuses returns the status of every shard.
+func (c *Conn) ShardStatuses() []ShardStatus {
+	statuses := make([]ShardStatus, 0, len(c.shards))
+	for _, s := range c.shards {
+		statuses = append(statuses, s.snapshot())
+	}
+	return statuses
+}
+
+// Guild is a guild the bot is connected to.
+type Guild struct {
+	ID          string
+	Name        string
+	ShardID     int
+	MemberCount int
+	Unavailable bool
+}
+
+// Guilds returns the guilds in the state of every shard, ordered by shard and ID.
+func (c *Conn) Guilds() []Guild {
+	guilds := make([]Guild, 0)
+	for _, s := range c.shards {
+		if s.session == nil || s.session.State == nil {
+			continue
+		}
+		s.session.State.RLock()
+		for _, g := range s.session.State.Guilds {
+			guilds = append(guilds, Guild{
+				ID:          g.ID,
+				Name:        g.Name,
+				ShardID:     s.id,
+				MemberCount: g.MemberCount,
+				Unavailable: g.Unavailable,
+			})
+		}
+		s.session.State.RUnlock()
+	}
+	sort.SliceStable(guilds, func(i, j int) bool {
+		if guilds[i].ShardID != guilds[j].ShardID {
+			return guilds[i].ShardID < guilds[j].ShardID
+		}
+		return guilds[i].ID < guilds[j].ID
+	})
+	return guilds
+}
+
+// InflightHandlers returns the number of handlers which have not returned yet, including the ones which timed out.
+func (c *Conn) InflightHandlers() int {
+	return len(c.inflight.running())
+}
+
+// buildEventHandler creates a discordgo handler for the event T which runs the handler with the deadline,
+// and reports the errors it returns. Events dispatched after the tracker is closed are dropped.
+func buildEventHandler[T any](tracker *inflight, deadline time.Duration, reporter report.Reporter, handler EventHandler[T]) func(*discordgo.Session, T) {
+	return func(s *discordgo.Session, event T) {
 		start := time.Now()
 
-		// attach trace id to the context
-		ctx = trace.WithTraceID(ctx)
+		// attach trace id to the context, and to the logger of the context so that every log of the event carries it.
+		ctx := trace.WithTraceID(tracker.ctx)
 		traceID := trace.AcquireTraceID(ctx)
+		ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With(logging.Trace(ctx)))
+		name := eventName(event)
 
 		// debug information
-		logger := logging.FromContext(ctx)
-		logger.Debug("MessageCreate event received",
-			zap.String("trace_id", traceID),
-			zap.Dict("message",
-				zap.String("guild_id", m.GuildID),
-				zap.String("channel_id", m.ChannelID),
-				zap.String("message_id", m.ID),
-				zap.Dict("author",
-					zap.String("id", m.Author.ID),
-					zap.String("username", m.Author.Username),
-					zap.Bool("is_bot", m.Author.Bot),
-				)))
+		logger := logging.Named(ctx, loggerName)
+		logger.Debug(name+" event received", eventFields(event)...)
+
+		// the connection is shutting down, so the event is not handled.
+		release, ok := tracker.begin(name, traceID)
+		if !ok {
+			logger.Debug(name + " event dropped during shutdown")
+			return
+		}
+		// the call is tracked until both the handler and the reports of its errors have finished,
+		// so that shutdown waits for the reports sent to other services too.
+		var remaining atomic.Int32
+		remaining.Store(2)
+		done := func() {
+			if remaining.Add(-1) == 0 {
+				release()
+			}
+		}
+		defer done()
 
 		// create a new context from the base context with the deadline.
-		ctx, cancel := context.WithTimeout(ctx, MinimumHandlerTimeout)
+		ctx, cancel := context.WithTimeout(ctx, deadline)
 		defer cancel()
 
+		// the reporters may call other services, so they get their own deadline after the handler finished.
+		reportError := func(err error) {
+			reportCtx, cancelReport := context.WithTimeout(context.WithoutCancel(ctx), deadline)
+			defer cancelReport()
+			if err := reporter.Report(reportCtx, report.NewEvent(name, traceID, err)); err != nil {
+				logger.Warn("failed to report the error of the handler",
+					zap.String("event", name),
+					zap.Error(err),
+				)
+			}
+		}
+
 		// execute the handler in a other goroutine.
-		errCh := make(chan error)
+		// the channel is buffered so that the goroutine does not leak when the handler times out.
+		// the handler is tracked until it returns, even after it timed out, so that shutdown waits for it.
+		errCh := make(chan error, 1)
 		go func() {
-			errCh <- handler(ctx, s, m)
+			defer done()
+			errCh <- handler(ctx, s, event)
 		}()
 
 		// wait for the handler to finish.
 		// if the handler does not finish in time, cancel the context.
 		select {
 		case <-ctx.Done():
-			logger.Warn("handler timed out",
-				zap.String("trace_id", traceID),
-			)
+			logger.Warn("handler timed out", zap.String("event", name))
+			// the handlers abandoned on shutdown are cancelled, and they are reported by Shutdown instead.
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				reportError(fmt.Errorf("handler did not finish in %s: %w", deadline, ctx.Err()))
+			}
 		case err := <-errCh:
 			if err != nil {
-				logger.Error("error occurred in handler",
-					zap.String("trace_id", traceID),
-					zap.Error(err),
-				)
+				reportError(err)
 			}
 		}
 
 		// debug information
 		latency := time.Since(start)
-		logger.Debug("MessageCreate event handled",
-			zap.String("trace_id", traceID),
-			zap.Duration("latency", latency),
-		)
+		logger.Debug(name+" event handled", zap.Duration("latency", latency))
+	}
+}
+
+// eventName returns the name of the event type, e.g. MessageCreate.
+func eventName(event any) string {
+	name := fmt.Sprintf("%T", event)
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// eventFields returns the log fields describing the event.
+func eventFields(event any) []zap.Field {
+	switch e := event.(type) {
+	case *discordgo.MessageCreate:
+		if e.Author == nil {
+			return nil
+		}
+		return []zap.Field{
+			zap.Dict("message",
+				zap.String("guild_id", e.GuildID),
+				zap.String("channel_id", e.ChannelID),
+				zap.String("message_id", e.ID),
+				zap.Dict("author",
+					zap.String("id", e.Author.ID),
+					zap.String("username", e.Author.Username),
+					zap.Bool("is_bot", e.Author.Bot),
+				)),
+		}
+	case *discordgo.ChannelUpdate:
+		return channelFields(e.Channel)
+	case *discordgo.ChannelDelete:
+		return channelFields(e.Channel)
+	case *discordgo.ThreadUpdate:
+		return channelFields(e.Channel)
+	case *discordgo.ThreadDelete:
+		return channelFields(e.Channel)
+	case *discordgo.GuildCreate:
+		if e.Guild == nil {
+			return nil
+		}
+		return []zap.Field{zap.String("guild_id", e.ID)}
+	case *discordgo.GuildRoleUpdate:
+		return []zap.Field{zap.String("guild_id", e.GuildID)}
+	case *discordgo.GuildMemberUpdate:
+		return []zap.Field{zap.String("guild_id", e.GuildID)}
+	case *discordgo.MessageUpdate:
+		return messageFields(e.Message)
+	case *discordgo.MessageDelete:
+		return messageFields(e.Message)
+	case *discordgo.MessageDeleteBulk:
+		return []zap.Field{
+			zap.String("guild_id", e.GuildID),
+			zap.String("channel_id", e.ChannelID),
+			zap.Int("count", len(e.Messages)),
+		}
+	}
+	return nil
+}
+
+func channelFields(channel *discordgo.Channel) []zap.Field {
+	if channel == nil {
+		return nil
+	}
+	return []zap.Field{
+		zap.Dict("channel",
+			zap.String("guild_id", channel.GuildID),
+			zap.String("channel_id", channel.ID)),
+	}
+}
+
+func messageFields(message *discordgo.Message) []zap.Field {
+	if message == nil {
+		return nil
+	}
+	return []zap.Field{
+		zap.Dict("message",
+			zap.String("guild_id", message.GuildID),
+			zap.String("channel_id", message.ChannelID),
+			zap.String("message_id", message.ID)),
 	}
 }
@@ -0,0 +1,164 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aqyuki/felm/pkg/logging"
+	"github.com/aqyuki/felm/pkg/report"
+	"github.com/aqyuki/felm/pkg/trace"
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestEventName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		event any
+		want  string
+	}{
+		{"message create", &discordgo.MessageCreate{}, "MessageCreate"},
+		{"channel update", &discordgo.ChannelUpdate{}, "ChannelUpdate"},
+		{"guild role update", &discordgo.GuildRoleUpdate{}, "GuildRoleUpdate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if actual := eventName(tt.event); actual != tt.want {
+				t.Errorf("expected %s but received %s", tt.want, actual)
+			}
+		})
+	}
+}
+
+func TestBuildEventHandler(t *testing.T) {
+	t.Parallel()
+
+	t.Run("handler receives the event with a trace id", func(t *testing.T) {
+		t.Parallel()
+
+		received := make(chan string, 1)
+		fn := buildEventHandler(newInflight(context.Background()), time.Second, report.Log(), func(ctx context.Context, _ *discordgo.Session, event *discordgo.ChannelDelete) error {
+			if event.Channel.ID != "channel" {
+				t.Errorf("expected channel id to be channel but received %s", event.Channel.ID)
+			}
+			received <- trace.AcquireTraceID(ctx)
+			return nil
+		})
+		fn(nil, &discordgo.ChannelDelete{Channel: &discordgo.Channel{ID: "channel"}})
+
+		if traceID := <-received; traceID == "" {
+			t.Error("expected trace id to be attached but received empty")
+		}
+	})
+
+	t.Run("every log of the event carries the trace id", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.DebugLevel)
+		base := logging.WithLogger(context.Background(), zap.New(core))
+		received := make(chan string, 1)
+		fn := buildEventHandler(newInflight(base), time.Second, report.Log(), func(ctx context.Context, _ *discordgo.Session, _ *discordgo.ChannelDelete) error {
+			logging.FromContext(ctx).Info("handler log")
+			received <- trace.AcquireTraceID(ctx)
+			return errors.New("handler failed")
+		})
+		fn(nil, &discordgo.ChannelDelete{Channel: &discordgo.Channel{ID: "channel"}})
+
+		traceID := <-received
+		if logs.Len() == 0 {
+			t.Fatal("expected the event to be logged but received no logs")
+		}
+		for _, entry := range logs.All() {
+			ids := make([]string, 0, 1)
+			for _, field := range entry.Context {
+				if field.Key == logging.TraceIDKey {
+					ids = append(ids, field.String)
+				}
+			}
+			if len(ids) != 1 || ids[0] != traceID {
+				t.Errorf("expected %q to have the trace id %s once but received %v", entry.Message, traceID, ids)
+			}
+		}
+	})
+
+	t.Run("handler context is cancelled after the deadline", func(t *testing.T) {
+		t.Parallel()
+
+		done := make(chan error, 1)
+		fn := buildEventHandler(newInflight(context.Background()), 10*time.Millisecond, report.Log(), func(ctx context.Context, _ *discordgo.Session, _ *discordgo.ChannelDelete) error {
+			<-ctx.Done()
+			done <- ctx.Err()
+			return nil
+		})
+		fn(nil, &discordgo.ChannelDelete{})
+
+		select {
+		case err := <-done:
+			if err != context.DeadlineExceeded {
+				t.Errorf("expected err to be %v but received %v", context.DeadlineExceeded, err)
+			}
+		case <-time.After(time.Second):
+			t.Error("expected handler context to be cancelled")
+		}
+	})
+
+	t.Run("timed out handler is reported", func(t *testing.T) {
+		t.Parallel()
+
+		events := make(chan report.Event, 1)
+		reporter := report.ReporterFunc(func(_ context.Context, event report.Event) error {
+			events <- event
+			return nil
+		})
+		fn := buildEventHandler(newInflight(context.Background()), 10*time.Millisecond, reporter, func(ctx context.Context, _ *discordgo.Session, _ *discordgo.ChannelDelete) error {
+			<-ctx.Done()
+			return nil
+		})
+		fn(nil, &discordgo.ChannelDelete{})
+
+		select {
+		case event := <-events:
+			if !errors.Is(event.Err, context.DeadlineExceeded) || event.Source != "ChannelDelete" || event.TraceID == "" {
+				t.Errorf("expected the timeout of ChannelDelete to be reported but received %+v", event)
+			}
+		default:
+			t.Error("expected the timeout to be reported before the handler returns")
+		}
+	})
+}
+
+func TestConnGuilds(t *testing.T) {
+	t.Parallel()
+
+	newSession := func(guilds ...*discordgo.Guild) *discordgo.Session {
+		session, _ := discordgo.New("Bot token")
+		for _, g := range guilds {
+			_ = session.State.GuildAdd(g)
+		}
+		return session
+	}
+	conn := NewConn("token")
+	conn.shards = []*shard{
+		newShard(0, newSession(&discordgo.Guild{ID: "2", Name: "b"}, &discordgo.Guild{ID: "1", Name: "a", MemberCount: 3})),
+		newShard(1, newSession(&discordgo.Guild{ID: "0", Unavailable: true})),
+		newShard(2, nil),
+	}
+
+	expected := []Guild{
+		{ID: "1", Name: "a", ShardID: 0, MemberCount: 3},
+		{ID: "2", Name: "b", ShardID: 0},
+		{ID: "0", ShardID: 1, Unavailable: true},
+	}
+	if guilds := conn.Guilds(); !reflect.DeepEqual(guilds, expected) {
+		t.Errorf("expected %+v but received %+v", expected, guilds)
+	}
+}
@@ -0,0 +1,120 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// closeDisallowedIntents is the close code Discord sends when the bot requests privileged intents it is not approved for.
+const closeDisallowedIntents = 4014
+
+// ErrDisallowedIntents is returned when Discord rejects the privileged intents requested by the bot.
+var ErrDisallowedIntents = errors.New("privileged intents are not enabled for the bot, enable them in the Discord Developer Portal or stop requesting them")
+
+// PrivilegedIntents are the intents which must be enabled in the Discord Developer Portal.
+const PrivilegedIntents = discordgo.IntentGuildMembers | discordgo.IntentGuildPresences | discordgo.IntentMessageContent
+
+// intentNames maps the names accepted by ParseIntents to the intents.
+var intentNames = map[string]discordgo.Intent{
+	"guilds":                    discordgo.IntentGuilds,
+	"guild_members":             discordgo.IntentGuildMembers,
+	"guild_moderation":          discordgo.IntentGuildModeration,
+	"guild_emojis":              discordgo.IntentGuildEmojis,
+	"guild_integrations":        discordgo.IntentGuildIntegrations,
+	"guild_webhooks":            discordgo.IntentGuildWebhooks,
+	"guild_invites":             discordgo.IntentGuildInvites,
+	"guild_voice_states":        discordgo.IntentGuildVoiceStates,
+	"guild_presences":           discordgo.IntentGuildPresences,
+	"guild_messages":            discordgo.IntentGuildMessages,
+	"guild_message_reactions":   discordgo.IntentGuildMessageReactions,
+	"guild_message_typing":      discordgo.IntentGuildMessageTyping,
+	"direct_messages":           discordgo.IntentDirectMessages,
+	"direct_message_reactions":  discordgo.IntentDirectMessageReactions,
+	"direct_message_typing":     discordgo.IntentDirectMessageTyping,
+	"message_content":           discordgo.IntentMessageContent,
+	"guild_scheduled_events":    discordgo.IntentGuildScheduledEvents,
+	"auto_moderation_config":    discordgo.IntentAutoModerationConfiguration,
+	"auto_moderation_execution": discordgo.IntentAutoModerationExecution,
+}
+
+// WithIntents requests the intents in addition to the ones required by the registered handlers.
+// Privileged intents such as discordgo.IntentMessageContent are never derived from handlers and must be requested here.
+func WithIntents(intents discordgo.Intent) Option {
+	return func(c *Conn) {
+		c.intents |= intents
+	}
+}
+
+// ParseIntents parses a comma separated list of intent names such as "message_content,guild_members".
+func ParseIntents(s string) (discordgo.Intent, error) {
+	var intents discordgo.Intent
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		intent, ok := intentNames[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown intent: %s", name)
+		}
+		intents |= intent
+	}
+	return intents, nil
+}
+
+// IntentNames returns the names of the intents in alphabetical order.
+func IntentNames(intents discordgo.Intent) []string {
+	names := make([]string, 0)
+	for name, intent := range intentNames {
+		if intents&intent != 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// intentsFor returns the intents Discord requires to dispatch the event to the bot.
+// Events which are dispatched regardless of the intents, such as *discordgo.Ready, require none.
+// Privileged intents are never derived, because Discord rejects them unless they are enabled for the bot.
+func intentsFor(event any) discordgo.Intent {
+	switch event.(type) {
+	case *discordgo.MessageCreate, *discordgo.MessageUpdate, *discordgo.MessageDelete, *discordgo.MessageDeleteBulk:
+		return discordgo.IntentGuildMessages
+	case *discordgo.MessageReactionAdd, *discordgo.MessageReactionRemove, *discordgo.MessageReactionRemoveAll:
+		return discordgo.IntentGuildMessageReactions
+	case *discordgo.GuildCreate, *discordgo.GuildUpdate, *discordgo.GuildDelete,
+		*discordgo.GuildRoleCreate, *discordgo.GuildRoleUpdate, *discordgo.GuildRoleDelete,
+		*discordgo.ChannelCreate, *discordgo.ChannelUpdate, *discordgo.ChannelDelete, *discordgo.ChannelPinsUpdate,
+		*discordgo.ThreadCreate, *discordgo.ThreadUpdate, *discordgo.ThreadDelete, *discordgo.ThreadListSync,
+		*discordgo.ThreadMemberUpdate:
+		return discordgo.IntentGuilds
+	case *discordgo.GuildMemberUpdate:
+		// Discord dispatches the updates of the bot's own member without the privileged intent,
+		// so only IntentGuilds is derived. Request IntentGuildMembers explicitly to receive the others.
+		return discordgo.IntentGuilds
+	}
+	return discordgo.IntentsNone
+}
+
+// Intents returns the intents the connection requests: the ones required by the handlers and the explicit ones.
+func (c *Conn) Intents() discordgo.Intent {
+	intents := c.intents
+	for _, handler := range c.handlers {
+		intents |= handler.intents
+	}
+	return intents
+}
+
+// classifyOpenError replaces the close error Discord sends for disallowed intents with ErrDisallowedIntents.
+func classifyOpenError(err error, intents discordgo.Intent) error {
+	if closeCode(err) == closeDisallowedIntents {
+		return fmt.Errorf("%w (requested = %s): %w", ErrDisallowedIntents,
+			strings.Join(IntentNames(intents&PrivilegedIntents), ","), err)
+	}
+	return err
+}
@@ -0,0 +1,114 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/gorilla/websocket"
+)
+
+func TestConnIntents(t *testing.T) {
+	t.Parallel()
+
+	nop := func(context.Context, *discordgo.Session, *discordgo.ChannelUpdate) error { return nil }
+	tests := []struct {
+		name    string
+		options []Option
+		want    discordgo.Intent
+	}{
+		{
+			name: "no handlers",
+			want: discordgo.IntentsNone,
+		},
+		{
+			name: "derived from handlers",
+			options: []Option{
+				WithMessageCreateHandler(func(context.Context, *discordgo.Session, *discordgo.MessageCreate) error { return nil }),
+				WithEventHandler(nop),
+			},
+			want: discordgo.IntentGuildMessages | discordgo.IntentGuilds,
+		},
+		{
+			name: "privileged intents are not derived",
+			options: []Option{
+				WithEventHandler(func(context.Context, *discordgo.Session, *discordgo.GuildMemberUpdate) error { return nil }),
+			},
+			want: discordgo.IntentGuilds,
+		},
+		{
+			name: "explicit intents are added",
+			options: []Option{
+				WithEventHandler(nop),
+				WithIntents(discordgo.IntentMessageContent),
+			},
+			want: discordgo.IntentGuilds | discordgo.IntentMessageContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			conn := NewConn("token", tt.options...)
+			if got := conn.Intents(); got != tt.want {
+				t.Errorf("expected intents to be %v but received %v", IntentNames(tt.want), IntentNames(got))
+			}
+			if got := conn.newSession(0, 1).Identify.Intents; got != tt.want {
+				t.Errorf("expected session intents to be %v but received %v", IntentNames(tt.want), IntentNames(got))
+			}
+		})
+	}
+}
+
+func TestParseIntents(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input   string
+		want    discordgo.Intent
+		wantErr bool
+	}{
+		{"", discordgo.IntentsNone, false},
+		{"message_content", discordgo.IntentMessageContent, false},
+		{" Message_Content , guild_members ", discordgo.IntentMessageContent | discordgo.IntentGuildMembers, false},
+		{"message_content,unknown", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseIntents(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected err to be %v but received %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %v but received %v", tt.want, got)
+			}
+		})
+	}
+
+	names := IntentNames(discordgo.IntentMessageContent | discordgo.IntentGuilds)
+	if !slices.Equal(names, []string{"guilds", "message_content"}) {
+		t.Errorf("expected names to be sorted but received %v", names)
+	}
+}
+
+func TestClassifyOpenError(t *testing.T) {
+	t.Parallel()
+
+	disallowed := &websocket.CloseError{Code: closeDisallowedIntents, Text: "Disallowed intent(s)."}
+	err := classifyOpenError(fmt.Errorf("wrapped: %w", disallowed), discordgo.IntentMessageContent|discordgo.IntentGuilds)
+	if !errors.Is(err, ErrDisallowedIntents) {
+		t.Errorf("expected err to be %v but received %v", ErrDisallowedIntents, err)
+	}
+
+	other := &websocket.CloseError{Code: websocket.CloseAbnormalClosure}
+	if err := classifyOpenError(other, discordgo.IntentGuilds); errors.Is(err, ErrDisallowedIntents) || err != other {
+		t.Errorf("expected other close errors to be returned unchanged but received %v", err)
+	}
+}
@@ -0,0 +1,239 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/aqyuki/felm/pkg/logging"
+	"github.com/bwmarrin/discordgo"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	// reconnectBaseDelay and reconnectMaxDelay bound the jittered delay between reconnect attempts.
+	reconnectBaseDelay = 1 * time.Second
+	reconnectMaxDelay  = 2 * time.Minute
+)
+
+var (
+	// ErrUnrecoverable is sent to Conn.Fatal when Discord closes the gateway with a code which reconnecting can not fix,
+	// e.g. an invalid token or disallowed intents.
+	ErrUnrecoverable = errors.New("gateway connection can not be recovered")
+
+	// ErrDisconnectedTooLong is sent to Conn.Fatal when a shard stays disconnected longer than WithMaxDisconnection.
+	ErrDisconnectedTooLong = errors.New("gateway connection has been lost for too long")
+)
+
+// unrecoverableCloseCodes are the gateway close codes after which Discord documents that the bot must not reconnect.
+var unrecoverableCloseCodes = map[int]string{
+	4004: "authentication failed",
+	4010: "invalid shard",
+	4011: "sharding required",
+	4012: "invalid API version",
+	4013: "invalid intents",
+	4014: "disallowed intents",
+}
+
+// LifecycleEventType is the kind of change of the gateway connection of a shard.
+type LifecycleEventType string
+
+const (
+	LifecycleConnected    LifecycleEventType = "connected"
+	LifecycleReady        LifecycleEventType = "ready"
+	LifecycleResumed      LifecycleEventType = "resumed"
+	LifecycleDisconnected LifecycleEventType = "disconnected"
+	LifecycleReconnecting LifecycleEventType = "reconnecting"
+)
+
+// LifecycleEvent is a change of the gateway connection of a shard.
+type LifecycleEvent struct {
+	Type    LifecycleEventType
+	ShardID int
+	Time    time.Time
+
+	// Guilds is the number of guilds of the shard. It is set for LifecycleReady.
+	Guilds int
+
+	// Attempt is the number of the reconnect attempt starting from 1. It is set for LifecycleReconnecting,
+	// and for LifecycleDisconnected when the attempt failed.
+	Attempt int
+
+	// CloseCode is the close code sent by Discord. discordgo does not expose the code of a dropped connection,
+	// so it is only set when a reconnect attempt is closed by Discord, and 0 otherwise.
+	CloseCode int
+
+	// Err is the error of the failed reconnect attempt.
+	Err error
+}
+
+// LifecycleHandler is called for every lifecycle event. It must not block.
+type LifecycleHandler func(LifecycleEvent)
+
+// WithLifecycleHandler adds a handler for the lifecycle events of the shards.
+func WithLifecycleHandler(handler LifecycleHandler) Option {
+	return func(c *Conn) {
+		c.lifecycleHandlers = append(c.lifecycleHandlers, handler)
+	}
+}
+
+// WithMaxDisconnection sends ErrDisconnectedTooLong to Conn.Fatal when a shard stays disconnected longer than d.
+// 0 keeps reconnecting forever.
+func WithMaxDisconnection(d time.Duration) Option {
+	return func(c *Conn) {
+		if d >= 0 {
+			c.maxDisconnection = d
+		}
+	}
+}
+
+// Fatal returns a channel which receives an error when the connection can not recover by itself,
+// so that the process can exit and be restarted.
+func (c *Conn) Fatal() <-chan error {
+	return c.fatal
+}
+
+// Uptime returns the duration since Open succeeded, or 0 before that.
+func (c *Conn) Uptime() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.openedAt.IsZero() {
+		return 0
+	}
+	return time.Since(c.openedAt)
+}
+
+// fail sends the error to Fatal. Only the first error is kept.
+func (c *Conn) fail(err error) {
+	select {
+	case c.fatal <- err:
+	default:
+	}
+}
+
+func (c *Conn) emit(event LifecycleEvent) {
+	event.Time = time.Now()
+	for _, handler := range c.lifecycleHandlers {
+		handler(event)
+	}
+}
+
+// track registers the handlers which keep the status of the shard up to date and reconnect it.
+// discordgo's own reconnect is disabled, because it neither reports its attempts nor stops on unrecoverable close codes.
+func (c *Conn) track(s *shard) {
+	logger := logging.Named(c.baseContext, loggerName).With(zap.Int("shard_id", s.id))
+	s.session.ShouldReconnectOnError = false
+
+	s.preClose = append(s.preClose,
+		s.session.AddHandler(func(_ *discordgo.Session, _ *discordgo.Connect) {
+			reconnected := s.connected()
+			if reconnected {
+				logger.Info("shard reconnected", zap.Int("reconnects", s.snapshot().Reconnects))
+			} else {
+				logger.Info("shard connected")
+			}
+			c.emit(LifecycleEvent{Type: LifecycleConnected, ShardID: s.id})
+		}),
+		s.session.AddHandler(func(_ *discordgo.Session, r *discordgo.Ready) {
+			s.setGuilds(len(r.Guilds))
+			logger.Info("shard is ready", zap.Int("guilds", len(r.Guilds)))
+			c.emit(LifecycleEvent{Type: LifecycleReady, ShardID: s.id, Guilds: len(r.Guilds)})
+			c.updatePresence(s)
+		}),
+		s.session.AddHandler(func(_ *discordgo.Session, _ *discordgo.Resumed) {
+			logger.Info("shard resumed the session")
+			c.emit(LifecycleEvent{Type: LifecycleResumed, ShardID: s.id})
+		}),
+		s.session.AddHandler(func(_ *discordgo.Session, _ *discordgo.Disconnect) {
+			if s.snapshot().State == ShardClosed || c.lifetime.Err() != nil {
+				return
+			}
+			s.disconnected(c.maxDisconnection, func() {
+				logger.Error("shard has been disconnected too long", zap.Duration("max_disconnection", c.maxDisconnection))
+				c.fail(fmt.Errorf("%w (shard_id = %d, max_disconnection = %s)", ErrDisconnectedTooLong, s.id, c.maxDisconnection))
+			})
+			logger.Warn("shard disconnected")
+			c.emit(LifecycleEvent{Type: LifecycleDisconnected, ShardID: s.id})
+			c.startReconnect(s, logger)
+		}),
+	)
+}
+
+// startReconnect reconnects the shard in the background unless it is already reconnecting.
+func (c *Conn) startReconnect(s *shard, logger *zap.Logger) {
+	// the lifetime is checked under the lock so that closeShards does not miss a loop starting concurrently.
+	c.mu.Lock()
+	if c.lifetime.Err() != nil || !s.beginReconnect() {
+		c.mu.Unlock()
+		return
+	}
+	c.reconnects.Add(1)
+	c.mu.Unlock()
+
+	go func() {
+		defer c.reconnects.Done()
+		defer s.endReconnect()
+
+		err := reconnectLoop(c.lifetime, s.session.Open, reconnectDelay,
+			func(attempt int) {
+				s.setState(ShardConnecting)
+				logger.Info("shard is reconnecting", zap.Int("attempt", attempt))
+				c.emit(LifecycleEvent{Type: LifecycleReconnecting, ShardID: s.id, Attempt: attempt})
+			},
+			func(attempt int, err error) {
+				s.setState(ShardDisconnected)
+				code := closeCode(err)
+				logger.Warn("shard failed to reconnect", zap.Int("attempt", attempt), zap.Int("close_code", code), zap.Error(err))
+				c.emit(LifecycleEvent{Type: LifecycleDisconnected, ShardID: s.id, Attempt: attempt, CloseCode: code, Err: err})
+			})
+		if errors.Is(err, ErrUnrecoverable) {
+			logger.Error("shard stopped reconnecting", zap.Error(err))
+			c.fail(fmt.Errorf("shard %d: %w", s.id, classifyOpenError(err, c.Intents())))
+		}
+	}()
+}
+
+// reconnectLoop calls open until it succeeds, fails with an unrecoverable close code or ctx is done.
+func reconnectLoop(ctx context.Context, open func() error, delay func(attempt int) time.Duration, onAttempt func(attempt int), onFailure func(attempt int, err error)) error {
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		onAttempt(attempt)
+
+		err := open()
+		if err == nil || errors.Is(err, discordgo.ErrWSAlreadyOpen) {
+			return nil
+		}
+		onFailure(attempt, err)
+		if reason, ok := unrecoverableCloseCodes[closeCode(err)]; ok {
+			return fmt.Errorf("%w: %s: %w", ErrUnrecoverable, reason, err)
+		}
+
+		if err := sleepContext(ctx, delay(attempt)); err != nil {
+			return err
+		}
+	}
+}
+
+// reconnectDelay returns a delay with full jitter for the attempt.
+func reconnectDelay(attempt int) time.Duration {
+	ceiling := reconnectBaseDelay << (attempt - 1)
+	if ceiling <= 0 || ceiling > reconnectMaxDelay {
+		ceiling = reconnectMaxDelay
+	}
+	return rand.N(ceiling) + 1
+}
+
+// closeCode returns the close code of the websocket error, or 0 if err is not a close error.
+func closeCode(err error) int {
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		return closeErr.Code
+	}
+	return 0
+}
@@ -0,0 +1,173 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/gorilla/websocket"
+)
+
+func TestReconnectLoop(t *testing.T) {
+	t.Parallel()
+
+	noDelay := func(int) time.Duration { return time.Millisecond }
+
+	t.Run("reconnects after failures", func(t *testing.T) {
+		t.Parallel()
+
+		calls := 0
+		var attempts, failures []int
+		err := reconnectLoop(context.Background(), func() error {
+			calls++
+			if calls < 3 {
+				return &websocket.CloseError{Code: websocket.CloseAbnormalClosure}
+			}
+			return nil
+		}, noDelay,
+			func(attempt int) { attempts = append(attempts, attempt) },
+			func(attempt int, _ error) { failures = append(failures, attempt) })
+
+		if err != nil {
+			t.Errorf("expected err to be nil but received %v", err)
+		}
+		if !slices.Equal(attempts, []int{1, 2, 3}) || !slices.Equal(failures, []int{1, 2}) {
+			t.Errorf("expected 3 attempts and 2 failures but received %v and %v", attempts, failures)
+		}
+	})
+
+	t.Run("already open is a success", func(t *testing.T) {
+		t.Parallel()
+
+		err := reconnectLoop(context.Background(), func() error { return discordgo.ErrWSAlreadyOpen }, noDelay,
+			func(int) {}, func(int, error) { t.Error("expected no failure") })
+		if err != nil {
+			t.Errorf("expected err to be nil but received %v", err)
+		}
+	})
+
+	t.Run("unrecoverable close codes stop reconnecting", func(t *testing.T) {
+		t.Parallel()
+
+		calls := 0
+		err := reconnectLoop(context.Background(), func() error {
+			calls++
+			return &websocket.CloseError{Code: 4004}
+		}, noDelay, func(int) {}, func(int, error) {})
+
+		if !errors.Is(err, ErrUnrecoverable) {
+			t.Errorf("expected err to be %v but received %v", ErrUnrecoverable, err)
+		}
+		if calls != 1 {
+			t.Errorf("expected 1 attempt but received %d", calls)
+		}
+	})
+
+	t.Run("context stops reconnecting", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		err := reconnectLoop(ctx, func() error {
+			cancel()
+			return errors.New("connection refused")
+		}, func(int) time.Duration { return time.Hour }, func(int) {}, func(int, error) {})
+
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected err to be %v but received %v", context.Canceled, err)
+		}
+	})
+}
+
+func TestReconnectDelay(t *testing.T) {
+	t.Parallel()
+
+	for attempt := 1; attempt <= 64; attempt++ {
+		delay := reconnectDelay(attempt)
+		if delay <= 0 || delay > reconnectMaxDelay {
+			t.Errorf("expected delay of attempt %d to be in (0, %s] but received %s", attempt, reconnectMaxDelay, delay)
+		}
+	}
+}
+
+func TestShardLifecycle(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reconnects are counted", func(t *testing.T) {
+		t.Parallel()
+
+		s := newShard(0, nil)
+		if s.connected() {
+			t.Error("expected the first connection not to be a reconnect")
+		}
+		s.disconnected(0, nil)
+		if !s.connected() {
+			t.Error("expected the second connection to be a reconnect")
+		}
+		if status := s.snapshot(); status.Reconnects != 1 || status.State != ShardConnected {
+			t.Errorf("expected 1 reconnect and connected but received %+v", status)
+		}
+	})
+
+	t.Run("long disconnection is reported", func(t *testing.T) {
+		t.Parallel()
+
+		s := newShard(0, nil)
+		exceeded := make(chan struct{}, 2)
+		s.disconnected(10*time.Millisecond, func() { exceeded <- struct{}{} })
+
+		// a failed reconnect attempt must not restart the timer.
+		s.disconnected(time.Hour, func() { exceeded <- struct{}{} })
+
+		select {
+		case <-exceeded:
+		case <-time.After(time.Second):
+			t.Error("expected long disconnection to be reported")
+		}
+	})
+
+	t.Run("connection stops the timer", func(t *testing.T) {
+		t.Parallel()
+
+		s := newShard(0, nil)
+		exceeded := make(chan struct{}, 1)
+		s.disconnected(10*time.Millisecond, func() { exceeded <- struct{}{} })
+		s.connected()
+
+		select {
+		case <-exceeded:
+			t.Error("expected the timer to be stopped")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("only one reconnect runs at once", func(t *testing.T) {
+		t.Parallel()
+
+		s := newShard(0, nil)
+		if !s.beginReconnect() || s.beginReconnect() {
+			t.Error("expected only the first reconnect to begin")
+		}
+		s.endReconnect()
+		if !s.beginReconnect() {
+			t.Error("expected a reconnect to begin after the previous one ended")
+		}
+	})
+}
+
+func TestConnFatal(t *testing.T) {
+	t.Parallel()
+
+	conn := NewConn("token")
+	conn.fail(ErrDisconnectedTooLong)
+	conn.fail(ErrUnrecoverable)
+
+	if err := <-conn.Fatal(); !errors.Is(err, ErrDisconnectedTooLong) {
+		t.Errorf("expected the first error to be kept but received %v", err)
+	}
+	if conn.Uptime() != 0 {
+		t.Errorf("expected uptime to be 0 before open but received %s", conn.Uptime())
+	}
+}
@@ -0,0 +1,236 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// guildsPerPage is the maximum number of guilds returned by a request of the current user's guilds.
+const guildsPerPage = 200
+
+// applicationFlags are the flags of the application which allow each privileged intent.
+// The limited flags are set for bots in less than 100 guilds, which do not need verification.
+var applicationFlags = map[discordgo.Intent]int{
+	discordgo.IntentGuildPresences: 1<<12 | 1<<13,
+	discordgo.IntentGuildMembers:   1<<14 | 1<<15,
+	discordgo.IntentMessageContent: 1<<18 | 1<<19,
+}
+
+// permissionNames are the names of the permissions checked by Preflight.
+var permissionNames = []struct {
+	permission int64
+	name       string
+}{
+	{discordgo.PermissionViewChannel, "view_channel"},
+	{discordgo.PermissionReadMessageHistory, "read_message_history"},
+	{discordgo.PermissionSendMessages, "send_messages"},
+	{discordgo.PermissionSendMessagesInThreads, "send_messages_in_threads"},
+	{discordgo.PermissionEmbedLinks, "embed_links"},
+}
+
+// sendPermissions are the permissions required to send a citation to each type of channel with messages.
+// Messages in forums and media channels are sent in their posts, which are threads.
+var sendPermissions = map[discordgo.ChannelType]int64{
+	discordgo.ChannelTypeGuildText:       discordgo.PermissionSendMessages,
+	discordgo.ChannelTypeGuildNews:       discordgo.PermissionSendMessages,
+	discordgo.ChannelTypeGuildVoice:      discordgo.PermissionSendMessages,
+	discordgo.ChannelTypeGuildStageVoice: discordgo.PermissionSendMessages,
+	discordgo.ChannelTypeGuildForum:      discordgo.PermissionSendMessagesInThreads,
+	discordgo.ChannelTypeGuildMedia:      discordgo.PermissionSendMessagesInThreads,
+}
+
+// PreflightReport is the result of Preflight.
+type PreflightReport struct {
+	Bot     PreflightBot     `json:"bot"`
+	Intents PreflightIntents `json:"intents"`
+	Guilds  []GuildReport    `json:"guilds"`
+}
+
+// PreflightBot is the user of the token.
+type PreflightBot struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+// PreflightIntents are the intents requested by the connection.
+type PreflightIntents struct {
+	Requested []string `json:"requested"`
+
+	// Missing are the privileged intents which are requested but not enabled in the developer portal.
+	Missing []string `json:"missing"`
+}
+
+// GuildReport lists the channels of a guild where messages can not be cited.
+type GuildReport struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
+	// Error is set when the guild could not be inspected.
+	Error string `json:"error,omitempty"`
+
+	// Channels are the channels with missing permissions. Channels without problems are omitted.
+	Channels []ChannelReport `json:"channels"`
+}
+
+// ChannelReport is a channel with missing permissions.
+type ChannelReport struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
+	// CanRead reports whether messages in the channel can be fetched to be cited.
+	CanRead bool `json:"can_read"`
+
+	// CanEmbed reports whether citations can be sent to the channel.
+	CanEmbed bool `json:"can_embed"`
+
+	Missing []string `json:"missing"`
+}
+
+// OK reports whether the bot can connect with the requested intents.
+// Channels with missing permissions do not prevent it, because they may be private on purpose.
+func (r *PreflightReport) OK() bool {
+	return len(r.Intents.Missing) == 0
+}
+
+// Preflight checks the token, the intents and the permissions of the bot in every guild without connecting to the gateway.
+// It returns ErrUnauthorized when the token is rejected.
+func (c *Conn) Preflight(ctx context.Context) (*PreflightReport, error) {
+	return preflight(ctx, c.newSession(0, 1), c.Intents())
+}
+
+func preflight(ctx context.Context, session *discordgo.Session, intents discordgo.Intent) (*PreflightReport, error) {
+	user, err := session.User("@me", discordgo.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the bot user: %w", ClassifyError(err))
+	}
+	report := &PreflightReport{
+		Bot:     PreflightBot{ID: user.ID, Username: user.Username},
+		Intents: PreflightIntents{Requested: IntentNames(intents), Missing: make([]string, 0)},
+		Guilds:  make([]GuildReport, 0),
+	}
+
+	if intents&PrivilegedIntents != 0 {
+		body, err := session.Request("GET", discordgo.EndpointApplications+"/@me", nil, discordgo.WithContext(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch the application: %w", ClassifyError(err))
+		}
+		var application discordgo.Application
+		if err := json.Unmarshal(body, &application); err != nil {
+			return nil, fmt.Errorf("failed to decode the application: %w", err)
+		}
+		report.Intents.Missing = missingIntents(intents, application.Flags)
+	}
+
+	guilds, err := userGuilds(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	for _, guild := range guilds {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		report.Guilds = append(report.Guilds, inspectGuild(ctx, session, user.ID, guild))
+	}
+	return report, nil
+}
+
+// missingIntents returns the names of the privileged intents which are not allowed by the flags of the application.
+func missingIntents(intents discordgo.Intent, flags int) []string {
+	missing := make([]string, 0)
+	for intent, allowed := range applicationFlags {
+		if intents&intent != 0 && flags&allowed == 0 {
+			missing = append(missing, IntentNames(intent)...)
+		}
+	}
+	slices.Sort(missing)
+	return missing
+}
+
+// userGuilds returns every guild the bot is in.
+func userGuilds(ctx context.Context, session *discordgo.Session) ([]*discordgo.UserGuild, error) {
+	guilds := make([]*discordgo.UserGuild, 0)
+	after := ""
+	for {
+		page, err := session.UserGuilds(guildsPerPage, "", after, false, discordgo.WithContext(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the guilds: %w", ClassifyError(err))
+		}
+		guilds = append(guilds, page...)
+		if len(page) < guildsPerPage {
+			return guilds, nil
+		}
+		after = page[len(page)-1].ID
+	}
+}
+
+// inspectGuild reports the channels of the guild where the bot lacks the permissions to cite messages.
+func inspectGuild(ctx context.Context, session *discordgo.Session, botID string, userGuild *discordgo.UserGuild) GuildReport {
+	report := GuildReport{ID: userGuild.ID, Name: userGuild.Name, Channels: make([]ChannelReport, 0)}
+
+	guild, err := session.Guild(userGuild.ID, discordgo.WithContext(ctx))
+	if err != nil {
+		report.Error = ClassifyError(err).Error()
+		return report
+	}
+	channels, err := session.GuildChannels(userGuild.ID, discordgo.WithContext(ctx))
+	if err != nil {
+		report.Error = ClassifyError(err).Error()
+		return report
+	}
+	member, err := session.GuildMember(userGuild.ID, botID, discordgo.WithContext(ctx))
+	if err != nil {
+		report.Error = ClassifyError(err).Error()
+		return report
+	}
+
+	// the state computes the permissions from the roles and the overwrites in the same way as the gateway cache.
+	state := discordgo.NewState()
+	guild.Channels = channels
+	guild.Members = []*discordgo.Member{member}
+	if err := state.GuildAdd(guild); err != nil {
+		report.Error = err.Error()
+		return report
+	}
+
+	for _, channel := range channels {
+		sendPermission, ok := sendPermissions[channel.Type]
+		if !ok {
+			continue
+		}
+		permissions, err := state.UserChannelPermissions(botID, channel.ID)
+		if err != nil {
+			continue
+		}
+		if permissions&discordgo.PermissionViewChannel == 0 {
+			// Discord denies every permission in channels which can not be viewed, but discordgo keeps them.
+			permissions = 0
+		}
+		read := int64(discordgo.PermissionViewChannel | discordgo.PermissionReadMessageHistory)
+		embed := int64(discordgo.PermissionViewChannel|discordgo.PermissionEmbedLinks) | sendPermission
+		if missing := (read | embed) &^ permissions; missing != 0 {
+			report.Channels = append(report.Channels, ChannelReport{
+				ID:       channel.ID,
+				Name:     channel.Name,
+				CanRead:  permissions&read == read,
+				CanEmbed: permissions&embed == embed,
+				Missing:  permissionNamesOf(missing),
+			})
+		}
+	}
+	return report
+}
+
+// permissionNamesOf returns the names of the permissions checked by Preflight.
+func permissionNamesOf(permissions int64) []string {
+	names := make([]string, 0)
+	for _, p := range permissionNames {
+		if permissions&p.permission != 0 {
+			names = append(names, p.name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,148 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// redirectTransport sends the requests to the Discord API to the test server.
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (t redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// newTestSession returns a session whose requests are handled by the handlers of the paths under /api/v9.
+func newTestSession(t *testing.T, handlers map[string]any) *discordgo.Session {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	for path, body := range handlers {
+		mux.HandleFunc("/api/v"+discordgo.APIVersion+path, func(w http.ResponseWriter, _ *http.Request) {
+			if code, ok := body.(int); ok {
+				w.WriteHeader(code)
+				_, _ = w.Write([]byte(`{"message": "` + http.StatusText(code) + `", "code": 0}`))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(body)
+		})
+	}
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	target, _ := url.Parse(server.URL)
+	session, _ := discordgo.New("Bot token")
+	session.Client = &http.Client{Transport: redirectTransport{target: target}}
+	session.MaxRestRetries = 0
+	return session
+}
+
+func TestPreflight(t *testing.T) {
+	t.Parallel()
+
+	everyone := &discordgo.Role{ID: "g1", Permissions: discordgo.PermissionViewChannel | discordgo.PermissionSendMessages | discordgo.PermissionReadMessageHistory}
+	bot := &discordgo.Role{ID: "bot", Permissions: discordgo.PermissionEmbedLinks}
+	deny := func(permissions int64) []*discordgo.PermissionOverwrite {
+		return []*discordgo.PermissionOverwrite{{ID: "g1", Type: discordgo.PermissionOverwriteTypeRole, Deny: permissions}}
+	}
+
+	session := newTestSession(t, map[string]any{
+		"/users/@me":             &discordgo.User{ID: "bot", Username: "felm"},
+		"/applications/@me":      &discordgo.Application{ID: "bot", Flags: 1 << 19},
+		"/users/@me/guilds":      []*discordgo.UserGuild{{ID: "g1", Name: "guild"}, {ID: "g2", Name: "forbidden"}},
+		"/guilds/g1":             &discordgo.Guild{ID: "g1", Name: "guild", OwnerID: "owner", Roles: []*discordgo.Role{everyone, bot}},
+		"/guilds/g1/members/bot": &discordgo.Member{User: &discordgo.User{ID: "bot"}, Roles: []string{"bot"}},
+		"/guilds/g1/channels": []*discordgo.Channel{
+			{ID: "ok", GuildID: "g1", Name: "general", Type: discordgo.ChannelTypeGuildText},
+			{ID: "private", GuildID: "g1", Name: "private", Type: discordgo.ChannelTypeGuildText, PermissionOverwrites: deny(discordgo.PermissionViewChannel)},
+			{ID: "noembed", GuildID: "g1", Name: "announcements", Type: discordgo.ChannelTypeGuildNews, PermissionOverwrites: deny(discordgo.PermissionSendMessages)},
+			{ID: "category", GuildID: "g1", Name: "category", Type: discordgo.ChannelTypeGuildCategory, PermissionOverwrites: deny(discordgo.PermissionViewChannel)},
+		},
+		"/guilds/g2": http.StatusForbidden,
+	})
+
+	report, err := preflight(context.Background(), session, discordgo.IntentGuildMessages|discordgo.IntentMessageContent|discordgo.IntentGuildMembers)
+	if err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+
+	if report.Bot.ID != "bot" || report.Bot.Username != "felm" {
+		t.Errorf("expected the bot user but received %+v", report.Bot)
+	}
+	if !slices.Equal(report.Intents.Missing, []string{"guild_members"}) || report.OK() {
+		t.Errorf("expected guild_members to be missing but received %v", report.Intents.Missing)
+	}
+	if len(report.Guilds) != 2 {
+		t.Fatalf("expected 2 guilds but received %d", len(report.Guilds))
+	}
+
+	guild := report.Guilds[0]
+	if guild.Error != "" {
+		t.Fatalf("expected the guild to be inspected but received %s", guild.Error)
+	}
+	want := []ChannelReport{
+		{ID: "private", Name: "private", CanRead: false, CanEmbed: false, Missing: []string{"view_channel", "read_message_history", "send_messages", "embed_links"}},
+		{ID: "noembed", Name: "announcements", CanRead: true, CanEmbed: false, Missing: []string{"send_messages"}},
+	}
+	if len(guild.Channels) != len(want) {
+		t.Fatalf("expected %d channels but received %+v", len(want), guild.Channels)
+	}
+	for i, channel := range guild.Channels {
+		if channel.ID != want[i].ID || channel.CanRead != want[i].CanRead || channel.CanEmbed != want[i].CanEmbed || !slices.Equal(channel.Missing, want[i].Missing) {
+			t.Errorf("expected %+v but received %+v", want[i], channel)
+		}
+	}
+
+	if report.Guilds[1].Error == "" {
+		t.Error("expected the forbidden guild to report an error")
+	}
+}
+
+func TestPreflightUnauthorized(t *testing.T) {
+	t.Parallel()
+
+	session := newTestSession(t, map[string]any{
+		"/users/@me": http.StatusUnauthorized,
+	})
+	if _, err := preflight(context.Background(), session, discordgo.IntentGuildMessages); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized but received %v", err)
+	}
+}
+
+func TestMissingIntents(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		intents discordgo.Intent
+		flags   int
+		want    []string
+	}{
+		{discordgo.IntentGuildMessages, 0, []string{}},
+		{PrivilegedIntents, 0, []string{"guild_members", "guild_presences", "message_content"}},
+		{PrivilegedIntents, 1<<12 | 1<<15 | 1<<18, []string{}},
+		{discordgo.IntentMessageContent, 1 << 19, []string{}},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Parallel()
+			if actual := missingIntents(tt.intents, tt.flags); !slices.Equal(actual, tt.want) {
+				t.Errorf("expected %v but received %v", tt.want, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package discord
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aqyuki/felm/pkg/logging"
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+// MinimumPresenceInterval is the minimum interval to refresh the presence.
+// Presence updates share the gateway rate limit with every other command, so they must not be sent too often.
+const MinimumPresenceInterval = 1 * time.Minute
+
+// activityTypes maps the names accepted by ParsePresence to the activity types.
+var activityTypes = map[string]discordgo.ActivityType{
+	"playing":   discordgo.ActivityTypeGame,
+	"streaming": discordgo.ActivityTypeStreaming,
+	"listening": discordgo.ActivityTypeListening,
+	"watching":  discordgo.ActivityTypeWatching,
+	"custom":    discordgo.ActivityTypeCustom,
+	"competing": discordgo.ActivityTypeCompeting,
+}
+
+// statuses are the statuses accepted by ParsePresence.
+var statuses = map[string]discordgo.Status{
+	"online":    discordgo.StatusOnline,
+	"idle":      discordgo.StatusIdle,
+	"dnd":       discordgo.StatusDoNotDisturb,
+	"invisible": discordgo.StatusInvisible,
+}
+
+// PresenceVars returns the template variables supplied by the application, e.g. CitationsToday.
+type PresenceVars func() map[string]any
+
+// Presence is the status and activity shown in the member list.
+type Presence struct {
+	status       discordgo.Status
+	activityType discordgo.ActivityType
+
+	// text is the activity text. It is nil when no activity is shown.
+	text *template.Template
+}
+
+// ParsePresence parses the status (online, idle, dnd or invisible), the activity type
+// (playing, streaming, listening, watching, custom or competing) and the activity text.
+// The text is a text/template which can refer to {{.Guilds}}, {{.Shards}} and the variables given by WithPresence.
+// An empty text shows no activity.
+func ParsePresence(status, activityType, text string) (*Presence, error) {
+	p := &Presence{}
+
+	var ok bool
+	if p.status, ok = statuses[strings.ToLower(status)]; !ok {
+		return nil, fmt.Errorf("unknown status: %s", status)
+	}
+	if p.activityType, ok = activityTypes[strings.ToLower(activityType)]; !ok {
+		return nil, fmt.Errorf("unknown activity type: %s", activityType)
+	}
+	if text != "" {
+		tmpl, err := template.New("presence").Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid activity text: %w", err)
+		}
+		p.text = tmpl
+	}
+	return p, nil
+}
+
+// render builds the presence update with the variables.
+func (p *Presence) render(vars map[string]any) (discordgo.UpdateStatusData, error) {
+	data := discordgo.UpdateStatusData{Status: string(p.status), Activities: make([]*discordgo.Activity, 0, 1)}
+	if p.text == nil {
+		return data, nil
+	}
+
+	var sb strings.Builder
+	if err := p.text.Execute(&sb, vars); err != nil {
+		return data, fmt.Errorf("failed to render the activity text: %w", err)
+	}
+	activity := &discordgo.Activity{Name: sb.String(), Type: p.activityType}
+	if p.activityType == discordgo.ActivityTypeCustom {
+		// custom statuses show the state instead of the name.
+		activity.State = activity.Name
+	}
+	data.Activities = append(data.Activities, activity)
+	return data, nil
+}
+
+// WithPresence sets the presence on Ready and refreshes it every interval so that the variables stay current.
+// vars supplies the variables in addition to Guilds and Shards, and may be nil.
+func WithPresence(presence *Presence, interval time.Duration, vars PresenceVars) Option {
+	return func(c *Conn) {
+		if presence == nil {
+			return
+		}
+		c.presence = presence
+		c.presenceInterval = max(interval, MinimumPresenceInterval)
+		c.presenceVars = vars
+	}
+}
+
+// SetPresence replaces the presence and its refresh interval while the connection is open.
+// The new presence is sent to the connected shards at once.
+func (c *Conn) SetPresence(presence *Presence, interval time.Duration) {
+	c.mu.Lock()
+	c.presence = presence
+	c.presenceInterval = max(interval, MinimumPresenceInterval)
+	c.mu.Unlock()
+
+	// wake up the refresh loop, which resets its ticker and sends the presence.
+	select {
+	case c.presenceChanged <- struct{}{}:
+	default:
+	}
+}
+
+// currentPresence returns the presence and its refresh interval.
+func (c *Conn) currentPresence() (*Presence, time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.presence, c.presenceInterval
+}
+
+// templateVars returns the template variables of the presence.
+func (c *Conn) templateVars() map[string]any {
+	guilds := 0
+	for _, status := range c.ShardStatuses() {
+		guilds += status.Guilds
+	}
+	vars := map[string]any{
+		"Guilds": guilds,
+		"Shards": len(c.shards),
+	}
+	if c.presenceVars != nil {
+		maps.Copy(vars, c.presenceVars())
+	}
+	return vars
+}
+
+// updatePresence sends the presence to the shards.
+func (c *Conn) updatePresence(shards ...*shard) {
+	presence, _ := c.currentPresence()
+	if presence == nil {
+		return
+	}
+	logger := logging.Named(c.baseContext, loggerName)
+
+	data, err := presence.render(c.templateVars())
+	if err != nil {
+		logger.Warn("failed to update presence", zap.Error(err))
+		return
+	}
+	for _, s := range shards {
+		if err := s.session.UpdateStatusComplex(data); err != nil {
+			logger.Warn("failed to update presence", zap.Int("shard_id", s.id), zap.Error(err))
+		}
+	}
+}
+
+// connectedShards returns the shards which are connected.
+// Disconnected shards get the presence on Ready when they come back.
+func (c *Conn) connectedShards() []*shard {
+	connected := make([]*shard, 0, len(c.shards))
+	for _, s := range c.shards {
+		if s.snapshot().State == ShardConnected {
+			connected = append(connected, s)
+		}
+	}
+	return connected
+}
+
+// refreshPresence updates the presence every interval until the connection is closed.
+func (c *Conn) refreshPresence() {
+	_, interval := c.currentPresence()
+	ticker := time.NewTicker(max(interval, MinimumPresenceInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.lifetime.Done():
+			return
+		case <-c.presenceChanged:
+			_, interval := c.currentPresence()
+			ticker.Reset(interval)
+			c.updatePresence(c.connectedShards()...)
+		case <-ticker.C:
+			c.updatePresence(c.connectedShards()...)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package discord
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestParsePresence(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		status       string
+		activityType string
+		text         string
+		wantErr      bool
+	}{
+		{"valid", "online", "watching", "{{.Guilds}} servers", false},
+		{"case insensitive", "DND", "Playing", "", false},
+		{"unknown status", "busy", "playing", "", true},
+		{"unknown activity type", "online", "sleeping", "", true},
+		{"broken template", "online", "playing", "{{.Guilds", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ParsePresence(tt.status, tt.activityType, tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected err to be %v but received %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPresenceRender(t *testing.T) {
+	t.Parallel()
+
+	t.Run("template variables are rendered", func(t *testing.T) {
+		t.Parallel()
+
+		p, err := ParsePresence("idle", "watching", "{{.Guilds}} servers, {{.CitationsToday}} today")
+		if err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		data, err := p.render(map[string]any{"Guilds": 3, "CitationsToday": 42})
+		if err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		if data.Status != "idle" || len(data.Activities) != 1 {
+			t.Fatalf("expected idle with an activity but received %+v", data)
+		}
+		if activity := data.Activities[0]; activity.Name != "3 servers, 42 today" || activity.Type != discordgo.ActivityTypeWatching {
+			t.Errorf("expected the rendered activity but received %+v", activity)
+		}
+	})
+
+	t.Run("custom status uses the state", func(t *testing.T) {
+		t.Parallel()
+
+		p, _ := ParsePresence("online", "custom", "citing messages")
+		data, _ := p.render(nil)
+		if data.Activities[0].State != "citing messages" {
+			t.Errorf("expected the state to be set but received %+v", data.Activities[0])
+		}
+	})
+
+	t.Run("unknown variables are errors", func(t *testing.T) {
+		t.Parallel()
+
+		p, _ := ParsePresence("online", "playing", "{{.Unknown}}")
+		if _, err := p.render(map[string]any{}); err == nil {
+			t.Error("expected err to be returned for an unknown variable")
+		}
+	})
+
+	t.Run("no text shows no activity", func(t *testing.T) {
+		t.Parallel()
+
+		p, _ := ParsePresence("dnd", "playing", "")
+		data, _ := p.render(nil)
+		if data.Status != "dnd" || len(data.Activities) != 0 {
+			t.Errorf("expected dnd without activities but received %+v", data)
+		}
+	})
+}
+
+func TestTemplateVars(t *testing.T) {
+	t.Parallel()
+
+	p, _ := ParsePresence("online", "playing", "")
+	conn := NewConn("token", WithPresence(p, time.Second, func() map[string]any {
+		return map[string]any{"CitationsToday": 7}
+	}))
+	if conn.presenceInterval != MinimumPresenceInterval {
+		t.Errorf("expected interval to be at least %s but received %s", MinimumPresenceInterval, conn.presenceInterval)
+	}
+
+	s := newShard(0, nil)
+	s.setGuilds(5)
+	conn.shards = []*shard{s, newShard(1, nil)}
+
+	vars := conn.templateVars()
+	if vars["Guilds"] != 5 || vars["Shards"] != 2 || vars["CitationsToday"] != 7 {
+		t.Errorf("expected guilds, shards and application variables but received %v", vars)
+	}
+}
+
+func TestSetPresence(t *testing.T) {
+	t.Parallel()
+
+	conn := NewConn("token")
+	p, _ := ParsePresence("idle", "playing", "felm")
+	conn.SetPresence(p, 2*time.Minute)
+
+	presence, interval := conn.currentPresence()
+	if presence != p || interval != 2*time.Minute {
+		t.Errorf("expected the new presence and interval but received %v and %s", presence, interval)
+	}
+	select {
+	case <-conn.presenceChanged:
+	default:
+		t.Error("expected the refresh loop to be notified")
+	}
+
+	// a second change before the loop wakes up must not block.
+	conn.SetPresence(p, time.Second)
+	conn.SetPresence(p, time.Second)
+	if _, interval := conn.currentPresence(); interval != MinimumPresenceInterval {
+		t.Errorf("expected interval to be at least %s but received %s", MinimumPresenceInterval, interval)
+	}
+}
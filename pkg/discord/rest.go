@@ -0,0 +1,218 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+	// ErrUnauthorized is returned when the token is rejected.
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrNotFound is returned when the requested resource does not exist.
+	ErrNotFound = errors.New("resource not found")
+
+	// ErrForbidden is returned when the bot is not allowed to access the resource.
+	ErrForbidden = errors.New("access forbidden")
+
+	// ErrRateLimited is returned when Discord rejects the request because of its rate limit.
+	ErrRateLimited = errors.New("rate limited")
+
+	// ErrTransient is returned when the request failed because of a temporary problem.
+	ErrTransient = errors.New("transient failure")
+)
+
+// ClassifyError wraps err with the sentinel error matching its cause.
+// The returned error still wraps err, so errors.As can be used to inspect the original error.
+func ClassifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Response != nil {
+		switch code := restErr.Response.StatusCode; {
+		case code == http.StatusUnauthorized:
+			return fmt.Errorf("%w: %w", ErrUnauthorized, err)
+		case code == http.StatusNotFound:
+			return fmt.Errorf("%w: %w", ErrNotFound, err)
+		case code == http.StatusForbidden:
+			return fmt.Errorf("%w: %w", ErrForbidden, err)
+		case code == http.StatusTooManyRequests:
+			return fmt.Errorf("%w: %w", ErrRateLimited, err)
+		case code >= http.StatusInternalServerError:
+			return fmt.Errorf("%w: %w", ErrTransient, err)
+		}
+		return err
+	}
+
+	var rateLimitErr *discordgo.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return fmt.Errorf("%w: %w", ErrRateLimited, err)
+	}
+
+	// context errors are caused by the caller, so retrying them is meaningless.
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: %w", ErrTransient, err)
+	}
+	return err
+}
+
+// retryAfter returns the duration requested by Discord before retrying, if any.
+func retryAfter(err error) (time.Duration, bool) {
+	var rateLimitErr *discordgo.RateLimitError
+	if errors.As(err, &rateLimitErr) && rateLimitErr.RateLimit != nil && rateLimitErr.TooManyRequests != nil {
+		return rateLimitErr.RetryAfter, true
+	}
+	return 0, false
+}
+
+// RESTOption is a function that configures a REST.
+type RESTOption func(*REST)
+
+// WithMaxAttempts sets the maximum number of attempts for a request.
+func WithMaxAttempts(attempts int) RESTOption {
+	return func(r *REST) {
+		if attempts > 0 {
+			r.maxAttempts = attempts
+		}
+	}
+}
+
+// WithBackoff sets the base and maximum delay between attempts.
+func WithBackoff(base, max time.Duration) RESTOption {
+	return func(r *REST) {
+		if base > 0 && max >= base {
+			r.baseDelay = base
+			r.maxDelay = max
+		}
+	}
+}
+
+// WithRateLimitHook sets the function called with the error of every request rate limited by Discord,
+// including the ones retried. discordgo does not emit *discordgo.RateLimit events for them,
+// because its own retry is disabled.
+func WithRateLimitHook(hook func(err error)) RESTOption {
+	return func(r *REST) {
+		r.onRateLimited = hook
+	}
+}
+
+// REST calls the Discord REST API, classifying errors and retrying transient failures.
+// Retries are made only while the deadline of the given context allows them.
+type REST struct {
+	maxAttempts   int
+	baseDelay     time.Duration
+	maxDelay      time.Duration
+	onRateLimited func(err error)
+
+	// sleep waits for the duration or until the context is done.
+	sleep func(context.Context, time.Duration) error
+}
+
+func NewREST(option ...RESTOption) *REST {
+	r := &REST{
+		maxAttempts: 3,
+		baseDelay:   100 * time.Millisecond,
+		maxDelay:    2 * time.Second,
+		sleep:       sleepContext,
+	}
+	for _, opt := range option {
+		opt(r)
+	}
+	return r
+}
+
+// Channel fetches the channel.
+func (r *REST) Channel(ctx context.Context, session *discordgo.Session, channelID string) (*discordgo.Channel, error) {
+	return do(ctx, r, func(options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+		return session.Channel(channelID, options...)
+	})
+}
+
+// ChannelMessage fetches the message in the channel.
+func (r *REST) ChannelMessage(ctx context.Context, session *discordgo.Session, channelID, messageID string) (*discordgo.Message, error) {
+	return do(ctx, r, func(options ...discordgo.RequestOption) (*discordgo.Message, error) {
+		return session.ChannelMessage(channelID, messageID, options...)
+	})
+}
+
+// ChannelMessageSendComplex sends the message to the channel.
+func (r *REST) ChannelMessageSendComplex(ctx context.Context, session *discordgo.Session, channelID string, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	return do(ctx, r, func(options ...discordgo.RequestOption) (*discordgo.Message, error) {
+		return session.ChannelMessageSendComplex(channelID, data, options...)
+	})
+}
+
+// MessageReactionAdd adds the reaction to the message.
+func (r *REST) MessageReactionAdd(ctx context.Context, session *discordgo.Session, channelID, messageID, emojiID string) error {
+	_, err := do(ctx, r, func(options ...discordgo.RequestOption) (struct{}, error) {
+		return struct{}{}, session.MessageReactionAdd(channelID, messageID, emojiID, options...)
+	})
+	return err
+}
+
+// do calls fn until it succeeds, fails permanently, or the context does not allow another attempt.
+// discordgo's own rate limit retry is disabled so that waiting for the rate limit respects the deadline.
+func do[T any](ctx context.Context, r *REST, fn func(...discordgo.RequestOption) (T, error)) (T, error) {
+	var zero T
+	for attempt := 1; ; attempt++ {
+		value, err := fn(discordgo.WithContext(ctx), discordgo.WithRetryOnRatelimit(false))
+		if err == nil {
+			return value, nil
+		}
+
+		err = ClassifyError(err)
+		if errors.Is(err, ErrRateLimited) && r.onRateLimited != nil {
+			r.onRateLimited(err)
+		}
+		if attempt >= r.maxAttempts || !(errors.Is(err, ErrTransient) || errors.Is(err, ErrRateLimited)) {
+			return zero, err
+		}
+
+		delay, ok := retryAfter(err)
+		if !ok {
+			delay = r.backoff(attempt)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return zero, err
+		}
+		if sleepErr := r.sleep(ctx, delay); sleepErr != nil {
+			return zero, err
+		}
+	}
+}
+
+// backoff returns a delay with full jitter for the attempt.
+func (r *REST) backoff(attempt int) time.Duration {
+	ceiling := r.baseDelay << (attempt - 1)
+	if ceiling <= 0 || ceiling > r.maxDelay {
+		ceiling = r.maxDelay
+	}
+	return rand.N(ceiling) + 1
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,218 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func restError(code int) error {
+	return &discordgo.RESTError{Response: &http.Response{StatusCode: code, Status: http.StatusText(code)}}
+}
+
+func TestClassifyError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"unauthorized", restError(http.StatusUnauthorized), ErrUnauthorized},
+		{"not found", restError(http.StatusNotFound), ErrNotFound},
+		{"forbidden", restError(http.StatusForbidden), ErrForbidden},
+		{"too many requests", restError(http.StatusTooManyRequests), ErrRateLimited},
+		{"server error", restError(http.StatusServiceUnavailable), ErrTransient},
+		{"rate limit error", &discordgo.RateLimitError{RateLimit: &discordgo.RateLimit{TooManyRequests: &discordgo.TooManyRequests{}}}, ErrRateLimited},
+		{"network error", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, ErrTransient},
+		{"unexpected eof", fmt.Errorf("read body: %w", io.ErrUnexpectedEOF), ErrTransient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			actual := ClassifyError(tt.err)
+			if !errors.Is(actual, tt.want) {
+				t.Errorf("expected error to be %v but received %v", tt.want, actual)
+			}
+			if !errors.Is(actual, tt.err) {
+				t.Errorf("expected error to wrap the original error but received %v", actual)
+			}
+		})
+	}
+
+	t.Run("unclassified errors are returned as is", func(t *testing.T) {
+		t.Parallel()
+		for _, err := range []error{
+			restError(http.StatusBadRequest),
+			context.DeadlineExceeded,
+			errors.New("unknown"),
+		} {
+			if actual := ClassifyError(err); actual != err {
+				t.Errorf("expected %v to be returned as is but received %v", err, actual)
+			}
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		t.Parallel()
+		if actual := ClassifyError(nil); actual != nil {
+			t.Errorf("expected nil but received %v", actual)
+		}
+	})
+}
+
+func newTestREST(option ...RESTOption) (*REST, *[]time.Duration) {
+	slept := make([]time.Duration, 0)
+	r := NewREST(option...)
+	r.sleep = func(_ context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+	return r, &slept
+}
+
+func TestREST_do(t *testing.T) {
+	t.Parallel()
+
+	t.Run("transient failures are retried", func(t *testing.T) {
+		t.Parallel()
+
+		r, slept := newTestREST(WithMaxAttempts(3))
+		calls := 0
+		value, err := do(context.Background(), r, func(...discordgo.RequestOption) (int, error) {
+			calls++
+			if calls < 3 {
+				return 0, restError(http.StatusBadGateway)
+			}
+			return 42, nil
+		})
+		if err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		if value != 42 {
+			t.Errorf("expected value to be 42 but received %d", value)
+		}
+		if len(*slept) != 2 {
+			t.Errorf("expected 2 backoffs but received %d", len(*slept))
+		}
+	})
+
+	t.Run("permanent failures are not retried", func(t *testing.T) {
+		t.Parallel()
+
+		r, _ := newTestREST(WithMaxAttempts(3))
+		calls := 0
+		_, err := do(context.Background(), r, func(...discordgo.RequestOption) (int, error) {
+			calls++
+			return 0, restError(http.StatusNotFound)
+		})
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected err to be %v but received %v", ErrNotFound, err)
+		}
+		if calls != 1 {
+			t.Errorf("expected 1 call but received %d", calls)
+		}
+	})
+
+	t.Run("attempts are limited", func(t *testing.T) {
+		t.Parallel()
+
+		r, _ := newTestREST(WithMaxAttempts(2))
+		calls := 0
+		_, err := do(context.Background(), r, func(...discordgo.RequestOption) (int, error) {
+			calls++
+			return 0, restError(http.StatusInternalServerError)
+		})
+		if !errors.Is(err, ErrTransient) {
+			t.Errorf("expected err to be %v but received %v", ErrTransient, err)
+		}
+		if calls != 2 {
+			t.Errorf("expected 2 calls but received %d", calls)
+		}
+	})
+
+	t.Run("rate limit waits for retry after", func(t *testing.T) {
+		t.Parallel()
+
+		r, slept := newTestREST()
+		calls := 0
+		_, err := do(context.Background(), r, func(...discordgo.RequestOption) (int, error) {
+			calls++
+			if calls == 1 {
+				return 0, &discordgo.RateLimitError{RateLimit: &discordgo.RateLimit{
+					TooManyRequests: &discordgo.TooManyRequests{RetryAfter: 750 * time.Millisecond},
+				}}
+			}
+			return 1, nil
+		})
+		if err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		if len(*slept) != 1 || (*slept)[0] != 750*time.Millisecond {
+			t.Errorf("expected to wait 750ms but waited %v", *slept)
+		}
+	})
+
+	t.Run("every rate limited attempt is reported to the hook", func(t *testing.T) {
+		t.Parallel()
+
+		reported := 0
+		r, _ := newTestREST(WithRateLimitHook(func(err error) {
+			if !errors.Is(err, ErrRateLimited) {
+				t.Errorf("expected err to be %v but received %v", ErrRateLimited, err)
+			}
+			reported++
+		}))
+		_, _ = do(context.Background(), r, func(...discordgo.RequestOption) (int, error) {
+			return 0, &discordgo.RateLimitError{RateLimit: &discordgo.RateLimit{
+				TooManyRequests: &discordgo.TooManyRequests{RetryAfter: time.Millisecond},
+			}}
+		})
+		_, _ = do(context.Background(), r, func(...discordgo.RequestOption) (int, error) {
+			return 0, restError(http.StatusServiceUnavailable)
+		})
+		if reported != 3 {
+			t.Errorf("expected 3 rate limits to be reported but received %d", reported)
+		}
+	})
+
+	t.Run("retry is abandoned when the deadline does not allow it", func(t *testing.T) {
+		t.Parallel()
+
+		r, slept := newTestREST()
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err := do(ctx, r, func(...discordgo.RequestOption) (int, error) {
+			return 0, &discordgo.RateLimitError{RateLimit: &discordgo.RateLimit{
+				TooManyRequests: &discordgo.TooManyRequests{RetryAfter: time.Minute},
+			}}
+		})
+		if !errors.Is(err, ErrRateLimited) {
+			t.Errorf("expected err to be %v but received %v", ErrRateLimited, err)
+		}
+		if len(*slept) != 0 {
+			t.Errorf("expected not to wait but waited %v", *slept)
+		}
+	})
+}
+
+func TestREST_backoff(t *testing.T) {
+	t.Parallel()
+
+	r := NewREST(WithBackoff(10*time.Millisecond, 50*time.Millisecond))
+	for attempt := 1; attempt <= 10; attempt++ {
+		delay := r.backoff(attempt)
+		if delay <= 0 || delay > 50*time.Millisecond {
+			t.Errorf("expected delay of attempt %d to be in (0, 50ms] but received %v", attempt, delay)
+		}
+	}
+}
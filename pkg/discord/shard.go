@@ -0,0 +1,257 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ShardCountAuto uses the number of shards recommended by Discord.
+const ShardCountAuto = 0
+
+// identifyInterval is the interval Discord requires between identifies of the same rate limit key.
+const identifyInterval = 5 * time.Second
+
+// ShardState is the connection state of a shard.
+type ShardState string
+
+const (
+	ShardIdle         ShardState = "idle"
+	ShardConnecting   ShardState = "connecting"
+	ShardConnected    ShardState = "connected"
+	ShardDisconnected ShardState = "disconnected"
+	ShardClosed       ShardState = "closed"
+)
+
+// ShardStatus is a snapshot of the state of a shard.
+type ShardStatus struct {
+	ID     int
+	State  ShardState
+	Guilds int
+
+	// Reconnects is the number of times the shard connected again after it was disconnected.
+	Reconnects int
+
+	// Since is the time the shard entered the state.
+	Since time.Time
+}
+
+// WithShardCount sets the number of shards. ShardCountAuto uses the number recommended by Discord.
+func WithShardCount(count int) Option {
+	return func(c *Conn) {
+		if count >= 0 {
+			c.shardCount = count
+		}
+	}
+}
+
+// shard is a gateway connection handling a part of the guilds.
+type shard struct {
+	id      int
+	session *discordgo.Session
+
+	// preClose are the functions to unregister the handlers of the shard.
+	preClose []func()
+
+	mu     sync.Mutex
+	status ShardStatus
+
+	// everConnected reports whether the shard has connected once, to tell reconnects from the first connection.
+	everConnected bool
+
+	// reconnecting reports whether a reconnect loop is running.
+	reconnecting bool
+
+	// disconnectTimer fires when the shard stays disconnected too long.
+	disconnectTimer *time.Timer
+}
+
+func newShard(id int, session *discordgo.Session) *shard {
+	return &shard{
+		id:       id,
+		session:  session,
+		preClose: make([]func(), 0),
+		status:   ShardStatus{ID: id, State: ShardIdle, Since: time.Now()},
+	}
+}
+
+func (s *shard) setState(state ShardState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.setStateLocked(state)
+}
+
+// setStateLocked is setState for callers holding s.mu.
+func (s *shard) setStateLocked(state ShardState) {
+	if s.status.State != state {
+		s.status.State = state
+		s.status.Since = time.Now()
+	}
+}
+
+func (s *shard) setGuilds(guilds int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status.Guilds = guilds
+}
+
+// unregister runs the functions to unregister the handlers. It is safe to call more than once.
+func (s *shard) unregister() {
+	s.mu.Lock()
+	preClose := s.preClose
+	s.preClose = nil
+	s.mu.Unlock()
+
+	for _, fn := range preClose {
+		fn()
+	}
+}
+
+// connected records a connection and reports whether it is a reconnect.
+func (s *shard) connected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.disconnectTimer != nil {
+		s.disconnectTimer.Stop()
+		s.disconnectTimer = nil
+	}
+	reconnected := s.everConnected
+	if reconnected {
+		s.status.Reconnects++
+	}
+	s.everConnected = true
+	s.setStateLocked(ShardConnected)
+	return reconnected
+}
+
+// disconnected records a disconnection and calls exceeded if the shard is still disconnected after max.
+// The timer keeps running across failed reconnect attempts. 0 disables the timer.
+func (s *shard) disconnected(max time.Duration, exceeded func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.setStateLocked(ShardDisconnected)
+	if max > 0 && s.disconnectTimer == nil {
+		s.disconnectTimer = time.AfterFunc(max, exceeded)
+	}
+}
+
+// stopDisconnectTimer stops the timer started by disconnected.
+func (s *shard) stopDisconnectTimer() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.disconnectTimer != nil {
+		s.disconnectTimer.Stop()
+		s.disconnectTimer = nil
+	}
+}
+
+// beginReconnect reports whether the caller should start reconnecting, false if another reconnect is running.
+func (s *shard) beginReconnect() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.reconnecting {
+		return false
+	}
+	s.reconnecting = true
+	return true
+}
+
+func (s *shard) endReconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reconnecting = false
+}
+
+func (s *shard) snapshot() ShardStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.status
+}
+
+// shardPlan is the number of shards and how many of them can identify at once.
+type shardPlan struct {
+	count          int
+	maxConcurrency int
+}
+
+// planShards resolves the number of shards, asking Discord when it is ShardCountAuto.
+func planShards(count int, gatewayBot func() (*discordgo.GatewayBotResponse, error)) (shardPlan, error) {
+	if count != ShardCountAuto {
+		return shardPlan{count: count, maxConcurrency: 1}, nil
+	}
+	resp, err := gatewayBot()
+	if err != nil {
+		return shardPlan{}, fmt.Errorf("error was occurred when trying to fetch the recommended shard count: %w", err)
+	}
+	plan := shardPlan{count: resp.Shards, maxConcurrency: resp.SessionStartLimit.MaxConcurrency}
+	if plan.count < 1 {
+		plan.count = 1
+	}
+	if plan.maxConcurrency < 1 {
+		plan.maxConcurrency = 1
+	}
+	return plan, nil
+}
+
+// openShards opens the shards in groups of maxConcurrency, waiting between groups as Discord requires.
+// Shards in a group have distinct rate limit keys (shard_id % max_concurrency), so they can identify at once.
+// If any shard fails, the opened shards are closed and the error is returned.
+func openShards(shards []*shard, maxConcurrency int, open func(*shard) error, wait func() error) error {
+	opened := make([]*shard, 0, len(shards))
+	closeOpened := func() {
+		for _, s := range opened {
+			_ = s.session.Close()
+		}
+	}
+
+	for start := 0; start < len(shards); start += maxConcurrency {
+		if start > 0 {
+			if err := wait(); err != nil {
+				closeOpened()
+				return err
+			}
+		}
+
+		group := shards[start:min(start+maxConcurrency, len(shards))]
+		errs := make([]error, len(group))
+		var wg sync.WaitGroup
+		for i, s := range group {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = open(s)
+			}()
+		}
+		wg.Wait()
+
+		for i, s := range group {
+			if errs[i] == nil {
+				opened = append(opened, s)
+			}
+		}
+		if err := errors.Join(errs...); err != nil {
+			closeOpened()
+			return err
+		}
+	}
+	return nil
+}
+
+// waitContext waits for the duration or until the context is done.
+func waitContext(ctx context.Context, d time.Duration) func() error {
+	return func() error {
+		return sleepContext(ctx, d)
+	}
+}
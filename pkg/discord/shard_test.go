@@ -0,0 +1,156 @@
+package discord
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestPlanShards(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fixed count", func(t *testing.T) {
+		t.Parallel()
+
+		plan, err := planShards(4, func() (*discordgo.GatewayBotResponse, error) {
+			t.Error("expected gateway bot not to be called")
+			return nil, nil
+		})
+		if err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		if plan != (shardPlan{count: 4, maxConcurrency: 1}) {
+			t.Errorf("expected 4 shards with concurrency 1 but received %+v", plan)
+		}
+	})
+
+	t.Run("recommended count", func(t *testing.T) {
+		t.Parallel()
+
+		plan, err := planShards(ShardCountAuto, func() (*discordgo.GatewayBotResponse, error) {
+			return &discordgo.GatewayBotResponse{
+				Shards:            3,
+				SessionStartLimit: discordgo.SessionInformation{MaxConcurrency: 2},
+			}, nil
+		})
+		if err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		if plan != (shardPlan{count: 3, maxConcurrency: 2}) {
+			t.Errorf("expected 3 shards with concurrency 2 but received %+v", plan)
+		}
+	})
+
+	t.Run("gateway bot fails", func(t *testing.T) {
+		t.Parallel()
+
+		want := errors.New("unauthorized")
+		_, err := planShards(ShardCountAuto, func() (*discordgo.GatewayBotResponse, error) {
+			return nil, want
+		})
+		if !errors.Is(err, want) {
+			t.Errorf("expected err to be %v but received %v", want, err)
+		}
+	})
+}
+
+func newTestShards(count int) []*shard {
+	shards := make([]*shard, 0, count)
+	for id := 0; id < count; id++ {
+		session, _ := discordgo.New("Bot token")
+		shards = append(shards, newShard(id, session))
+	}
+	return shards
+}
+
+func TestOpenShards(t *testing.T) {
+	t.Parallel()
+
+	t.Run("shards are opened in groups of max concurrency", func(t *testing.T) {
+		t.Parallel()
+
+		var mu sync.Mutex
+		groups := make([][]int, 1)
+		open := func(s *shard) error {
+			mu.Lock()
+			defer mu.Unlock()
+			groups[len(groups)-1] = append(groups[len(groups)-1], s.id)
+			return nil
+		}
+		wait := func() error {
+			groups = append(groups, nil)
+			return nil
+		}
+
+		if err := openShards(newTestShards(5), 2, open, wait); err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		if len(groups) != 3 {
+			t.Fatalf("expected 3 groups but received %v", groups)
+		}
+		for i, size := range []int{2, 2, 1} {
+			if len(groups[i]) != size {
+				t.Errorf("expected group %d to have %d shards but received %v", i, size, groups[i])
+			}
+		}
+	})
+
+	t.Run("failure stops opening", func(t *testing.T) {
+		t.Parallel()
+
+		want := errors.New("invalid session")
+		opened := 0
+		open := func(s *shard) error {
+			if s.id == 1 {
+				return want
+			}
+			opened++
+			return nil
+		}
+
+		err := openShards(newTestShards(3), 1, open, func() error { return nil })
+		if !errors.Is(err, want) {
+			t.Errorf("expected err to be %v but received %v", want, err)
+		}
+		if opened != 1 {
+			t.Errorf("expected only the first shard to be opened but opened %d", opened)
+		}
+	})
+
+	t.Run("cancelled wait stops opening", func(t *testing.T) {
+		t.Parallel()
+
+		want := errors.New("cancelled")
+		opened := 0
+		open := func(*shard) error {
+			opened++
+			return nil
+		}
+
+		err := openShards(newTestShards(3), 1, open, func() error { return want })
+		if !errors.Is(err, want) {
+			t.Errorf("expected err to be %v but received %v", want, err)
+		}
+		if opened != 1 {
+			t.Errorf("expected only the first shard to be opened but opened %d", opened)
+		}
+	})
+}
+
+func TestShardStatus(t *testing.T) {
+	t.Parallel()
+
+	s := newTestShards(1)[0]
+	if status := s.snapshot(); status.State != ShardIdle {
+		t.Errorf("expected state to be %s but received %s", ShardIdle, status.State)
+	}
+
+	s.setState(ShardConnected)
+	s.setGuilds(3)
+	status := s.snapshot()
+	if status.State != ShardConnected || status.Guilds != 3 {
+		t.Errorf("expected connected shard with 3 guilds but received %+v", status)
+	}
+}
@@ -0,0 +1,164 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aqyuki/felm/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// ErrHandlersAbandoned is returned by Shutdown when some handlers did not finish before the deadline.
+var ErrHandlersAbandoned = errors.New("handlers were abandoned")
+
+// AbandonedHandler describes a handler which was still running when the shutdown deadline passed.
+type AbandonedHandler struct {
+	Event   string
+	TraceID string
+	Elapsed time.Duration
+}
+
+// AbandonedError is returned by Shutdown with the handlers which did not finish before the deadline.
+type AbandonedError struct {
+	Handlers []AbandonedHandler
+}
+
+func (e *AbandonedError) Error() string {
+	events := make([]string, 0, len(e.Handlers))
+	for _, h := range e.Handlers {
+		events = append(events, fmt.Sprintf("%s (trace_id = %s, elapsed = %s)", h.Event, h.TraceID, h.Elapsed.Round(time.Millisecond)))
+	}
+	return fmt.Sprintf("%s: %s", ErrHandlersAbandoned, strings.Join(events, ", "))
+}
+
+// Is reports whether the target is ErrHandlersAbandoned.
+func (e *AbandonedError) Is(target error) bool {
+	return target == ErrHandlersAbandoned
+}
+
+// handlerCall is a running handler.
+type handlerCall struct {
+	event   string
+	traceID string
+	start   time.Time
+}
+
+// inflight tracks the running handlers so that they can be drained on shutdown.
+type inflight struct {
+	// ctx is the parent context of the handlers. It is not cancelled with the base context,
+	// so that the handlers running when a shutdown signal arrives can finish.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	closed bool
+	nextID uint64
+	calls  map[uint64]handlerCall
+	done   chan struct{}
+}
+
+func newInflight(base context.Context) *inflight {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(base))
+	return &inflight{
+		ctx:    ctx,
+		cancel: cancel,
+		calls:  make(map[uint64]handlerCall),
+	}
+}
+
+// begin records a handler starting and returns the function to call when it returns.
+// It returns false once the tracker is closed, and the event must be dropped.
+func (f *inflight) begin(event, traceID string) (func(), bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return nil, false
+	}
+	id := f.nextID
+	f.nextID++
+	f.calls[id] = handlerCall{event: event, traceID: traceID, start: time.Now()}
+
+	return func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		delete(f.calls, id)
+		if f.closed && len(f.calls) == 0 && f.done != nil {
+			close(f.done)
+			f.done = nil
+		}
+	}, true
+}
+
+// close stops accepting handlers and returns a channel which is closed when every running handler has returned.
+func (f *inflight) close() <-chan struct{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	done := make(chan struct{})
+	if len(f.calls) == 0 {
+		close(done)
+	} else {
+		f.done = done
+	}
+	return done
+}
+
+// running returns the handlers which have not returned yet, the oldest first.
+func (f *inflight) running() []AbandonedHandler {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	handlers := make([]AbandonedHandler, 0, len(f.calls))
+	for _, call := range f.calls {
+		handlers = append(handlers, AbandonedHandler{Event: call.event, TraceID: call.traceID, Elapsed: now.Sub(call.start)})
+	}
+	sort.Slice(handlers, func(i, j int) bool {
+		return handlers[i].Elapsed > handlers[j].Elapsed
+	})
+	return handlers
+}
+
+// Shutdown stops dispatching new events, waits for the running handlers until ctx is done and closes the connection.
+// Handlers still running when ctx is done have their contexts cancelled and are reported with *AbandonedError.
+func (c *Conn) Shutdown(ctx context.Context) error {
+	logger := logging.Named(c.baseContext, loggerName)
+	start := time.Now()
+
+	// unregister the handlers first, so that no event is dispatched while draining.
+	c.stopLifetime()
+	c.unregisterHandlers()
+	done := c.inflight.close()
+
+	var abandoned []AbandonedHandler
+	select {
+	case <-done:
+		logger.Info("in-flight handlers were drained", zap.Duration("elapsed", time.Since(start)))
+	case <-ctx.Done():
+		abandoned = c.inflight.running()
+		for _, h := range abandoned {
+			logger.Warn("handler was abandoned on shutdown",
+				zap.String(logging.TraceIDKey, h.TraceID),
+				zap.String("event", h.Event),
+				zap.Duration("elapsed", h.Elapsed))
+		}
+	}
+	c.inflight.cancel()
+
+	errs := []error{c.closeShards()}
+	if len(abandoned) > 0 {
+		errs = append(errs, &AbandonedError{Handlers: abandoned})
+	}
+
+	// flush buffered logs before the process exits. stderr can not be synced on some platforms, so ignore the error.
+	_ = logger.Sync()
+	return errors.Join(errs...)
+}
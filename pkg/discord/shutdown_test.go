@@ -0,0 +1,151 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aqyuki/felm/pkg/report"
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestShutdown(t *testing.T) {
+	t.Parallel()
+
+	t.Run("running handlers are drained", func(t *testing.T) {
+		t.Parallel()
+
+		conn := NewConn("token")
+		release := make(chan struct{})
+		finished := make(chan error, 1)
+		fn := buildEventHandler(conn.inflight, time.Minute, report.Log(), func(ctx context.Context, _ *discordgo.Session, _ *discordgo.ChannelDelete) error {
+			<-release
+			finished <- ctx.Err()
+			return nil
+		})
+		go fn(nil, &discordgo.ChannelDelete{})
+		waitRunning(t, conn.inflight, 1)
+		if n := conn.InflightHandlers(); n != 1 {
+			t.Errorf("expected 1 handler in flight but received %d", n)
+		}
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			close(release)
+		}()
+		if err := conn.Shutdown(context.Background()); err != nil {
+			t.Errorf("expected err to be nil but received %v", err)
+		}
+		if err := <-finished; err != nil {
+			t.Errorf("expected handler context to be alive while draining but received %v", err)
+		}
+	})
+
+	t.Run("reports of running handlers are drained", func(t *testing.T) {
+		t.Parallel()
+
+		conn := NewConn("token")
+		reporting := make(chan struct{})
+		release := make(chan struct{})
+		reported := make(chan error, 1)
+		reporter := report.ReporterFunc(func(ctx context.Context, _ report.Event) error {
+			close(reporting)
+			<-release
+			reported <- ctx.Err()
+			return nil
+		})
+		fn := buildEventHandler(conn.inflight, time.Minute, reporter, func(context.Context, *discordgo.Session, *discordgo.ChannelDelete) error {
+			return errors.New("handler failed")
+		})
+		go fn(nil, &discordgo.ChannelDelete{})
+		<-reporting
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			close(release)
+		}()
+		if err := conn.Shutdown(context.Background()); err != nil {
+			t.Errorf("expected err to be nil but received %v", err)
+		}
+		select {
+		case err := <-reported:
+			if err != nil {
+				t.Errorf("expected report context to be alive while draining but received %v", err)
+			}
+		default:
+			t.Error("expected shutdown to wait for the report")
+		}
+	})
+
+	t.Run("handlers running after the deadline are abandoned", func(t *testing.T) {
+		t.Parallel()
+
+		conn := NewConn("token")
+		cancelled := make(chan error, 1)
+		fn := buildEventHandler(conn.inflight, time.Minute, report.Log(), func(ctx context.Context, _ *discordgo.Session, _ *discordgo.ChannelDelete) error {
+			<-ctx.Done()
+			cancelled <- ctx.Err()
+			return nil
+		})
+		go fn(nil, &discordgo.ChannelDelete{})
+		waitRunning(t, conn.inflight, 1)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := conn.Shutdown(ctx)
+
+		var abandoned *AbandonedError
+		if !errors.As(err, &abandoned) || !errors.Is(err, ErrHandlersAbandoned) {
+			t.Fatalf("expected err to be %v but received %v", ErrHandlersAbandoned, err)
+		}
+		if len(abandoned.Handlers) != 1 || abandoned.Handlers[0].Event != "ChannelDelete" {
+			t.Errorf("expected ChannelDelete to be reported but received %+v", abandoned.Handlers)
+		}
+		if err := <-cancelled; !errors.Is(err, context.Canceled) {
+			t.Errorf("expected handler context to be cancelled but received %v", err)
+		}
+	})
+
+	t.Run("events are dropped after shutdown", func(t *testing.T) {
+		t.Parallel()
+
+		conn := NewConn("token")
+		if err := conn.Shutdown(context.Background()); err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+
+		called := false
+		fn := buildEventHandler(conn.inflight, time.Minute, report.Log(), func(context.Context, *discordgo.Session, *discordgo.ChannelDelete) error {
+			called = true
+			return nil
+		})
+		fn(nil, &discordgo.ChannelDelete{})
+		if called {
+			t.Error("expected handler not to be called after shutdown")
+		}
+	})
+
+	t.Run("base context cancellation does not cancel handlers", func(t *testing.T) {
+		t.Parallel()
+
+		base, cancel := context.WithCancel(context.Background())
+		tracker := newInflight(base)
+		cancel()
+		if err := tracker.ctx.Err(); err != nil {
+			t.Errorf("expected handler context to be alive but received %v", err)
+		}
+	})
+}
+
+func waitRunning(t *testing.T, tracker *inflight, count int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for len(tracker.running()) != count {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d handlers to be running but received %d", count, len(tracker.running()))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
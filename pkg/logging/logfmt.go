@@ -0,0 +1,95 @@
+package logging
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+var logfmtPool = buffer.NewPool()
+
+// logfmtEncoder encodes the entries as key=value pairs. Fields in objects are flattened with dotted keys,
+// and the fields are sorted by their keys after the time, level, logger, caller and msg.
+type logfmtEncoder struct {
+	// MapObjectEncoder holds the fields added with With.
+	*zapcore.MapObjectEncoder
+}
+
+func newLogfmtEncoder() zapcore.Encoder {
+	return &logfmtEncoder{MapObjectEncoder: zapcore.NewMapObjectEncoder()}
+}
+
+func (e *logfmtEncoder) Clone() zapcore.Encoder {
+	clone := zapcore.NewMapObjectEncoder()
+	maps.Copy(clone.Fields, e.Fields)
+	return &logfmtEncoder{MapObjectEncoder: clone}
+}
+
+func (e *logfmtEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	enc := zapcore.NewMapObjectEncoder()
+	maps.Copy(enc.Fields, e.Fields)
+	for _, field := range fields {
+		field.AddTo(enc)
+	}
+
+	buf := logfmtPool.Get()
+	appendLogfmtPair(buf, "time", entry.Time)
+	appendLogfmtPair(buf, "level", entry.Level.String())
+	if entry.LoggerName != "" {
+		appendLogfmtPair(buf, logger, entry.LoggerName)
+	}
+	if entry.Caller.Defined {
+		appendLogfmtPair(buf, caller, entry.Caller.TrimmedPath())
+	}
+	appendLogfmtPair(buf, "msg", entry.Message)
+	appendLogfmtFields(buf, "", enc.Fields)
+	if entry.Stack != "" {
+		appendLogfmtPair(buf, stacktrace, entry.Stack)
+	}
+	buf.AppendString(zapcore.DefaultLineEnding)
+	return buf, nil
+}
+
+// appendLogfmtFields appends the fields sorted by their keys, flattening the objects.
+func appendLogfmtFields(buf *buffer.Buffer, prefix string, fields map[string]any) {
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		if object, ok := fields[key].(map[string]any); ok {
+			appendLogfmtFields(buf, prefix+key+".", object)
+			continue
+		}
+		appendLogfmtPair(buf, prefix+key, fields[key])
+	}
+}
+
+func appendLogfmtPair(buf *buffer.Buffer, key string, value any) {
+	if buf.Len() > 0 {
+		buf.AppendByte(' ')
+	}
+	buf.AppendString(key)
+	buf.AppendByte('=')
+	buf.AppendString(formatLogfmtValue(value))
+}
+
+// formatLogfmtValue formats a value for logfmt, quoting it when it is empty or contains spaces, quotes or =.
+func formatLogfmtValue(value any) string {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case time.Time:
+		s = v.Format(time.RFC3339Nano)
+	case []byte:
+		s = string(v)
+	default:
+		s = fmt.Sprint(v)
+	}
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n\\") {
+		return fmt.Sprintf("%q", s)
+	}
+	return s
+}
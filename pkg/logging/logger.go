@@ -2,6 +2,8 @@
 
 import (
 	"context"
+	"fmt"
+	"maps"
 	"os"
 	"strings"
 	"sync"
@@ -21,36 +23,139 @@
 )
 
 func NewLoggerFromEnv() *zap.Logger {
+	logger, _ := NewLeveledLoggerFromEnv()
+	return logger
+}
+
+// NewLeveledLoggerFromEnv is NewLoggerFromEnv which also returns the levels, so that they can be changed at runtime.
+// LOG_LEVELS overrides the level of named loggers, e.g. discord=debug,cache=warning. Invalid overrides are ignored.
+func NewLeveledLoggerFromEnv(option ...Option) (*zap.Logger, *Levels) {
 	develop := strings.ToLower(strings.TrimSpace(os.Getenv("LOG_MODE"))) == "develop"
 	level := strings.TrimSpace(os.Getenv("LOG_LEVEL"))
-	return NewLogger(develop, level)
+	if overrides, err := ParseLevelOverrides(os.Getenv("LOG_LEVELS")); err == nil {
+		option = append([]Option{withOverrides(overrides)}, option...)
+	}
+	return NewLeveledLogger(develop, level, option...)
 }
 
 func NewLogger(develop bool, level string) *zap.Logger {
-	var cfg *zap.Config
+	logger, _ := NewLeveledLogger(develop, level)
+	return logger
+}
+
+// Option configures the logger built by NewLeveledLogger.
+type Option func(*options)
+
+type options struct {
+	levels    *Levels
+	overrides map[string]zapcore.Level
+	outputs   []zapcore.WriteSyncer
+
+	// samplingInitial and samplingThereafter sample the entries with the same level and message every second.
+	// Sampling is disabled when samplingInitial is 0.
+	samplingInitial    int
+	samplingThereafter int
+
+	// profile is the format of the logs. Empty chooses console in develop mode and gcp otherwise.
+	profile      Profile
+	traceProject string
+}
+
+// WithLevels makes the logger share the levels, e.g. with the logger it replaces. The level given to NewLeveledLogger is ignored.
+func WithLevels(levels *Levels) Option {
+	return func(o *options) {
+		o.levels = levels
+	}
+}
+
+// withOverrides sets the overrides of the levels created by NewLeveledLogger.
+func withOverrides(overrides map[string]zapcore.Level) Option {
+	return func(o *options) {
+		o.overrides = overrides
+	}
+}
+
+// WithOutputs replaces the outputs of the logger, which is stderr by default.
+func WithOutputs(outputs ...zapcore.WriteSyncer) Option {
+	return func(o *options) {
+		if len(outputs) > 0 {
+			o.outputs = outputs
+		}
+	}
+}
+
+// WithSampling logs the first initial entries with the same level and message every second,
+// and every thereafter-th entry after that. An initial of 0 disables the sampling.
+func WithSampling(initial, thereafter int) Option {
+	return func(o *options) {
+		if initial >= 0 && thereafter >= 0 {
+			o.samplingInitial = initial
+			o.samplingThereafter = thereafter
+		}
+	}
+}
+
+// WithProfile sets the format of the logs. By default, it is console in develop mode and gcp otherwise.
+func WithProfile(profile Profile) Option {
+	return func(o *options) {
+		o.profile = profile
+	}
+}
+
+// WithTraceProject sets the Google Cloud project of the traces, so that the gcp profile links the logs to Cloud Trace.
+func WithTraceProject(project string) Option {
+	return func(o *options) {
+		o.traceProject = project
+	}
+}
+
+// Stderr is the default output of the logger.
+func Stderr() zapcore.WriteSyncer {
+	return zapcore.Lock(os.Stderr)
+}
+
+// NewLeveledLogger is NewLogger which also returns the levels, so that they can be changed at runtime.
+func NewLeveledLogger(develop bool, level string, option ...Option) (*zap.Logger, *Levels) {
+	opts := &options{outputs: []zapcore.WriteSyncer{Stderr()}}
+	for _, opt := range option {
+		opt(opts)
+	}
+	levels := opts.levels
+	if levels == nil {
+		levels = NewLevels(levelToZapLevel(level))
+		levels.SetOverrides(opts.overrides)
+	}
+
+	profile := opts.profile
+	zapOptions := []zap.Option{zap.ErrorOutput(Stderr()), zap.AddCaller()}
 	if develop {
-		cfg = &zap.Config{
-			Level:            zap.NewAtomicLevelAt(levelToZapLevel(level)),
-			Development:      true,
-			Encoding:         encodingConsole,
-			OutputPaths:      outputStderr,
-			ErrorOutputPaths: outputStderr,
-			EncoderConfig:    developmentEncoderConfig,
+		if profile == "" {
+			profile = ProfileConsole
 		}
+		zapOptions = append(zapOptions, zap.Development(), zap.AddStacktrace(zapcore.WarnLevel))
 	} else {
-		cfg = &zap.Config{
-			Level:            zap.NewAtomicLevelAt(levelToZapLevel(level)),
-			Encoding:         encodingJSON,
-			EncoderConfig:    productionEncoderConfig,
-			OutputPaths:      outputStderr,
-			ErrorOutputPaths: outputStderr,
+		if profile == "" {
+			profile = ProfileGCP
 		}
+		zapOptions = append(zapOptions, zap.AddStacktrace(zapcore.ErrorLevel))
 	}
-	logger, err := cfg.Build()
-	if err != nil {
-		logger = zap.NewNop()
+	if fields := profileFields(profile); len(fields) > 0 {
+		zapOptions = append(zapOptions, zap.Fields(fields...))
 	}
-	return logger
+	encoder := newEncoder(profile, opts.traceProject)
+
+	// the levels are checked by levelCore, so the core itself accepts every level.
+	core := zapcore.NewCore(encoder, zapcore.NewMultiWriteSyncer(opts.outputs...), zapcore.DebugLevel)
+	if opts.samplingInitial > 0 {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, opts.samplingInitial, opts.samplingThereafter)
+	}
+	return zap.New(&levelCore{Core: core, levels: levels}, zapOptions...), levels
+}
+
+// Named returns the logger of the context named after the subsystem, e.g. discord or handler.citation,
+// so that its level can be overridden.
+func Named(ctx context.Context, name string) *zap.Logger {
+	return FromContext(ctx).Named(name)
 }
 
 func DefaultLogger() *zap.Logger {
@@ -86,13 +191,8 @@ func FromContext(ctx context.Context) *zap.Logger {
 	levelCritical  = "CRITICAL"
 	levelAlert     = "ALERT"
 	levelEmergency = "EMERGENCY"
-
-	encodingConsole = "console"
-	encodingJSON    = "json"
 )
 
-var outputStderr = []string{"stderr"}
-
 var productionEncoderConfig = zapcore.EncoderConfig{
 	TimeKey:        timestamp,
 	LevelKey:       severity,
@@ -122,6 +222,187 @@ func FromContext(ctx context.Context) *zap.Logger {
 	EncodeCaller:   zapcore.ShortCallerEncoder,
 }
 
+// ParseLevel parses the level names accepted by LOG_LEVEL, e.g. debug or WARNING.
+func ParseLevel(level string) (zapcore.Level, bool) {
+	switch strings.ToUpper(level) {
+	case levelDebug, levelInfo, levelWarning, levelError, levelCritical, levelAlert, levelEmergency:
+		return levelToZapLevel(level), true
+	default:
+		return zapcore.InfoLevel, false
+	}
+}
+
+// LevelName returns the name of the level accepted by ParseLevel, e.g. warning.
+func LevelName(level zapcore.Level) string {
+	switch level {
+	case zapcore.DebugLevel:
+		return strings.ToLower(levelDebug)
+	case zapcore.WarnLevel:
+		return strings.ToLower(levelWarning)
+	case zapcore.ErrorLevel:
+		return strings.ToLower(levelError)
+	case zapcore.DPanicLevel:
+		return strings.ToLower(levelCritical)
+	case zapcore.PanicLevel:
+		return strings.ToLower(levelAlert)
+	case zapcore.FatalLevel:
+		return strings.ToLower(levelEmergency)
+	default:
+		return strings.ToLower(levelInfo)
+	}
+}
+
+// ParseLevelOverrides parses the levels of named loggers given as comma separated name=level pairs,
+// e.g. discord=debug,handler.citation=warning. An empty string has no overrides.
+func ParseLevelOverrides(s string) (map[string]zapcore.Level, error) {
+	overrides := make(map[string]zapcore.Level)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("override must be name=level but is %q", pair)
+		}
+		level, ok := ParseLevel(value)
+		if !ok {
+			return nil, fmt.Errorf("unknown level of %s: %q", name, value)
+		}
+		overrides[name] = level
+	}
+	return overrides, nil
+}
+
+// Levels holds the level of the logger and the overrides of its named loggers, which can be changed at runtime.
+// An override of a name applies to its children too, e.g. handler applies to handler.citation,
+// unless the child has its own override.
+type Levels struct {
+	root zap.AtomicLevel
+
+	mu        sync.RWMutex
+	overrides map[string]zapcore.Level
+}
+
+// NewLevels creates the levels with the level of loggers without an override.
+func NewLevels(level zapcore.Level) *Levels {
+	return &Levels{
+		root:      zap.NewAtomicLevelAt(level),
+		overrides: make(map[string]zapcore.Level),
+	}
+}
+
+// Level returns the level of loggers without an override.
+func (l *Levels) Level() zapcore.Level {
+	return l.root.Level()
+}
+
+// SetLevel changes the level of loggers without an override.
+func (l *Levels) SetLevel(level zapcore.Level) {
+	l.root.SetLevel(level)
+}
+
+// Overrides returns a copy of the overrides.
+func (l *Levels) Overrides() map[string]zapcore.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return maps.Clone(l.overrides)
+}
+
+// SetOverride changes the level of the named logger and its children.
+func (l *Levels) SetOverride(name string, level zapcore.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.overrides[name] = level
+}
+
+// RemoveOverride makes the named logger follow the level of its parent again.
+func (l *Levels) RemoveOverride(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.overrides, name)
+}
+
+// SetOverrides replaces every override.
+func (l *Levels) SetOverrides(overrides map[string]zapcore.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.overrides = maps.Clone(overrides)
+	if l.overrides == nil {
+		l.overrides = make(map[string]zapcore.Level)
+	}
+}
+
+// LevelOf returns the level of the named logger, which is the override of the name or its nearest parent.
+func (l *Levels) LevelOf(name string) zapcore.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for name != "" {
+		if level, ok := l.overrides[name]; ok {
+			return level
+		}
+		i := strings.LastIndex(name, ".")
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return l.root.Level()
+}
+
+// Enabled reports whether the entry of the named logger at the level is logged.
+func (l *Levels) Enabled(name string, level zapcore.Level) bool {
+	return l.LevelOf(name).Enabled(level)
+}
+
+// minimum returns the lowest level of the loggers, so that the entries below it are skipped without a name.
+func (l *Levels) minimum() zapcore.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	minimum := l.root.Level()
+	for _, level := range l.overrides {
+		minimum = min(minimum, level)
+	}
+	return minimum
+}
+
+// levelCore drops the entries below the level of their logger.
+// The entries are checked on Write too, because a core wrapping it may write without calling Check.
+type levelCore struct {
+	zapcore.Core
+	levels *Levels
+}
+
+func (c *levelCore) Enabled(level zapcore.Level) bool {
+	return c.levels.minimum().Enabled(level)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), levels: c.levels}
+}
+
+// Check lets the wrapped core check the entry too, so that a sampler under it counts the entries.
+func (c *levelCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.levels.Enabled(entry.LoggerName, entry.Level) {
+		return c.Core.Check(entry, checked)
+	}
+	return checked
+}
+
+func (c *levelCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	if !c.levels.Enabled(entry.LoggerName, entry.Level) {
+		return nil
+	}
+	return c.Core.Write(entry, fields)
+}
+
 func levelToZapLevel(level string) zapcore.Level {
 	switch strings.ToUpper(level) {
 	case levelDebug:
@@ -1,12 +1,15 @@
 package logging
 
 import (
+	"bytes"
 	"context"
+	"strings"
 	"testing"
 	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestNewLoggerFromEnv(t *testing.T) {
@@ -187,3 +190,240 @@ func Test_timeEncoder(t *testing.T) {
 		t.Errorf("Expected %v, but got %v", expected, arr[0])
 	}
 }
+
+func TestNewLeveledLogger(t *testing.T) {
+	t.Parallel()
+
+	logger, level := NewLeveledLogger(false, "warning")
+	if logger.Core().Enabled(zapcore.InfoLevel) {
+		t.Errorf("Expected info to be disabled at warning level")
+	}
+
+	level.SetLevel(zapcore.DebugLevel)
+	if !logger.Core().Enabled(zapcore.DebugLevel) {
+		t.Errorf("Expected debug to be enabled after the level was changed")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		level  string
+		want   zapcore.Level
+		wantOK bool
+	}{
+		{"debug", "debug", zapcore.DebugLevel, true},
+		{"upper case", "WARNING", zapcore.WarnLevel, true},
+		{"unknown", "verbose", zapcore.InfoLevel, false},
+		{"empty", "", zapcore.InfoLevel, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			actual, ok := ParseLevel(tt.level)
+			if actual != tt.want || ok != tt.wantOK {
+				t.Errorf("Expected (%v, %v), but got (%v, %v)", tt.want, tt.wantOK, actual, ok)
+			}
+		})
+	}
+}
+
+func TestLevelName(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"debug", "info", "warning", "error", "critical", "alert", "emergency"} {
+		level, _ := ParseLevel(name)
+		if actual := LevelName(level); actual != name {
+			t.Errorf("Expected %s, but got %s", name, actual)
+		}
+	}
+}
+
+func TestNewLeveledLoggerFromEnv(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "warning")
+	t.Setenv("LOG_LEVELS", "discord=debug")
+
+	_, levels := NewLeveledLoggerFromEnv()
+	if levels.Level() != zapcore.WarnLevel {
+		t.Errorf("Expected level to be warning, but got %v", levels.Level())
+	}
+	if levels.LevelOf("discord") != zapcore.DebugLevel {
+		t.Errorf("Expected discord to be overridden to debug, but got %v", levels.LevelOf("discord"))
+	}
+}
+
+func TestParseLevelOverrides(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		s       string
+		want    map[string]zapcore.Level
+		wantErr bool
+	}{
+		{"empty", "", map[string]zapcore.Level{}, false},
+		{"pairs", "discord=debug, handler.citation=WARNING,", map[string]zapcore.Level{"discord": zapcore.DebugLevel, "handler.citation": zapcore.WarnLevel}, false},
+		{"missing level", "discord", nil, true},
+		{"missing name", "=debug", nil, true},
+		{"unknown level", "discord=verbose", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			actual, err := ParseLevelOverrides(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error to be %v, but got %v", tt.wantErr, err)
+			}
+			if len(actual) != len(tt.want) {
+				t.Fatalf("Expected %v, but got %v", tt.want, actual)
+			}
+			for name, level := range tt.want {
+				if actual[name] != level {
+					t.Errorf("Expected %s to be %v, but got %v", name, level, actual[name])
+				}
+			}
+		})
+	}
+}
+
+func TestLevels(t *testing.T) {
+	t.Parallel()
+
+	levels := NewLevels(zapcore.InfoLevel)
+	levels.SetOverride("handler", zapcore.ErrorLevel)
+	levels.SetOverride("handler.citation", zapcore.DebugLevel)
+
+	tests := []struct {
+		name string
+		want zapcore.Level
+	}{
+		{"", zapcore.InfoLevel},
+		{"discord", zapcore.InfoLevel},
+		{"handler", zapcore.ErrorLevel},
+		{"handler.invalidation", zapcore.ErrorLevel},
+		{"handler.citation", zapcore.DebugLevel},
+		{"handler.citation.reply", zapcore.DebugLevel},
+		{"handlers", zapcore.InfoLevel},
+	}
+	for _, tt := range tests {
+		if actual := levels.LevelOf(tt.name); actual != tt.want {
+			t.Errorf("Expected level of %q to be %v, but got %v", tt.name, tt.want, actual)
+		}
+	}
+	if levels.minimum() != zapcore.DebugLevel {
+		t.Errorf("Expected minimum to be debug, but got %v", levels.minimum())
+	}
+
+	levels.RemoveOverride("handler.citation")
+	if actual := levels.LevelOf("handler.citation"); actual != zapcore.ErrorLevel {
+		t.Errorf("Expected handler.citation to follow handler after the override was removed, but got %v", actual)
+	}
+
+	levels.SetOverrides(nil)
+	levels.SetLevel(zapcore.WarnLevel)
+	if actual := levels.LevelOf("handler"); actual != zapcore.WarnLevel {
+		t.Errorf("Expected handler to follow the level after the overrides were replaced, but got %v", actual)
+	}
+	if len(levels.Overrides()) != 0 {
+		t.Errorf("Expected no overrides, but got %v", levels.Overrides())
+	}
+}
+
+func TestNamedLoggerLevels(t *testing.T) {
+	t.Parallel()
+
+	observed, logs := observer.New(zapcore.DebugLevel)
+	levels := NewLevels(zapcore.InfoLevel)
+	levels.SetOverride("discord", zapcore.DebugLevel)
+	levels.SetOverride("cache", zapcore.ErrorLevel)
+	logger := zap.New(&levelCore{Core: observed, levels: levels})
+
+	ctx := WithLogger(context.Background(), logger)
+	Named(ctx, "discord").Debug("discord debug")
+	Named(ctx, "cache").Warn("cache warning")
+	Named(ctx, "handler").Named("citation").Debug("citation debug")
+	Named(ctx, "handler").Named("citation").Info("citation info")
+
+	// the level can be changed while the loggers are in use.
+	levels.SetOverride("cache", zapcore.DebugLevel)
+	Named(ctx, "cache").Debug("cache debug")
+
+	// wrapping cores which skip Check must not bypass the levels.
+	RedactSecrets(Named(ctx, "cache"), "secret").With(zap.String("k", "v")).Debug("redacted")
+	levels.RemoveOverride("cache")
+	RedactSecrets(Named(ctx, "cache"), "secret").Debug("dropped")
+
+	want := []string{"discord debug", "citation info", "cache debug", "redacted"}
+	entries := logs.All()
+	if len(entries) != len(want) {
+		t.Fatalf("Expected %d entries, but got %d: %v", len(want), len(entries), entries)
+	}
+	for i, entry := range entries {
+		if entry.Message != want[i] {
+			t.Errorf("Expected entry %d to be %q, but got %q", i, want[i], entry.Message)
+		}
+	}
+	if entries[1].LoggerName != "handler.citation" {
+		t.Errorf("Expected logger name to be handler.citation, but got %q", entries[1].LoggerName)
+	}
+}
+
+func TestNewLeveledLoggerOptions(t *testing.T) {
+	t.Parallel()
+
+	t.Run("entries are written to every output", func(t *testing.T) {
+		t.Parallel()
+
+		var a, b bytes.Buffer
+		logger, _ := NewLeveledLogger(false, "info", WithOutputs(zapcore.AddSync(&a), zapcore.AddSync(&b)))
+		logger.Info("hello")
+
+		for _, buf := range []*bytes.Buffer{&a, &b} {
+			if !strings.Contains(buf.String(), `"message":"hello"`) {
+				t.Errorf("Expected the entry to be written, but got %q", buf.String())
+			}
+		}
+	})
+
+	t.Run("repeated entries are sampled", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		logger, _ := NewLeveledLogger(false, "info", WithOutputs(zapcore.AddSync(&buf)), WithSampling(2, 3))
+		logger = RedactSecrets(logger, "secret")
+		for range 8 {
+			logger.Info("noisy")
+		}
+		logger.Info("other")
+
+		// the first 2 entries, then every third one: 1, 2, 5 and 8.
+		if actual := strings.Count(buf.String(), `"noisy"`); actual != 4 {
+			t.Errorf("Expected 4 sampled entries, but got %d", actual)
+		}
+		if !strings.Contains(buf.String(), `"other"`) {
+			t.Errorf("Expected other messages not to be sampled")
+		}
+	})
+
+	t.Run("levels are shared with the replaced logger", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		_, levels := NewLeveledLogger(false, "error")
+		logger, shared := NewLeveledLogger(false, "debug", WithLevels(levels), WithOutputs(zapcore.AddSync(&buf)))
+		if shared != levels {
+			t.Fatalf("Expected the levels to be shared")
+		}
+		logger.Info("dropped")
+		levels.SetLevel(zapcore.InfoLevel)
+		logger.Info("logged")
+
+		if strings.Contains(buf.String(), "dropped") || !strings.Contains(buf.String(), "logged") {
+			t.Errorf("Expected only the entry after the level was changed, but got %q", buf.String())
+		}
+	})
+}
@@ -0,0 +1,215 @@
+package logging
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// FieldAction is what is done with a field containing personal data.
+type FieldAction string
+
+const (
+	// Keep logs the field as it is.
+	Keep FieldAction = "keep"
+
+	// Hash replaces the field with a keyed hash, so that the logs of the same user can be correlated
+	// without logging who the user is.
+	Hash FieldAction = "hash"
+
+	// Drop removes the field.
+	Drop FieldAction = "drop"
+)
+
+// hashLength is the number of hex digits of the hashes in the logs.
+const hashLength = 16
+
+// PrivacyPolicy is the actions on the fields, keyed by their path, e.g. author.id.
+// Fields in objects such as zap.Dict have a dotted path, and a key matches the fields whose path ends with it,
+// so author.id matches message.author.id. The longest matching key wins. Fields without a matching key are kept.
+type PrivacyPolicy map[string]FieldAction
+
+// DefaultPrivacyPolicy pseudonymizes the users and drops their names and the message contents.
+// The guild, channel and message IDs are kept for debugging.
+func DefaultPrivacyPolicy() PrivacyPolicy {
+	return PrivacyPolicy{
+		"user_id":   Hash,
+		"author.id": Hash,
+		"username":  Drop,
+		"content":   Drop,
+	}
+}
+
+// ParsePrivacyPolicy parses the actions given as comma separated path=action pairs,
+// e.g. author.id=drop,content=keep. An empty string has no actions.
+func ParsePrivacyPolicy(s string) (PrivacyPolicy, error) {
+	policy := make(PrivacyPolicy)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		path, value, ok := strings.Cut(pair, "=")
+		path, value = strings.TrimSpace(path), strings.ToLower(strings.TrimSpace(value))
+		if !ok || path == "" {
+			return nil, fmt.Errorf("action must be path=action but is %q", pair)
+		}
+		switch action := FieldAction(value); action {
+		case Keep, Hash, Drop:
+			policy[path] = action
+		default:
+			return nil, fmt.Errorf("action of %s must be keep, hash or drop but is %q", path, value)
+		}
+	}
+	return policy, nil
+}
+
+// Merge returns a copy of the policy with the actions of other added, replacing the ones with the same path.
+func (p PrivacyPolicy) Merge(other PrivacyPolicy) PrivacyPolicy {
+	merged := maps.Clone(p)
+	if merged == nil {
+		merged = make(PrivacyPolicy, len(other))
+	}
+	maps.Copy(merged, other)
+	return merged
+}
+
+// actionOf returns the action of the field at the path.
+func (p PrivacyPolicy) actionOf(path string) FieldAction {
+	action, matched := Keep, -1
+	for key, a := range p {
+		if (path == key || strings.HasSuffix(path, "."+key)) && len(key) > matched {
+			action, matched = a, len(key)
+		}
+	}
+	return action
+}
+
+// ProtectPrivacy returns a logger which hashes or drops the fields with personal data according to the policy.
+// The hashes are keyed with salt, which should be kept secret because user IDs are easy to enumerate.
+func ProtectPrivacy(logger *zap.Logger, policy PrivacyPolicy, salt string) *zap.Logger {
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &privacyCore{Core: core, policy: maps.Clone(policy), salt: []byte(salt)}
+	}))
+}
+
+// privacyCore applies the privacy policy before the entries are encoded.
+type privacyCore struct {
+	zapcore.Core
+	policy PrivacyPolicy
+	salt   []byte
+}
+
+func (c *privacyCore) With(fields []zapcore.Field) zapcore.Core {
+	return &privacyCore{Core: c.Core.With(c.protectFields(fields)), policy: c.policy, salt: c.salt}
+}
+
+// Check asks the wrapped core whether it logs the entry, so that its levels and sampling apply,
+// and adds itself instead so that the fields are protected before they are written.
+func (c *privacyCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(entry.Level) {
+		return checked
+	}
+	if c.Core.Check(entry, nil) != nil {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *privacyCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, c.protectFields(fields))
+}
+
+// protectFields returns the fields with the policy applied. fields is copied only when a field is changed.
+func (c *privacyCore) protectFields(fields []zapcore.Field) []zapcore.Field {
+	var protected []zapcore.Field
+	for i, field := range fields {
+		replaced, changed := c.protectField(field)
+		if !changed {
+			if protected != nil {
+				protected = append(protected, field)
+			}
+			continue
+		}
+		if protected == nil {
+			protected = append(make([]zapcore.Field, 0, len(fields)), fields[:i]...)
+		}
+		if replaced != nil {
+			protected = append(protected, *replaced)
+		}
+	}
+	if protected == nil {
+		return fields
+	}
+	return protected
+}
+
+// protectField returns the field with the policy applied and whether it was changed.
+// A changed field of nil is dropped.
+func (c *privacyCore) protectField(field zapcore.Field) (*zapcore.Field, bool) {
+	switch c.policy.actionOf(field.Key) {
+	case Drop:
+		return nil, true
+	case Hash:
+		hashed := zap.String(field.Key, c.hash(fieldValue(field)))
+		return &hashed, true
+	}
+
+	marshaler, ok := field.Interface.(zapcore.ObjectMarshaler)
+	if field.Type != zapcore.ObjectMarshalerType || !ok {
+		return nil, false
+	}
+	enc := zapcore.NewMapObjectEncoder()
+	if err := marshaler.MarshalLogObject(enc); err != nil {
+		return nil, false
+	}
+	if !c.protectMap(field.Key, enc.Fields) {
+		return nil, false
+	}
+	replaced := zap.Any(field.Key, enc.Fields)
+	return &replaced, true
+}
+
+// protectMap applies the policy to the encoded object at the path in place and reports whether it was changed.
+func (c *privacyCore) protectMap(path string, object map[string]any) bool {
+	changed := false
+	for key, value := range object {
+		child := path + "." + key
+		switch c.policy.actionOf(child) {
+		case Drop:
+			delete(object, key)
+			changed = true
+		case Hash:
+			object[key] = c.hash(fmt.Sprint(value))
+			changed = true
+		default:
+			if nested, ok := value.(map[string]any); ok && c.protectMap(child, nested) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// hash returns the keyed hash of the value.
+func (c *privacyCore) hash(value string) string {
+	mac := hmac.New(sha256.New, c.salt)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))[:hashLength]
+}
+
+// fieldValue returns the value of the field as a string to be hashed.
+func fieldValue(field zapcore.Field) string {
+	enc := zapcore.NewMapObjectEncoder()
+	field.AddTo(enc)
+	if s, ok := enc.Fields[field.Key].(string); ok {
+		return s
+	}
+	return fmt.Sprint(enc.Fields[field.Key])
+}
@@ -0,0 +1,122 @@
+package logging
+
+import (
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestProtectPrivacy(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := ProtectPrivacy(zap.New(core), DefaultPrivacyPolicy(), "salt")
+
+	logger.With(zap.String("user_id", "42")).Info("handler called",
+		zap.String("guild_id", "1"),
+		zap.Dict("message",
+			zap.String("channel_id", "2"),
+			zap.String("content", "hello"),
+			zap.Dict("author",
+				zap.String("id", "42"),
+				zap.String("username", "alice"))))
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry but received %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+
+	hashed, ok := fields["user_id"].(string)
+	if !ok || hashed == "42" || len(hashed) != hashLength {
+		t.Errorf("expected user_id to be hashed but received %v", fields["user_id"])
+	}
+	if fields["guild_id"] != "1" {
+		t.Errorf("expected guild_id to be kept but received %v", fields["guild_id"])
+	}
+	message, ok := fields["message"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected message to be an object but received %T", fields["message"])
+	}
+	if message["channel_id"] != "2" {
+		t.Errorf("expected message.channel_id to be kept but received %v", message["channel_id"])
+	}
+	if _, ok := message["content"]; ok {
+		t.Error("expected message.content to be dropped")
+	}
+	author := message["author"].(map[string]any)
+	if author["id"] != hashed {
+		t.Errorf("expected message.author.id to have the same hash as user_id (%s) but received %v", hashed, author["id"])
+	}
+	if _, ok := author["username"]; ok {
+		t.Error("expected message.author.username to be dropped")
+	}
+}
+
+func TestProtectPrivacySalt(t *testing.T) {
+	t.Parallel()
+
+	hash := func(salt string) any {
+		core, logs := observer.New(zapcore.DebugLevel)
+		ProtectPrivacy(zap.New(core), DefaultPrivacyPolicy(), salt).Info("test", zap.String("user_id", "42"))
+		return logs.All()[0].ContextMap()["user_id"]
+	}
+	if hash("a") != hash("a") {
+		t.Error("expected the hash to be stable")
+	}
+	if hash("a") == hash("b") {
+		t.Error("expected the hash to depend on the salt")
+	}
+}
+
+func TestProtectPrivacyPolicyOverride(t *testing.T) {
+	t.Parallel()
+
+	overrides, err := ParsePrivacyPolicy("content=keep, message.author.id = DROP")
+	if err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := ProtectPrivacy(zap.New(core), DefaultPrivacyPolicy().Merge(overrides), "")
+
+	logger.Info("test", zap.String("content", "hello"), zap.Dict("message", zap.Dict("author", zap.String("id", "42"))))
+
+	fields := logs.All()[0].ContextMap()
+	if fields["content"] != "hello" {
+		t.Errorf("expected content to be kept but received %v", fields["content"])
+	}
+	author := fields["message"].(map[string]any)["author"].(map[string]any)
+	if _, ok := author["id"]; ok {
+		t.Error("expected the longer key to drop message.author.id")
+	}
+}
+
+func TestParsePrivacyPolicy(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name string
+		s    string
+		err  string
+	}{
+		{name: "empty", s: ""},
+		{name: "valid", s: "author.id=hash,username=keep"},
+		{name: "missing action", s: "author.id", err: "path=action"},
+		{name: "unknown action", s: "author.id=encrypt", err: "keep, hash or drop"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ParsePrivacyPolicy(tt.s)
+			if tt.err == "" && err != nil {
+				t.Errorf("expected err to be nil but received %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("expected an error containing %q but received %v", tt.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package logging
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aqyuki/felm/pkg/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// Profile is the format of the logs, chosen for the platform collecting them.
+type Profile string
+
+const (
+	// ProfileGCP is JSON for Google Cloud Logging. Trace IDs are linked with logging.googleapis.com/trace.
+	ProfileGCP Profile = "gcp"
+
+	// ProfileECS is JSON in the Elastic Common Schema. Trace IDs are written as trace.id.
+	ProfileECS Profile = "ecs"
+
+	// ProfileLogfmt is key=value pairs, e.g. for Loki or Heroku.
+	ProfileLogfmt Profile = "logfmt"
+
+	// ProfileConsole is the human readable format used when LOG_MODE is develop.
+	ProfileConsole Profile = "console"
+)
+
+// TraceIDKey is the key of the trace ID in the logs, which each profile renames to its correlation field.
+const TraceIDKey = "trace_id"
+
+// ecsVersion is the version of the Elastic Common Schema of ProfileECS.
+const ecsVersion = "8.11.0"
+
+// gcpTraceKey is the field linking the log to a trace in Google Cloud Logging.
+const gcpTraceKey = "logging.googleapis.com/trace"
+
+// ParseProfile parses the name of a profile, e.g. gcp or ECS.
+func ParseProfile(s string) (Profile, bool) {
+	switch profile := Profile(strings.ToLower(strings.TrimSpace(s))); profile {
+	case ProfileGCP, ProfileECS, ProfileLogfmt, ProfileConsole:
+		return profile, true
+	default:
+		return "", false
+	}
+}
+
+// Trace returns the field of the trace ID of the context.
+func Trace(ctx context.Context) zap.Field {
+	return zap.String(TraceIDKey, trace.AcquireTraceID(ctx))
+}
+
+// newEncoder returns the encoder of the profile. project is the Google Cloud project the GCP traces belong to.
+func newEncoder(profile Profile, project string) zapcore.Encoder {
+	switch profile {
+	case ProfileECS:
+		return &traceEncoder{Encoder: zapcore.NewJSONEncoder(ecsEncoderConfig), key: "trace.id"}
+	case ProfileLogfmt:
+		return newLogfmtEncoder()
+	case ProfileConsole:
+		return zapcore.NewConsoleEncoder(developmentEncoderConfig)
+	default:
+		encoder := &traceEncoder{Encoder: zapcore.NewJSONEncoder(productionEncoderConfig), key: gcpTraceKey}
+		if project != "" {
+			encoder.format = func(id string) string { return "projects/" + project + "/traces/" + id }
+		}
+		return encoder
+	}
+}
+
+// profileFields returns the fields the profile adds to every log.
+func profileFields(profile Profile) []zap.Field {
+	if profile == ProfileECS {
+		return []zap.Field{zap.String("ecs.version", ecsVersion)}
+	}
+	return nil
+}
+
+var ecsEncoderConfig = zapcore.EncoderConfig{
+	TimeKey:        "@timestamp",
+	LevelKey:       "log.level",
+	NameKey:        "log.logger",
+	CallerKey:      "log.origin.file.name",
+	MessageKey:     message,
+	StacktraceKey:  "error.stack_trace",
+	LineEnding:     zapcore.DefaultLineEnding,
+	EncodeLevel:    zapcore.LowercaseLevelEncoder,
+	EncodeTime:     zapcore.ISO8601TimeEncoder,
+	EncodeDuration: zapcore.NanosDurationEncoder,
+	EncodeCaller:   zapcore.ShortCallerEncoder,
+}
+
+// traceEncoder renames the trace ID to the correlation field of the profile.
+type traceEncoder struct {
+	zapcore.Encoder
+	key string
+
+	// format formats the trace ID for the correlation field. nil keeps it as it is.
+	format func(string) string
+}
+
+func (e *traceEncoder) Clone() zapcore.Encoder {
+	return &traceEncoder{Encoder: e.Encoder.Clone(), key: e.key, format: e.format}
+}
+
+// AddString renames the trace IDs added with With.
+func (e *traceEncoder) AddString(key, value string) {
+	if key == TraceIDKey && value != "" {
+		key, value = e.key, e.traceID(value)
+	}
+	e.Encoder.AddString(key, value)
+}
+
+func (e *traceEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	for i, field := range fields {
+		if field.Key != TraceIDKey || field.Type != zapcore.StringType || field.String == "" {
+			continue
+		}
+		renamed := make([]zapcore.Field, len(fields))
+		copy(renamed, fields)
+		renamed[i] = zap.String(e.key, e.traceID(field.String))
+		fields = renamed
+		break
+	}
+	return e.Encoder.EncodeEntry(entry, fields)
+}
+
+func (e *traceEncoder) traceID(id string) string {
+	if e.format == nil {
+		return id
+	}
+	return e.format(id)
+}
@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestParseProfile(t *testing.T) {
+	t.Parallel()
+
+	for input, want := range map[string]Profile{"gcp": ProfileGCP, " ECS ": ProfileECS, "logfmt": ProfileLogfmt, "console": ProfileConsole} {
+		if actual, ok := ParseProfile(input); !ok || actual != want {
+			t.Errorf("expected %q to be parsed as %s but received %q", input, want, actual)
+		}
+	}
+	if _, ok := ParseProfile("xml"); ok {
+		t.Error("expected an unknown profile not to be parsed")
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	t.Parallel()
+
+	decode := func(t *testing.T, buf *bytes.Buffer) map[string]any {
+		t.Helper()
+
+		var entry map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("expected a JSON entry but received %q: %v", buf.String(), err)
+		}
+		return entry
+	}
+
+	t.Run("gcp links the trace in the project", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		logger, _ := NewLeveledLogger(false, "info", WithOutputs(zapcore.AddSync(&buf)), WithProfile(ProfileGCP), WithTraceProject("felm-prod"))
+		logger.Info("hello", zap.String(TraceIDKey, "abc"))
+
+		entry := decode(t, &buf)
+		if actual := entry[gcpTraceKey]; actual != "projects/felm-prod/traces/abc" {
+			t.Errorf("expected the trace to be linked but received %v", actual)
+		}
+		if _, ok := entry[TraceIDKey]; ok {
+			t.Error("expected trace_id to be renamed")
+		}
+		if entry["severity"] != "INFO" {
+			t.Errorf("expected the severity of Cloud Logging but received %v", entry["severity"])
+		}
+	})
+
+	t.Run("ecs writes the trace id added with With", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		logger, _ := NewLeveledLogger(false, "info", WithOutputs(zapcore.AddSync(&buf)), WithProfile(ProfileECS))
+		logger.With(zap.String(TraceIDKey, "abc")).Warn("hello")
+
+		entry := decode(t, &buf)
+		for key, want := range map[string]any{"trace.id": "abc", "log.level": "warn", "message": "hello", "ecs.version": ecsVersion} {
+			if entry[key] != want {
+				t.Errorf("expected %s to be %v but received %v", key, want, entry[key])
+			}
+		}
+		if _, ok := entry["@timestamp"]; !ok {
+			t.Error("expected @timestamp to be written")
+		}
+	})
+
+	t.Run("logfmt flattens and quotes the fields", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		logger, _ := NewLeveledLogger(false, "info", WithOutputs(zapcore.AddSync(&buf)), WithProfile(ProfileLogfmt))
+		logger.Named("discord").With(zap.String(TraceIDKey, "abc")).Info("shard connected",
+			zap.Dict("shard", zap.Int("id", 1)),
+			zap.Error(errors.New("not found")))
+
+		line := buf.String()
+		for _, want := range []string{" level=info ", " logger=discord ", ` msg="shard connected" `, ` error="not found" `, " shard.id=1 ", " trace_id=abc"} {
+			if !strings.Contains(line, want) {
+				t.Errorf("expected %q in %q", want, line)
+			}
+		}
+		if !strings.HasPrefix(line, "time=") || !strings.HasSuffix(line, "\n") {
+			t.Errorf("expected a line starting with the time but received %q", line)
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package logging
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redacted replaces the secrets in the logs.
+const Redacted = "REDACTED"
+
+// RedactSecrets returns a logger which replaces the secrets in the messages and the fields with REDACTED.
+// Errors are checked with their verbose form, so that secrets in the context of oops errors are not logged either.
+// Empty secrets are ignored.
+func RedactSecrets(logger *zap.Logger, secrets ...string) *zap.Logger {
+	secrets = slices.DeleteFunc(slices.Clone(secrets), func(s string) bool { return s == "" })
+	if len(secrets) == 0 {
+		return logger
+	}
+
+	pairs := make([]string, 0, len(secrets)*2)
+	for _, secret := range secrets {
+		pairs = append(pairs, secret, Redacted)
+	}
+	replacer := strings.NewReplacer(pairs...)
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &redactingCore{Core: core, secrets: secrets, replacer: replacer}
+	}))
+}
+
+// redactingCore replaces the secrets before the entries are encoded.
+type redactingCore struct {
+	zapcore.Core
+	secrets  []string
+	replacer *strings.Replacer
+}
+
+func (c *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{Core: c.Core.With(c.redactFields(fields)), secrets: c.secrets, replacer: c.replacer}
+}
+
+// Check asks the wrapped core whether it logs the entry, so that its levels and sampling apply,
+// and adds itself instead so that the entry is redacted before it is written.
+func (c *redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(entry.Level) {
+		return checked
+	}
+	if c.Core.Check(entry, nil) != nil {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = c.replacer.Replace(entry.Message)
+	entry.Stack = c.replacer.Replace(entry.Stack)
+	return c.Core.Write(entry, c.redactFields(fields))
+}
+
+// contains reports whether s contains any secret.
+func (c *redactingCore) contains(s string) bool {
+	return slices.ContainsFunc(c.secrets, func(secret string) bool { return strings.Contains(s, secret) })
+}
+
+// redactFields returns the fields with the secrets replaced. fields is copied only when a secret is found.
+func (c *redactingCore) redactFields(fields []zapcore.Field) []zapcore.Field {
+	redacted := fields
+	for i, field := range fields {
+		replaced, ok := c.redactField(field)
+		if !ok {
+			continue
+		}
+		if &redacted[0] == &fields[0] {
+			redacted = slices.Clone(fields)
+		}
+		redacted[i] = replaced
+	}
+	return redacted
+}
+
+// redactField returns the field as a string with the secrets replaced when it contains any secret.
+func (c *redactingCore) redactField(field zapcore.Field) (zapcore.Field, bool) {
+	switch field.Type {
+	case zapcore.StringType:
+		if c.contains(field.String) {
+			return zap.String(field.Key, c.replacer.Replace(field.String)), true
+		}
+	case zapcore.ByteStringType, zapcore.BinaryType:
+		if s := string(field.Interface.([]byte)); c.contains(s) {
+			return zap.String(field.Key, c.replacer.Replace(s)), true
+		}
+	case zapcore.ErrorType:
+		err, ok := field.Interface.(error)
+		if ok && err != nil && c.contains(fmt.Sprintf("%+v", err)) {
+			return zap.String(field.Key, c.replacer.Replace(err.Error())), true
+		}
+	case zapcore.StringerType, zapcore.ReflectType:
+		if s := fmt.Sprintf("%+v", field.Interface); c.contains(s) {
+			return zap.String(field.Key, c.replacer.Replace(s)), true
+		}
+	case zapcore.ObjectMarshalerType:
+		enc := zapcore.NewMapObjectEncoder()
+		if marshaler, ok := field.Interface.(zapcore.ObjectMarshaler); ok && marshaler.MarshalLogObject(enc) == nil {
+			if s := fmt.Sprintf("%+v", enc.Fields); c.contains(s) {
+				return zap.String(field.Key, c.replacer.Replace(s)), true
+			}
+		}
+	}
+	return field, false
+}
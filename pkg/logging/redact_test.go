@@ -0,0 +1,58 @@
+package logging
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/samber/oops"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRedactSecrets(t *testing.T) {
+	t.Parallel()
+
+	const token = "very-secret-token"
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := RedactSecrets(zap.New(core), token, "")
+
+	logger.With(zap.String("header", "Bot "+token)).Info("token is "+token,
+		zap.Error(errors.New("invalid token: "+token)),
+		zap.Error(oops.With("token", token).Errorf("request failed")),
+		zap.ByteString("body", []byte(token)),
+		zap.Any("config", struct{ Token string }{token}),
+		zap.String("guild_id", "123"))
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry but received %d", len(entries))
+	}
+	entry := entries[0]
+	if strings.Contains(entry.Message, token) {
+		t.Errorf("expected the message to be redacted but received %s", entry.Message)
+	}
+	for _, field := range entry.Context {
+		enc := zapcore.NewMapObjectEncoder()
+		field.AddTo(enc)
+		for key, value := range enc.Fields {
+			if s, ok := value.(string); ok && strings.Contains(s, token) {
+				t.Errorf("expected %s to be redacted but received %s", key, s)
+			}
+		}
+	}
+	if guildID := entry.ContextMap()["guild_id"]; guildID != "123" {
+		t.Errorf("expected the other fields to be kept but received %v", guildID)
+	}
+}
+
+func TestRedactSecretsWithoutSecrets(t *testing.T) {
+	t.Parallel()
+
+	logger := zap.NewNop()
+	if actual := RedactSecrets(logger, ""); actual != logger {
+		t.Error("expected the logger to be returned as it is")
+	}
+}
@@ -0,0 +1,222 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxSize is the size of a log file at which it is rotated.
+	DefaultMaxSize = 100 * 1024 * 1024
+
+	// backupTimeFormat is the timestamp added to the names of rotated files. It sorts in chronological order.
+	backupTimeFormat = "20060102T150405.000"
+)
+
+// RotatingFile is a log file which is rotated when it exceeds the maximum size.
+// Rotated files are renamed with the time of the rotation, e.g. felm-20240102T150405.000.log,
+// and removed when they are older than the maximum age or exceed the maximum number of backups.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	now        func() time.Time
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+type RotateOption func(*RotatingFile)
+
+// WithMaxSize sets the size in bytes at which the file is rotated.
+func WithMaxSize(size int64) RotateOption {
+	return func(f *RotatingFile) {
+		if size > 0 {
+			f.maxSize = size
+		}
+	}
+}
+
+// WithMaxAge sets the age after which rotated files are removed. 0 keeps them regardless of their age.
+func WithMaxAge(age time.Duration) RotateOption {
+	return func(f *RotatingFile) {
+		if age >= 0 {
+			f.maxAge = age
+		}
+	}
+}
+
+// WithMaxBackups sets the number of rotated files to keep. 0 keeps every file.
+func WithMaxBackups(backups int) RotateOption {
+	return func(f *RotatingFile) {
+		if backups >= 0 {
+			f.maxBackups = backups
+		}
+	}
+}
+
+// withRotateClock sets the clock used to name the rotated files. It is used in tests.
+func withRotateClock(now func() time.Time) RotateOption {
+	return func(f *RotatingFile) {
+		f.now = now
+	}
+}
+
+// OpenRotatingFile opens the log file to append to it, creating the directory if needed.
+func OpenRotatingFile(path string, option ...RotateOption) (*RotatingFile, error) {
+	f := &RotatingFile{
+		path:    path,
+		maxSize: DefaultMaxSize,
+		now:     time.Now,
+	}
+	for _, opt := range option {
+		opt(f)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create the log directory: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the file at the path, keeping its current size.
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open the log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to stat the log file: %w", err)
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// Write writes p to the file, rotating it first when p does not fit in the maximum size.
+// An entry larger than the maximum size is written to an empty file rather than split.
+// When the rotation fails, p is still appended to the current file and the error is returned,
+// so that no entry is lost and the rotation is tried again by the next write.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	var rotateErr error
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		rotateErr = f.rotate()
+		if f.file == nil {
+			return 0, rotateErr
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, errors.Join(rotateErr, err)
+}
+
+// Sync flushes the file to the disk.
+func (f *RotatingFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Sync()
+}
+
+// Close closes the file. Writes after Close fail.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// rotate renames the current file, opens a new one and removes the expired backups.
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to close the log file: %w", err)
+	}
+	f.file = nil
+
+	if err := os.Rename(f.path, f.backupName(f.now())); err != nil {
+		// keep appending to the current file rather than failing every write after it.
+		return errors.Join(fmt.Errorf("failed to rotate the log file: %w", err), f.open())
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	return f.removeExpired()
+}
+
+// backupName returns the name of the file rotated at t.
+func (f *RotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(f.path)
+	return strings.TrimSuffix(f.path, ext) + "-" + t.UTC().Format(backupTimeFormat) + ext
+}
+
+// removeExpired removes the rotated files exceeding the maximum age or number of backups.
+func (f *RotatingFile) removeExpired() error {
+	if f.maxAge == 0 && f.maxBackups == 0 {
+		return nil
+	}
+
+	ext := filepath.Ext(f.path)
+	prefix := filepath.Base(strings.TrimSuffix(f.path, ext)) + "-"
+	entries, err := os.ReadDir(filepath.Dir(f.path))
+	if err != nil {
+		return fmt.Errorf("failed to list the rotated log files: %w", err)
+	}
+
+	type backup struct {
+		path string
+		time time.Time
+	}
+	backups := make([]backup, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		t, err := time.Parse(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext))
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: filepath.Join(filepath.Dir(f.path), name), time: t})
+	}
+	// newest first, so that the backups beyond the maximum number are the oldest ones.
+	slices.SortFunc(backups, func(a, b backup) int { return b.time.Compare(a.time) })
+
+	errs := make([]error, 0)
+	for i, b := range backups {
+		expired := f.maxAge > 0 && f.now().Sub(b.time) > f.maxAge
+		exceeded := f.maxBackups > 0 && i >= f.maxBackups
+		if expired || exceeded {
+			if err := os.Remove(b.path); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,154 @@
+package logging
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to list %s: %v", dir, err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestRotatingFile(t *testing.T) {
+	t.Parallel()
+
+	t.Run("file is rotated when it exceeds the maximum size", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+		f, err := OpenRotatingFile(filepath.Join(dir, "logs", "felm.log"), WithMaxSize(10), withRotateClock(func() time.Time { return now }))
+		if err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		defer f.Close()
+
+		for _, line := range []string{"12345\n", "67890\n", "abcde\n"} {
+			if _, err := f.Write([]byte(line)); err != nil {
+				t.Fatalf("expected err to be nil but received %v", err)
+			}
+			now = now.Add(time.Second)
+		}
+
+		want := []string{"felm-20240102T150406.000.log", "felm-20240102T150407.000.log", "felm.log"}
+		if actual := listDir(t, filepath.Join(dir, "logs")); !slices.Equal(actual, want) {
+			t.Fatalf("expected %v but received %v", want, actual)
+		}
+		content, _ := os.ReadFile(filepath.Join(dir, "logs", "felm.log"))
+		if string(content) != "abcde\n" {
+			t.Errorf("expected the current file to have the last line but received %q", content)
+		}
+	})
+
+	t.Run("existing file is appended", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "felm.log")
+		_ = os.WriteFile(path, []byte("12345678\n"), 0o644)
+		f, err := OpenRotatingFile(path, WithMaxSize(10))
+		if err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		defer f.Close()
+
+		// the size of the existing content counts, so this write rotates the file.
+		_, _ = f.Write([]byte("abc\n"))
+		if actual := listDir(t, filepath.Dir(path)); len(actual) != 2 {
+			t.Errorf("expected the existing file to be rotated but received %v", actual)
+		}
+	})
+
+	t.Run("old and excess backups are removed", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+		for _, name := range []string{
+			"felm-20240101T000000.000.log", // older than the maximum age
+			"felm-20240108T000000.000.log", // exceeds the maximum number of backups
+			"felm-20240109T000000.000.log",
+			"other-20240101T000000.000.log", // not a backup of the file
+		} {
+			_ = os.WriteFile(filepath.Join(dir, name), nil, 0o644)
+		}
+
+		f, err := OpenRotatingFile(filepath.Join(dir, "felm.log"),
+			WithMaxSize(1), WithMaxAge(72*time.Hour), WithMaxBackups(2), withRotateClock(func() time.Time { return now }))
+		if err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		defer f.Close()
+		_, _ = f.Write([]byte("a"))
+		_, _ = f.Write([]byte("b"))
+
+		want := []string{"felm-20240109T000000.000.log", "felm-20240110T000000.000.log", "felm.log", "other-20240101T000000.000.log"}
+		if actual := listDir(t, dir); !slices.Equal(actual, want) {
+			t.Errorf("expected %v but received %v", want, actual)
+		}
+	})
+
+	t.Run("file is reopened when the rotation fails", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+		f, err := OpenRotatingFile(filepath.Join(dir, "felm.log"), WithMaxSize(10), withRotateClock(func() time.Time { return now }))
+		if err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		defer f.Close()
+
+		// a directory which is not empty can not be replaced by the file, so the rename fails.
+		backup := filepath.Join(dir, "felm-20240102T150405.000.log")
+		if err := os.MkdirAll(filepath.Join(backup, "blocker"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := f.Write([]byte("12345\n")); err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		if n, err := f.Write([]byte("67890\n")); err == nil || n != 6 {
+			t.Errorf("expected the line to be written with the error of the rotation but received (%d, %v)", n, err)
+		}
+
+		_ = os.RemoveAll(backup)
+		if _, err := f.Write([]byte("abcde\n")); err != nil {
+			t.Fatalf("expected the rotation to succeed again but received %v", err)
+		}
+		content, _ := os.ReadFile(backup)
+		if string(content) != "12345\n67890\n" {
+			t.Errorf("expected the lines written while the rotation failed to be kept but received %q", content)
+		}
+	})
+
+	t.Run("writes fail after close", func(t *testing.T) {
+		t.Parallel()
+
+		f, err := OpenRotatingFile(filepath.Join(t.TempDir(), "felm.log"))
+		if err != nil {
+			t.Fatalf("expected err to be nil but received %v", err)
+		}
+		_ = f.Close()
+		if _, err := f.Write([]byte("a")); !errors.Is(err, os.ErrClosed) {
+			t.Errorf("expected os.ErrClosed but received %v", err)
+		}
+		if err := f.Sync(); err != nil {
+			t.Errorf("expected sync after close to be a no-op but received %v", err)
+		}
+	})
+}
@@ -0,0 +1,177 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Scope identifies which bucket suppressed an event.
+type Scope string
+
+const (
+	ScopeNone    Scope = ""
+	ScopeUser    Scope = "user"
+	ScopeChannel Scope = "channel"
+	ScopeGuild   Scope = "guild"
+)
+
+// Limit describes a token bucket. The bucket holds at most Burst tokens and
+// regains one token every Interval. A zero Burst disables the limit.
+type Limit struct {
+	Burst    int
+	Interval time.Duration
+}
+
+// Enabled reports whether the limit restricts anything.
+func (l Limit) Enabled() bool {
+	return l.Burst > 0 && l.Interval > 0
+}
+
+// Config is a set of limits applied to a guild.
+type Config struct {
+	User    Limit
+	Channel Limit
+	Guild   Limit
+}
+
+// Key identifies the origin of an event.
+type Key struct {
+	GuildID   string
+	ChannelID string
+	UserID    string
+}
+
+// Decision is the result of Limiter.Allow.
+type Decision struct {
+	Allowed bool
+
+	// Scope is the bucket which rejected the event. It is ScopeNone when the event is allowed.
+	Scope Scope
+
+	// RetryAfter is the time until the rejecting bucket regains a token.
+	RetryAfter time.Duration
+}
+
+// Option is a function that configures a Limiter.
+type Option func(*Limiter)
+
+// WithClock replaces the clock used by the limiter.
+func WithClock(now func() time.Time) Option {
+	return func(l *Limiter) {
+		if now != nil {
+			l.now = now
+		}
+	}
+}
+
+// Limiter tracks token buckets per user, channel and guild.
+type Limiter struct {
+	mu      sync.Mutex
+	store   Store
+	buckets map[bucketKey]*bucket
+	now     func() time.Time
+
+	// lastSweep is the last time idle buckets were removed.
+	lastSweep time.Time
+}
+
+// sweepInterval is the interval between removals of idle buckets.
+const sweepInterval = 10 * time.Minute
+
+type bucketKey struct {
+	scope Scope
+	id    string
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+func New(store Store, option ...Option) *Limiter {
+	l := &Limiter{
+		store:   store,
+		buckets: make(map[bucketKey]*bucket),
+		now:     time.Now,
+	}
+	for _, opt := range option {
+		opt(l)
+	}
+	l.lastSweep = l.now()
+	return l
+}
+
+// Allow reports whether an event from the key may be processed.
+// A token is taken from every bucket only when all of them allow the event,
+// so a suppressed user does not drain the channel or guild bucket.
+func (l *Limiter) Allow(key Key) Decision {
+	cfg := l.store.GuildConfig(key.GuildID)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	candidates := []struct {
+		key   bucketKey
+		limit Limit
+	}{
+		{bucketKey{ScopeUser, key.GuildID + "/" + key.UserID}, cfg.User},
+		{bucketKey{ScopeChannel, key.ChannelID}, cfg.Channel},
+		{bucketKey{ScopeGuild, key.GuildID}, cfg.Guild},
+	}
+
+	buckets := make([]*bucket, 0, len(candidates))
+	for _, c := range candidates {
+		if !c.limit.Enabled() {
+			continue
+		}
+		b := l.bucket(c.key, c.limit, now)
+		if b.tokens < 1 {
+			return Decision{
+				Allowed:    false,
+				Scope:      c.key.scope,
+				RetryAfter: time.Duration((1 - b.tokens) * float64(c.limit.Interval)),
+			}
+		}
+		buckets = append(buckets, b)
+	}
+
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return Decision{Allowed: true, Scope: ScopeNone}
+}
+
+// bucket returns the refilled bucket for the key, creating it if necessary.
+func (l *Limiter) bucket(key bucketKey, limit Limit, now time.Time) *bucket {
+	b, ok := l.buckets[key]
+	if !ok || b.limit != limit {
+		b = &bucket{tokens: float64(limit.Burst), last: now, limit: limit}
+		l.buckets[key] = b
+		return b
+	}
+	elapsed := now.Sub(b.last)
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+float64(elapsed)/float64(limit.Interval))
+		b.last = now
+	}
+	return b
+}
+
+// sweep removes buckets which have been refilled completely, because they behave like new ones.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		refill := time.Duration((float64(b.limit.Burst) - b.tokens) * float64(b.limit.Interval))
+		if now.Sub(b.last) >= refill {
+			delete(l.buckets, key)
+		}
+	}
+}
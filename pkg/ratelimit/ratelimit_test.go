@@ -0,0 +1,190 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(0, 0)}
+}
+
+func TestLimit_Enabled(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		limit Limit
+		want  bool
+	}{
+		{"zero", Limit{}, false},
+		{"burst only", Limit{Burst: 1}, false},
+		{"interval only", Limit{Interval: time.Second}, false},
+		{"enabled", Limit{Burst: 1, Interval: time.Second}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if actual := tt.limit.Enabled(); actual != tt.want {
+				t.Errorf("expected %v but received %v", tt.want, actual)
+			}
+		})
+	}
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	t.Parallel()
+
+	t.Run("user bucket is exhausted", func(t *testing.T) {
+		t.Parallel()
+
+		clock := newFakeClock()
+		limiter := New(NewMemoryStore(Config{
+			User: Limit{Burst: 2, Interval: time.Minute},
+		}), WithClock(clock.Now))
+		key := Key{GuildID: "g", ChannelID: "c", UserID: "u"}
+
+		for i := 0; i < 2; i++ {
+			if d := limiter.Allow(key); !d.Allowed {
+				t.Fatalf("expected event %d to be allowed but received %+v", i, d)
+			}
+		}
+
+		d := limiter.Allow(key)
+		if d.Allowed {
+			t.Fatal("expected event to be suppressed but it was allowed")
+		}
+		if d.Scope != ScopeUser {
+			t.Errorf("expected scope to be %q but received %q", ScopeUser, d.Scope)
+		}
+		if d.RetryAfter != time.Minute {
+			t.Errorf("expected retry after to be %v but received %v", time.Minute, d.RetryAfter)
+		}
+
+		other := Key{GuildID: "g", ChannelID: "c", UserID: "v"}
+		if d := limiter.Allow(other); !d.Allowed {
+			t.Errorf("expected other user to be allowed but received %+v", d)
+		}
+	})
+
+	t.Run("bucket is refilled over time", func(t *testing.T) {
+		t.Parallel()
+
+		clock := newFakeClock()
+		limiter := New(NewMemoryStore(Config{
+			Channel: Limit{Burst: 1, Interval: time.Second},
+		}), WithClock(clock.Now))
+		key := Key{GuildID: "g", ChannelID: "c", UserID: "u"}
+
+		if d := limiter.Allow(key); !d.Allowed {
+			t.Fatalf("expected first event to be allowed but received %+v", d)
+		}
+		if d := limiter.Allow(key); d.Allowed || d.Scope != ScopeChannel {
+			t.Fatalf("expected second event to be suppressed by channel but received %+v", d)
+		}
+
+		clock.Advance(time.Second)
+		if d := limiter.Allow(key); !d.Allowed {
+			t.Errorf("expected event to be allowed after refill but received %+v", d)
+		}
+	})
+
+	t.Run("suppressed event does not consume other buckets", func(t *testing.T) {
+		t.Parallel()
+
+		clock := newFakeClock()
+		limiter := New(NewMemoryStore(Config{
+			User:  Limit{Burst: 1, Interval: time.Hour},
+			Guild: Limit{Burst: 2, Interval: time.Hour},
+		}), WithClock(clock.Now))
+		spammer := Key{GuildID: "g", ChannelID: "c", UserID: "spammer"}
+
+		for i := 0; i < 5; i++ {
+			limiter.Allow(spammer)
+		}
+
+		if d := limiter.Allow(Key{GuildID: "g", ChannelID: "c", UserID: "other"}); !d.Allowed {
+			t.Errorf("expected other user to be allowed but received %+v", d)
+		}
+	})
+
+	t.Run("guild override is applied", func(t *testing.T) {
+		t.Parallel()
+
+		store := NewMemoryStore(Config{})
+		store.SetGuildConfig("strict", Config{Guild: Limit{Burst: 1, Interval: time.Hour}})
+		limiter := New(store)
+
+		for i := 0; i < 3; i++ {
+			if d := limiter.Allow(Key{GuildID: "relaxed", ChannelID: "c", UserID: "u"}); !d.Allowed {
+				t.Fatalf("expected event in relaxed guild to be allowed but received %+v", d)
+			}
+		}
+
+		limiter.Allow(Key{GuildID: "strict", ChannelID: "c", UserID: "u"})
+		if d := limiter.Allow(Key{GuildID: "strict", ChannelID: "c", UserID: "u"}); d.Allowed || d.Scope != ScopeGuild {
+			t.Errorf("expected event in strict guild to be suppressed by guild but received %+v", d)
+		}
+	})
+
+	t.Run("idle buckets are swept", func(t *testing.T) {
+		t.Parallel()
+
+		clock := newFakeClock()
+		limiter := New(NewMemoryStore(Config{
+			User: Limit{Burst: 1, Interval: time.Second},
+		}), WithClock(clock.Now))
+
+		limiter.Allow(Key{GuildID: "g", ChannelID: "c", UserID: "u"})
+		clock.Advance(sweepInterval)
+		limiter.Allow(Key{GuildID: "g", ChannelID: "c", UserID: "v"})
+
+		if len(limiter.buckets) != 1 {
+			t.Errorf("expected 1 bucket to remain but received %d", len(limiter.buckets))
+		}
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	t.Parallel()
+
+	defaults := Config{User: Limit{Burst: 1, Interval: time.Second}}
+	override := Config{Guild: Limit{Burst: 5, Interval: time.Minute}}
+
+	store := NewMemoryStore(defaults)
+	store.SetGuildConfig("g", override)
+
+	if actual := store.GuildConfig("g"); actual != override {
+		t.Errorf("expected override %+v but received %+v", override, actual)
+	}
+	if actual := store.GuildConfig("other"); actual != defaults {
+		t.Errorf("expected defaults %+v but received %+v", defaults, actual)
+	}
+
+	store.DeleteGuildConfig("g")
+	if actual := store.GuildConfig("g"); actual != defaults {
+		t.Errorf("expected defaults after delete %+v but received %+v", defaults, actual)
+	}
+
+	store.SetGuildConfig("g", override)
+	store.SetGuildConfigs(map[string]Config{"other": override})
+	if actual := store.GuildConfig("other"); actual != override {
+		t.Errorf("expected override %+v but received %+v", override, actual)
+	}
+	if actual := store.GuildConfig("g"); actual != defaults {
+		t.Errorf("expected the replaced override to be removed but received %+v", actual)
+	}
+}
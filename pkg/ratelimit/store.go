@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"sync"
+)
+
+// Store provides the limits applied to each guild.
+type Store interface {
+	GuildConfig(guildID string) Config
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// MemoryStore is a Store which keeps per guild overrides in memory.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	defaults  Config
+	overrides map[string]Config
+}
+
+func NewMemoryStore(defaults Config) *MemoryStore {
+	return &MemoryStore{
+		defaults:  defaults,
+		overrides: make(map[string]Config),
+	}
+}
+
+// GuildConfig returns the limits for the guild, or the defaults if the guild has no override.
+func (s *MemoryStore) GuildConfig(guildID string) Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if cfg, ok := s.overrides[guildID]; ok {
+		return cfg
+	}
+	return s.defaults
+}
+
+// SetGuildConfig overrides the limits for the guild.
+func (s *MemoryStore) SetGuildConfig(guildID string, cfg Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.overrides[guildID] = cfg
+}
+
+// SetGuildConfigs replaces every override with overrides, so that the guilds which are not in it use the defaults.
+func (s *MemoryStore) SetGuildConfigs(overrides map[string]Config) {
+	copied := make(map[string]Config, len(overrides))
+	for guildID, cfg := range overrides {
+		copied[guildID] = cfg
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.overrides = copied
+}
+
+// DeleteGuildConfig removes the override for the guild.
+func (s *MemoryStore) DeleteGuildConfig(guildID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.overrides, guildID)
+}
+
+// SetDefaults replaces the limits applied to guilds without an override.
+func (s *MemoryStore) SetDefaults(cfg Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.defaults = cfg
+}
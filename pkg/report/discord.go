@@ -0,0 +1,127 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// embedColor is the color of the embeds of the reports.
+	embedColor = 0xe74c3c
+
+	// maxFieldLength is the length of the values of the embed fields, which is limited to 1024 by Discord.
+	maxFieldLength = 1000
+
+	// maxDescriptionLength is the length of the description of the embed, which is limited to 4096 by Discord.
+	maxDescriptionLength = 4000
+)
+
+// Webhook reports the events to a Discord webhook, e.g. of a channel only the operators can read.
+type Webhook struct {
+	client *http.Client
+	url    string
+}
+
+// NewWebhook creates the reporter posting the events to the URL of the webhook.
+func NewWebhook(url string, option ...HTTPOption) *Webhook {
+	return &Webhook{client: newHTTPClient(option...), url: url}
+}
+
+func (w *Webhook) Report(ctx context.Context, event Event) error {
+	body, err := json.Marshal(discordgo.WebhookParams{
+		Username: "felm",
+		Embeds:   []*discordgo.MessageEmbed{newEmbed(event)},
+		// the errors may contain the names of users or roles, which must not be pinged.
+		AllowedMentions: &discordgo.MessageAllowedMentions{Parse: []discordgo.AllowedMentionType{}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode the webhook message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create the webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return send(w.client, req, "the discord webhook")
+}
+
+// Channel reports the events to a channel with the bot, e.g. a channel only the operators can read.
+type Channel struct {
+	session   *discordgo.Session
+	channelID string
+}
+
+// NewChannel creates the reporter sending the events to the channel. session is only used to call the REST API.
+func NewChannel(session *discordgo.Session, channelID string) *Channel {
+	return &Channel{session: session, channelID: channelID}
+}
+
+func (c *Channel) Report(ctx context.Context, event Event) error {
+	_, err := c.session.ChannelMessageSendComplex(c.channelID, &discordgo.MessageSend{
+		Embeds:          []*discordgo.MessageEmbed{newEmbed(event)},
+		AllowedMentions: &discordgo.MessageAllowedMentions{Parse: []discordgo.AllowedMentionType{}},
+	}, discordgo.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to report the error to the channel (channel_id = %s): %w", c.channelID, err)
+	}
+	return nil
+}
+
+// newEmbed creates the embed showing the event to the operators.
+func newEmbed(event Event) *discordgo.MessageEmbed {
+	fields := []*discordgo.MessageEmbedField{
+		{Name: "Event", Value: event.Source, Inline: true},
+		{Name: "Trace ID", Value: orNone(event.TraceID), Inline: true},
+	}
+	if event.Code != "" {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Code", Value: event.Code, Inline: true})
+	}
+	if event.Suppressed > 0 {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Suppressed", Value: fmt.Sprintf("%d duplicates", event.Suppressed), Inline: true})
+	}
+	if len(event.Context) > 0 {
+		lines := make([]string, 0, len(event.Context))
+		for key, value := range event.Context {
+			lines = append(lines, fmt.Sprintf("%s = %v", key, value))
+		}
+		slices.Sort(lines)
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Context", Value: codeBlock(strings.Join(lines, "\n"), maxFieldLength)})
+	}
+	if len(event.Frames) > 0 {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Stacktrace", Value: codeBlock(event.Stacktrace(), maxFieldLength)})
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:       "Error occurred in handler",
+		Description: codeBlock(event.Message, maxDescriptionLength),
+		Color:       embedColor,
+		Fields:      fields,
+		Timestamp:   event.Time.UTC().Format(time.RFC3339),
+	}
+}
+
+// codeBlock returns s in a code block, truncated so that the block is at most n characters.
+func codeBlock(s string, n int) string {
+	const fence = "```"
+	s = strings.ReplaceAll(s, fence, "'''")
+	if limit := n - 2*len(fence) - 2; len([]rune(s)) > limit {
+		s = string([]rune(s)[:limit-1]) + "…"
+	}
+	return fence + "\n" + s + "\n" + fence
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,61 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/samber/oops"
+)
+
+func TestWebhookReport(t *testing.T) {
+	t.Parallel()
+
+	var params discordgo.WebhookParams
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&params)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	event := NewEvent("MessageCreate", "trace", oops.With("guild_id", "1").Errorf("failed"))
+	event.Suppressed = 3
+	if err := NewWebhook(server.URL).Report(context.Background(), event); err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+
+	if len(params.Embeds) != 1 {
+		t.Fatalf("expected 1 embed but received %d", len(params.Embeds))
+	}
+	embed := params.Embeds[0]
+	if !strings.Contains(embed.Description, "failed") {
+		t.Errorf("expected the message in the description but received %q", embed.Description)
+	}
+	values := make(map[string]string)
+	for _, field := range embed.Fields {
+		values[field.Name] = field.Value
+	}
+	if values["Trace ID"] != "trace" || !strings.Contains(values["Context"], "guild_id = 1") || values["Suppressed"] != "3 duplicates" {
+		t.Errorf("expected the attributes in the fields but received %v", values)
+	}
+	if params.AllowedMentions == nil || len(params.AllowedMentions.Parse) != 0 {
+		t.Error("expected the mentions to be disabled")
+	}
+}
+
+func TestCodeBlock(t *testing.T) {
+	t.Parallel()
+
+	block := codeBlock(strings.Repeat("あ", 100)+"```", 50)
+	if utf8.RuneCountInString(block) > 50 {
+		t.Errorf("expected at most 50 characters but received %d", utf8.RuneCountInString(block))
+	}
+	if strings.Count(block, "```") != 2 {
+		t.Errorf("expected the fences in the value to be replaced but received %q", block)
+	}
+}
@@ -0,0 +1,54 @@
+package report
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultHistorySize is the number of events kept by History.
+const DefaultHistorySize = 50
+
+// History is the reporter which keeps the latest events in memory, e.g. to show them to the operators.
+type History struct {
+	mu     sync.Mutex
+	events []Event
+	next   int
+	full   bool
+}
+
+// NewHistory creates the history of the latest size events. A size of 0 or less uses DefaultHistorySize.
+func NewHistory(size int) *History {
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+	return &History{events: make([]Event, size)}
+}
+
+// Report keeps the event, forgetting the oldest one when the history is full.
+func (h *History) Report(_ context.Context, event Event) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.events[h.next] = event
+	h.next = (h.next + 1) % len(h.events)
+	if h.next == 0 {
+		h.full = true
+	}
+	return nil
+}
+
+// Events returns the kept events, the latest first.
+func (h *History) Events() []Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n := h.next
+	if h.full {
+		n = len(h.events)
+	}
+	events := make([]Event, 0, n)
+	for i := range n {
+		events = append(events, h.events[(h.next-1-i+len(h.events))%len(h.events)])
+	}
+	return events
+}
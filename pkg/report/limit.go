@@ -0,0 +1,140 @@
+package report
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDedupeWindow is the duration during which the events with the same fingerprint are reported once.
+	DefaultDedupeWindow = 10 * time.Minute
+
+	// DefaultBurst and DefaultInterval allow 10 reports at once, and one more every 30 seconds.
+	DefaultBurst    = 10
+	DefaultInterval = 30 * time.Second
+)
+
+// LimitOption configures the reporter returned by Limit.
+type LimitOption func(*limiter)
+
+// WithDedupeWindow sets the duration during which the events with the same fingerprint are reported once.
+// 0 disables the de-duplication.
+func WithDedupeWindow(window time.Duration) LimitOption {
+	return func(l *limiter) {
+		if window >= 0 {
+			l.window = window
+		}
+	}
+}
+
+// WithRate sets the number of events which can be reported at once, and the interval to regain one.
+// A burst of 0 disables the rate limit.
+func WithRate(burst int, interval time.Duration) LimitOption {
+	return func(l *limiter) {
+		if burst >= 0 && interval > 0 {
+			l.burst = burst
+			l.interval = interval
+		}
+	}
+}
+
+// withLimitClock sets the clock of the limiter. It is used in tests.
+func withLimitClock(now func() time.Time) LimitOption {
+	return func(l *limiter) {
+		l.now = now
+	}
+}
+
+// limiter drops the duplicated events and the events exceeding the rate,
+// so that an outage does not flood the operators or exceed the quota of the error tracker.
+type limiter struct {
+	reporter Reporter
+	window   time.Duration
+	burst    int
+	interval time.Duration
+	now      func() time.Time
+
+	mu sync.Mutex
+
+	// seen is the fingerprints reported in the window, with the number of events suppressed since then.
+	seen map[string]*seenEvent
+
+	// tokens is the number of events which can be reported, as of updated.
+	tokens  float64
+	updated time.Time
+}
+
+type seenEvent struct {
+	reported   time.Time
+	suppressed int
+}
+
+// Limit returns the reporter which reports the events to reporter, except the duplicated ones and the ones exceeding the rate.
+// The number of suppressed duplicates is reported with the next event of the same fingerprint.
+func Limit(reporter Reporter, option ...LimitOption) Reporter {
+	l := &limiter{
+		reporter: reporter,
+		window:   DefaultDedupeWindow,
+		burst:    DefaultBurst,
+		interval: DefaultInterval,
+		now:      time.Now,
+		seen:     make(map[string]*seenEvent),
+	}
+	for _, opt := range option {
+		opt(l)
+	}
+	l.tokens = float64(l.burst)
+	return l
+}
+
+func (l *limiter) Report(ctx context.Context, event Event) error {
+	event, ok := l.allow(event)
+	if !ok {
+		return nil
+	}
+	return l.reporter.Report(ctx, event)
+}
+
+// allow reports whether the event is reported, and returns it with the number of suppressed duplicates.
+func (l *limiter) allow(event Event) (Event, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	seen := l.seen[event.Fingerprint]
+	if seen != nil && now.Sub(seen.reported) < l.window {
+		seen.suppressed++
+		return event, false
+	}
+
+	if l.burst > 0 {
+		l.tokens = min(l.tokens+now.Sub(l.updated).Seconds()/l.interval.Seconds(), float64(l.burst))
+		l.updated = now
+		if l.tokens < 1 {
+			return event, false
+		}
+		l.tokens--
+	}
+
+	if seen != nil {
+		event.Suppressed = seen.suppressed
+	}
+	if l.window > 0 {
+		l.seen[event.Fingerprint] = &seenEvent{reported: now}
+	}
+	return event, true
+}
+
+// sweep forgets the fingerprints whose window has passed. The ones with suppressed events are kept for another window,
+// so that their number is reported with the next event.
+func (l *limiter) sweep(now time.Time) {
+	for fingerprint, seen := range l.seen {
+		elapsed := now.Sub(seen.reported)
+		if (elapsed >= l.window && seen.suppressed == 0) || elapsed >= 2*l.window {
+			delete(l.seen, fingerprint)
+		}
+	}
+}
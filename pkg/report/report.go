@@ -0,0 +1,133 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/aqyuki/felm/pkg/logging"
+	"github.com/samber/oops"
+	"go.uber.org/zap"
+)
+
+// loggerName is the name of the logger of the reports, whose level can be overridden.
+const loggerName = "report"
+
+// Event is an error returned by a handler, with the attributes of oops errors.
+type Event struct {
+	// Time is when the error was reported.
+	Time time.Time
+
+	// Source is the name of the event whose handler returned the error, e.g. MessageCreate.
+	Source string
+
+	// TraceID is the trace ID of the event, or the one of the error when the event has none.
+	TraceID string
+
+	// Message is the message of the error.
+	Message string
+
+	// Code, Context and Frames are the attributes of oops errors. They are empty for other errors.
+	Code    string
+	Context map[string]any
+	Frames  []runtime.Frame
+
+	// Fingerprint groups the events of the same error, e.g. to de-duplicate them.
+	// It is made of the source, the code and where the error was created, so it does not change with IDs in the message.
+	Fingerprint string
+
+	// Suppressed is the number of events with the same fingerprint which were not reported since the previous one.
+	Suppressed int
+
+	// Err is the error itself.
+	Err error
+}
+
+// NewEvent creates the event of the error returned by the handler of source.
+func NewEvent(source, traceID string, err error) Event {
+	event := Event{
+		Time:    time.Now(),
+		Source:  source,
+		TraceID: traceID,
+		Message: err.Error(),
+		Err:     err,
+	}
+
+	o, ok := oops.AsOops(err)
+	if !ok {
+		event.Fingerprint = source + "|" + event.Message
+		return event
+	}
+	event.Code = o.Code()
+	event.Context = o.Context()
+	event.Frames = o.StackFrames()
+	if event.TraceID == "" {
+		event.TraceID = o.Trace()
+	}
+
+	fingerprint := []string{source, event.Code}
+	if len(event.Frames) > 0 {
+		fingerprint = append(fingerprint, fmt.Sprintf("%s:%d", event.Frames[0].Function, event.Frames[0].Line))
+	}
+	event.Fingerprint = strings.Join(fingerprint, "|")
+	return event
+}
+
+// Stacktrace returns the frames where the error was created, one per line.
+func (e Event) Stacktrace() string {
+	lines := make([]string, 0, len(e.Frames))
+	for _, frame := range e.Frames {
+		lines = append(lines, fmt.Sprintf("%s\n\t%s:%d", frame.Function, frame.File, frame.Line))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Reporter receives the errors returned by the handlers.
+type Reporter interface {
+	Report(ctx context.Context, event Event) error
+}
+
+// ReporterFunc is a function which implements Reporter.
+type ReporterFunc func(ctx context.Context, event Event) error
+
+func (f ReporterFunc) Report(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// Log returns the reporter which logs the events at Error with their attributes as structured fields.
+// The trace ID is not added, because the logger of the context of a handler already carries it.
+func Log() Reporter {
+	return ReporterFunc(func(ctx context.Context, event Event) error {
+		fields := []zap.Field{
+			zap.String("event", event.Source),
+			zap.String("error", event.Message),
+		}
+		if event.Code != "" {
+			fields = append(fields, zap.String("code", event.Code))
+		}
+		if len(event.Context) > 0 {
+			fields = append(fields, zap.Any("context", event.Context))
+		}
+		if len(event.Frames) > 0 {
+			fields = append(fields, zap.String("error_stack", event.Stacktrace()))
+		}
+		logging.Named(ctx, loggerName).Error("error occurred in handler", fields...)
+		return nil
+	})
+}
+
+// Multi returns the reporter which reports the events to every reporter, joining their errors.
+func Multi(reporters ...Reporter) Reporter {
+	return ReporterFunc(func(ctx context.Context, event Event) error {
+		errs := make([]error, 0)
+		for _, reporter := range reporters {
+			if err := reporter.Report(ctx, event); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
@@ -0,0 +1,132 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samber/oops"
+)
+
+func TestNewEvent(t *testing.T) {
+	t.Parallel()
+
+	t.Run("attributes of oops errors are kept", func(t *testing.T) {
+		t.Parallel()
+
+		newErr := func(messageID string) error {
+			return oops.Code("fetch_failed").Trace("error-trace").With("message_id", messageID).
+				Wrapf(errors.New("not found"), "failed to fetch (message_id = %s)", messageID)
+		}
+		event := NewEvent("MessageCreate", "", newErr("1"))
+
+		if event.TraceID != "error-trace" {
+			t.Errorf("expected the trace ID of the error but received %q", event.TraceID)
+		}
+		if event.Code != "fetch_failed" {
+			t.Errorf("expected the code of the error but received %q", event.Code)
+		}
+		if event.Context["message_id"] != "1" {
+			t.Errorf("expected the context of the error but received %v", event.Context)
+		}
+		if !strings.Contains(event.Stacktrace(), "TestNewEvent") {
+			t.Errorf("expected the stacktrace to contain the test but received %q", event.Stacktrace())
+		}
+		if other := NewEvent("MessageCreate", "", newErr("2")); other.Fingerprint != event.Fingerprint {
+			t.Errorf("expected the same fingerprint regardless of the IDs but received %q and %q", event.Fingerprint, other.Fingerprint)
+		}
+	})
+
+	t.Run("trace ID of the event takes precedence", func(t *testing.T) {
+		t.Parallel()
+
+		event := NewEvent("MessageCreate", "event-trace", oops.Trace("error-trace").Errorf("failed"))
+		if event.TraceID != "event-trace" {
+			t.Errorf("expected the trace ID of the event but received %q", event.TraceID)
+		}
+	})
+
+	t.Run("other errors are reported with their message", func(t *testing.T) {
+		t.Parallel()
+
+		event := NewEvent("MessageCreate", "event-trace", errors.New("failed"))
+		if event.Message != "failed" || event.Context != nil || event.Fingerprint == "" {
+			t.Errorf("expected only the message to be set but received %+v", event)
+		}
+	})
+}
+
+func TestLimit(t *testing.T) {
+	t.Parallel()
+
+	newLimit := func(option ...LimitOption) (Reporter, *[]Event, *time.Time) {
+		reported := make([]Event, 0)
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		reporter := ReporterFunc(func(_ context.Context, event Event) error {
+			reported = append(reported, event)
+			return nil
+		})
+		option = append(option, withLimitClock(func() time.Time { return now }))
+		return Limit(reporter, option...), &reported, &now
+	}
+
+	t.Run("duplicates are reported once in the window with their number", func(t *testing.T) {
+		t.Parallel()
+
+		limit, reported, now := newLimit(WithDedupeWindow(time.Minute), WithRate(0, time.Second))
+		for range 3 {
+			_ = limit.Report(context.Background(), Event{Fingerprint: "a"})
+		}
+		_ = limit.Report(context.Background(), Event{Fingerprint: "b"})
+		if len(*reported) != 2 {
+			t.Fatalf("expected 2 reports but received %d", len(*reported))
+		}
+
+		*now = now.Add(time.Minute)
+		_ = limit.Report(context.Background(), Event{Fingerprint: "a"})
+		if len(*reported) != 3 || (*reported)[2].Suppressed != 2 {
+			t.Errorf("expected the suppressed duplicates to be reported but received %+v", *reported)
+		}
+	})
+
+	t.Run("events exceeding the rate are dropped", func(t *testing.T) {
+		t.Parallel()
+
+		limit, reported, now := newLimit(WithDedupeWindow(0), WithRate(2, time.Minute))
+		for range 3 {
+			_ = limit.Report(context.Background(), Event{Fingerprint: "a"})
+		}
+		if len(*reported) != 2 {
+			t.Fatalf("expected 2 reports but received %d", len(*reported))
+		}
+
+		*now = now.Add(time.Minute)
+		_ = limit.Report(context.Background(), Event{Fingerprint: "a"})
+		if len(*reported) != 3 {
+			t.Errorf("expected the rate to be regained but received %d reports", len(*reported))
+		}
+	})
+}
+
+func TestHistory(t *testing.T) {
+	t.Parallel()
+
+	history := NewHistory(3)
+	if events := history.Events(); len(events) != 0 {
+		t.Errorf("expected no events but received %+v", events)
+	}
+
+	for _, message := range []string{"a", "b", "c", "d"} {
+		_ = history.Report(context.Background(), Event{Message: message})
+	}
+	events := history.Events()
+	messages := make([]string, 0, len(events))
+	for _, event := range events {
+		messages = append(messages, event.Message)
+	}
+	if strings.Join(messages, "") != "dcb" {
+		t.Errorf("expected the latest 3 events, the latest first, but received %v", messages)
+	}
+}
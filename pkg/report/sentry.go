@@ -0,0 +1,205 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrInvalidDSN is returned when the DSN of Sentry can not be parsed.
+var ErrInvalidDSN = errors.New("invalid sentry DSN")
+
+// DefaultHTTPTimeout is the timeout of the requests to report an event.
+const DefaultHTTPTimeout = 10 * time.Second
+
+// sentryClient is the name of felm sent to Sentry.
+const sentryClient = "felm/1.0"
+
+// HTTPOption configures the reporters calling an HTTP endpoint.
+type HTTPOption func(*http.Client)
+
+// WithHTTPClient replaces the HTTP client, e.g. to set a proxy.
+func WithHTTPClient(client *http.Client) HTTPOption {
+	return func(c *http.Client) {
+		if client != nil {
+			*c = *client
+		}
+	}
+}
+
+// WithTimeout sets the timeout of the requests.
+func WithTimeout(timeout time.Duration) HTTPOption {
+	return func(c *http.Client) {
+		if timeout > 0 {
+			c.Timeout = timeout
+		}
+	}
+}
+
+func newHTTPClient(option ...HTTPOption) *http.Client {
+	client := &http.Client{Timeout: DefaultHTTPTimeout}
+	for _, opt := range option {
+		opt(client)
+	}
+	return client
+}
+
+// Sentry reports the events to the store endpoint of Sentry, or of a compatible server such as GlitchTip.
+type Sentry struct {
+	client   *http.Client
+	endpoint string
+	key      string
+}
+
+// NewSentry creates the reporter sending the events to the project of the DSN, e.g. https://key@sentry.example.com/42.
+func NewSentry(dsn string, option ...HTTPOption) (*Sentry, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidDSN, err)
+	}
+	project := path.Base(u.Path)
+	if u.Scheme == "" || u.Host == "" || u.User == nil || u.User.Username() == "" || project == "." || project == "/" {
+		return nil, fmt.Errorf("%w: must be like https://key@host/project", ErrInvalidDSN)
+	}
+
+	endpoint := url.URL{
+		Scheme: u.Scheme,
+		Host:   u.Host,
+		Path:   path.Join(path.Dir(u.Path), "api", project, "store") + "/",
+	}
+	return &Sentry{
+		client:   newHTTPClient(option...),
+		endpoint: endpoint.String(),
+		key:      u.User.Username(),
+	}, nil
+}
+
+// sentryEvent is the event in the format of the store endpoint.
+type sentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Level       string            `json:"level"`
+	Logger      string            `json:"logger"`
+	Platform    string            `json:"platform"`
+	Message     sentryMessage     `json:"message"`
+	Exception   sentryExceptions  `json:"exception"`
+	Tags        map[string]string `json:"tags"`
+	Extra       map[string]any    `json:"extra,omitempty"`
+	Fingerprint []string          `json:"fingerprint"`
+}
+
+type sentryMessage struct {
+	Formatted string `json:"formatted"`
+}
+
+type sentryExceptions struct {
+	Values []sentryException `json:"values"`
+}
+
+type sentryException struct {
+	Type       string            `json:"type"`
+	Value      string            `json:"value"`
+	Stacktrace *sentryStacktrace `json:"stacktrace,omitempty"`
+}
+
+type sentryStacktrace struct {
+	Frames []sentryFrame `json:"frames"`
+}
+
+type sentryFrame struct {
+	Function string `json:"function"`
+	Filename string `json:"filename"`
+	Lineno   int    `json:"lineno"`
+}
+
+func (s *Sentry) Report(ctx context.Context, event Event) error {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+
+	tags := map[string]string{"source": event.Source}
+	if event.TraceID != "" {
+		tags["trace_id"] = event.TraceID
+	}
+	if event.Code != "" {
+		tags["code"] = event.Code
+	}
+	extra := make(map[string]any, len(event.Context)+1)
+	for key, value := range event.Context {
+		extra[key] = fmt.Sprint(value)
+	}
+	if event.Suppressed > 0 {
+		extra["suppressed"] = event.Suppressed
+	}
+
+	exception := sentryException{Type: rootType(event.Err), Value: event.Message}
+	if len(event.Frames) > 0 {
+		// sentry expects the frames from the oldest call to the newest one.
+		frames := make([]sentryFrame, 0, len(event.Frames))
+		for _, frame := range slices.Backward(event.Frames) {
+			frames = append(frames, sentryFrame{Function: frame.Function, Filename: frame.File, Lineno: frame.Line})
+		}
+		exception.Stacktrace = &sentryStacktrace{Frames: frames}
+	}
+
+	body, err := json.Marshal(sentryEvent{
+		EventID:     hex.EncodeToString(id),
+		Timestamp:   event.Time.UTC().Format(time.RFC3339Nano),
+		Level:       "error",
+		Logger:      event.Source,
+		Platform:    "go",
+		Message:     sentryMessage{Formatted: event.Message},
+		Exception:   sentryExceptions{Values: []sentryException{exception}},
+		Tags:        tags,
+		Extra:       extra,
+		Fingerprint: []string{event.Fingerprint},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode the sentry event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create the sentry request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", strings.Join([]string{
+		"Sentry sentry_version=7",
+		"sentry_client=" + sentryClient,
+		"sentry_key=" + s.key,
+	}, ", "))
+	return send(s.client, req, "sentry")
+}
+
+// rootType returns the type of the innermost error, which is more specific than the wrapping oops error.
+func rootType(err error) string {
+	for {
+		inner := errors.Unwrap(err)
+		if inner == nil {
+			return fmt.Sprintf("%T", err)
+		}
+		err = inner
+	}
+}
+
+// send sends the request and returns an error when the endpoint does not accept it.
+func send(client *http.Client, req *http.Request, name string) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to report the error to %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to report the error to %s: %s", name, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/samber/oops"
+)
+
+func TestNewSentry(t *testing.T) {
+	t.Parallel()
+
+	for _, dsn := range []string{"", "https://sentry.example.com/42", "https://key@sentry.example.com", "://key@host/1"} {
+		if _, err := NewSentry(dsn); !errors.Is(err, ErrInvalidDSN) {
+			t.Errorf("expected ErrInvalidDSN for %q but received %v", dsn, err)
+		}
+	}
+}
+
+func TestSentryReport(t *testing.T) {
+	t.Parallel()
+
+	var (
+		path, auth string
+		body       sentryEvent
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("X-Sentry-Auth")
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	dsn := strings.Replace(server.URL, "http://", "http://public-key@", 1) + "/sentry/42"
+	sentry, err := NewSentry(dsn)
+	if err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+	event := NewEvent("MessageCreate", "trace", oops.Code("fetch_failed").With("guild_id", "1").Errorf("failed"))
+	if err := sentry.Report(context.Background(), event); err != nil {
+		t.Fatalf("expected err to be nil but received %v", err)
+	}
+
+	if path != "/sentry/api/42/store/" {
+		t.Errorf("expected the store endpoint of the project but received %s", path)
+	}
+	if !strings.Contains(auth, "sentry_key=public-key") {
+		t.Errorf("expected the key in the auth header but received %s", auth)
+	}
+	if body.Tags["trace_id"] != "trace" || body.Tags["code"] != "fetch_failed" || body.Extra["guild_id"] != "1" {
+		t.Errorf("expected the attributes in the event but received %+v", body)
+	}
+	if len(body.Exception.Values) != 1 || body.Exception.Values[0].Stacktrace == nil {
+		t.Errorf("expected the exception with the stacktrace but received %+v", body.Exception)
+	}
+	if len(body.EventID) != 32 {
+		t.Errorf("expected an event ID of 32 hex digits but received %q", body.EventID)
+	}
+}
+
+func TestSentryReportRejected(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	sentry, _ := NewSentry(strings.Replace(server.URL, "http://", "http://key@", 1) + "/1")
+	if err := sentry.Report(context.Background(), NewEvent("MessageCreate", "", errors.New("failed"))); err == nil {
+		t.Error("expected an error when the event is rejected")
+	}
+}
@@ -0,0 +1,173 @@
+// Package secret resolves secrets referred by references such as file:///run/secrets/token,
+// so that they do not have to be passed on the command line or in the environment of the process.
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned when the secret referred by a reference does not exist.
+	ErrNotFound = errors.New("secret not found")
+
+	// ErrUnknownScheme is returned when no provider is registered for the scheme of a reference.
+	ErrUnknownScheme = errors.New("unknown secret scheme")
+)
+
+const (
+	// SchemeFile refers to a file, e.g. file:///run/secrets/token.
+	SchemeFile = "file"
+
+	// SchemeEnv refers to an environment variable, e.g. env://DISCORD_TOKEN.
+	SchemeEnv = "env"
+)
+
+// schemeSeparator separates the scheme and the name of a reference.
+const schemeSeparator = "://"
+
+// Provider returns the secrets of a scheme.
+type Provider interface {
+	// Resolve returns the secret of the name, which is the part of the reference after the scheme.
+	Resolve(ctx context.Context, name string) (string, error)
+}
+
+// ProviderFunc is a function which implements Provider.
+type ProviderFunc func(ctx context.Context, name string) (string, error)
+
+// Resolve calls f.
+func (f ProviderFunc) Resolve(ctx context.Context, name string) (string, error) {
+	return f(ctx, name)
+}
+
+// Resolver resolves references with the provider registered for their scheme.
+type Resolver struct {
+	providers map[string]Provider
+}
+
+type Option func(*Resolver)
+
+// WithProvider registers the provider of the scheme. It replaces the provider already registered.
+func WithProvider(scheme string, provider Provider) Option {
+	return func(r *Resolver) {
+		r.providers[strings.ToLower(scheme)] = provider
+	}
+}
+
+// NewResolver creates a resolver which resolves file:// and env:// references in addition to the given providers.
+func NewResolver(option ...Option) *Resolver {
+	r := &Resolver{
+		providers: map[string]Provider{
+			SchemeFile: ProviderFunc(ReadFile),
+			SchemeEnv:  ProviderFunc(LookupEnv),
+		},
+	}
+	for _, opt := range option {
+		opt(r)
+	}
+	return r
+}
+
+// Reference returns the reference to the name of the scheme.
+func Reference(scheme, name string) string {
+	return scheme + schemeSeparator + name
+}
+
+// Resolve returns the secret referred by value. A value which is not a reference is returned as it is,
+// so that a secret can still be given directly.
+// The errors never contain the secret itself.
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	scheme, name, ok := strings.Cut(value, schemeSeparator)
+	if !ok || !isScheme(scheme) {
+		return value, nil
+	}
+
+	provider, ok := r.providers[strings.ToLower(scheme)]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownScheme, scheme)
+	}
+	secret, err := provider.Resolve(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the secret (scheme = %s, name = %s): %w", scheme, name, err)
+	}
+	if secret == "" {
+		return "", fmt.Errorf("secret is empty (scheme = %s, name = %s): %w", scheme, name, ErrNotFound)
+	}
+	return secret, nil
+}
+
+// isScheme reports whether s is a URI scheme, so that secrets which happen to contain :// are not taken as references.
+func isScheme(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z':
+		case i > 0 && ('0' <= r && r <= '9' || r == '+' || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ReadFile returns the content of the file without the trailing newline, as mounted by Docker and Kubernetes secrets.
+func ReadFile(_ context.Context, path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// LookupEnv returns the value of the environment variable.
+func LookupEnv(_ context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("%w: environment variable %s is not set", ErrNotFound, name)
+	}
+	return value, nil
+}
+
+// KVFile is a provider which reads secrets from a JSON file of paths to key-value pairs, e.g.
+// {"secret/felm": {"token": "..."}}. A secret is referred by the path and the key, e.g. secret/felm#token.
+// It stands in for a secret store such as Vault on a single host and in tests.
+type KVFile struct {
+	path string
+}
+
+// NewKVFile creates a provider of the secrets in the file. The file is read on every resolution to follow its updates.
+func NewKVFile(path string) *KVFile {
+	return &KVFile{path: path}
+}
+
+// Resolve returns the secret of the key in the path given as path#key.
+func (f *KVFile) Resolve(_ context.Context, name string) (string, error) {
+	path, key, ok := strings.Cut(name, "#")
+	if !ok || path == "" || key == "" {
+		return "", fmt.Errorf("name must be path#key but is %s", name)
+	}
+
+	content, err := os.ReadFile(f.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the secret store: %w", err)
+	}
+	var store map[string]map[string]string
+	if err := json.Unmarshal(content, &store); err != nil {
+		// the error of encoding/json may quote the content, so it is not wrapped.
+		return "", errors.New("failed to decode the secret store")
+	}
+	secret, ok := store[path][key]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return secret, nil
+}
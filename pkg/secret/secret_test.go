@@ -0,0 +1,91 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+	return path
+}
+
+func TestResolver(t *testing.T) {
+	const token = "very-secret-token"
+
+	tokenFile := writeFile(t, "token", token+"\n")
+	storeFile := writeFile(t, "store.json", `{"secret/felm": {"token": "`+token+`"}}`)
+	t.Setenv("FELM_TEST_SECRET", token)
+
+	resolver := NewResolver(WithProvider("kv", NewKVFile(storeFile)))
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr error
+	}{
+		{"literal", token, token, nil},
+		{"literal with separator", "abc://def", "", ErrUnknownScheme},
+		{"not a scheme", "a b://c", "a b://c", nil},
+		{"file", Reference(SchemeFile, tokenFile), token, nil},
+		{"missing file", Reference(SchemeFile, tokenFile+".missing"), "", ErrNotFound},
+		{"env", "env://FELM_TEST_SECRET", token, nil},
+		{"missing env", "env://FELM_TEST_MISSING", "", ErrNotFound},
+		{"kv", "kv://secret/felm#token", token, nil},
+		{"missing kv key", "kv://secret/felm#password", "", ErrNotFound},
+		{"scheme is case insensitive", "ENV://FELM_TEST_SECRET", token, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := resolver.Resolve(context.Background(), tt.value)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v but received %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected err to be nil but received %v", err)
+			}
+			if actual != tt.want {
+				t.Errorf("expected %q but received %q", tt.want, actual)
+			}
+		})
+	}
+}
+
+func TestResolverEmptySecret(t *testing.T) {
+	t.Parallel()
+
+	resolver := NewResolver(WithProvider("empty", ProviderFunc(func(context.Context, string) (string, error) {
+		return "", nil
+	})))
+	if _, err := resolver.Resolve(context.Background(), "empty://name"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound but received %v", err)
+	}
+}
+
+func TestKVFileDoesNotLeakContent(t *testing.T) {
+	t.Parallel()
+
+	const token = "very-secret-token"
+	storeFile := writeFile(t, "store.json", `{"secret/felm": "`+token+`"}`)
+
+	_, err := NewKVFile(storeFile).Resolve(context.Background(), "secret/felm#token")
+	if err == nil {
+		t.Fatal("expected an error for the malformed store")
+	}
+	if strings.Contains(err.Error(), token) {
+		t.Errorf("expected the error not to contain the content but received %v", err)
+	}
+}
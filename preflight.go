@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aqyuki/felm/internal/app"
+	"github.com/aqyuki/felm/internal/app/handler"
+	"github.com/aqyuki/felm/pkg/discord"
+	"github.com/aqyuki/felm/pkg/logging"
+	"github.com/aqyuki/felm/pkg/secret"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var preflightCmd = &cobra.Command{
+	Use:   "preflight",
+	Short: "preflight checks the token, the intents and the permissions of the bot in every guild",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		// the errors are reported in the output, so that cobra does not print them again.
+		cmd.SilenceErrors = true
+		fail := func(err error) error {
+			fmt.Fprintln(os.Stderr, err)
+			return err
+		}
+
+		format, _ := cmd.Flags().GetString("format")
+		if format != "table" && format != "json" {
+			return fail(fmt.Errorf("format must be table or json but is %q", format))
+		}
+
+		cfg, err := app.LoadConfig(cmd.Context(), viper.GetViper(), secret.NewResolver())
+		if err != nil {
+			return fail(err)
+		}
+		// the handlers are registered only to request the same intents as felm.
+		citation := handler.NewCitationService()
+		options := append([]discord.Option{
+			discord.WithIntents(cfg.GatewayIntents()),
+			discord.WithMessageCreateHandler(citation.On),
+		}, citation.InvalidationHandlers()...)
+		conn := discord.NewConn(cfg.Token, options...)
+
+		report, err := conn.Preflight(cmd.Context())
+		if errors.Is(err, discord.ErrUnauthorized) {
+			return fail(fmt.Errorf("token was rejected by discord: %w", err))
+		}
+		if err != nil {
+			return fail(fmt.Errorf("preflight failed: %w", err))
+		}
+
+		if format == "json" {
+			encoder := json.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(report); err != nil {
+				return err
+			}
+		} else {
+			printPreflightReport(cmd.OutOrStdout(), report)
+		}
+		if !report.OK() {
+			return fail(errMissingIntents(report))
+		}
+		return nil
+	},
+}
+
+// errMissingIntents returns the error of the privileged intents which are not enabled.
+func errMissingIntents(report *discord.PreflightReport) error {
+	return fmt.Errorf("%w: enable %s in the developer portal or remove them from FELM_INTENTS",
+		discord.ErrDisallowedIntents, strings.Join(report.Intents.Missing, ", "))
+}
+
+// printPreflightReport writes the report as tables for humans.
+func printPreflightReport(w io.Writer, report *discord.PreflightReport) {
+	missing := "none"
+	if len(report.Intents.Missing) > 0 {
+		missing = strings.Join(report.Intents.Missing, ", ")
+	}
+	fmt.Fprintf(w, "bot:             %s (%s)\n", report.Bot.Username, report.Bot.ID)
+	fmt.Fprintf(w, "intents:         %s\n", strings.Join(report.Intents.Requested, ", "))
+	fmt.Fprintf(w, "missing intents: %s\n", missing)
+	fmt.Fprintf(w, "guilds:          %d\n\n", len(report.Guilds))
+
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "GUILD\tCHANNEL\tREAD\tEMBED\tMISSING")
+	problems := 0
+	for _, guild := range report.Guilds {
+		if guild.Error != "" {
+			problems++
+			fmt.Fprintf(tw, "%s (%s)\t-\t-\t-\t%s\n", guild.Name, guild.ID, guild.Error)
+		}
+		for _, channel := range guild.Channels {
+			problems++
+			fmt.Fprintf(tw, "%s (%s)\t#%s (%s)\t%s\t%s\t%s\n",
+				guild.Name, guild.ID, channel.Name, channel.ID,
+				yesNo(channel.CanRead), yesNo(channel.CanEmbed), strings.Join(channel.Missing, ", "))
+		}
+	}
+	if problems == 0 {
+		fmt.Fprintln(w, "every channel can be read and cited")
+		return
+	}
+	tw.Flush()
+}
+
+// runPreflight runs the preflight before connecting and logs the channels with missing permissions.
+// It returns an error when felm can not connect, so that the problem is reported before identifying to the gateway.
+func runPreflight(ctx context.Context, conn *discord.Conn) error {
+	logger := logging.FromContext(ctx)
+
+	report, err := conn.Preflight(ctx)
+	if err != nil {
+		return fmt.Errorf("preflight failed: %w", err)
+	}
+	logger.Info("preflight finished",
+		zap.String("bot_id", report.Bot.ID),
+		zap.String("bot_username", report.Bot.Username),
+		zap.Int("guilds", len(report.Guilds)))
+	for _, guild := range report.Guilds {
+		if guild.Error != "" {
+			logger.Warn("preflight could not inspect the guild", zap.String("guild_id", guild.ID), zap.String("error", guild.Error))
+		}
+		for _, channel := range guild.Channels {
+			logger.Warn("bot lacks permissions in the channel",
+				zap.String("guild_id", guild.ID),
+				zap.String("channel_id", channel.ID),
+				zap.Bool("can_read", channel.CanRead),
+				zap.Bool("can_embed", channel.CanEmbed),
+				zap.Strings("missing", channel.Missing))
+		}
+	}
+	if !report.OK() {
+		return errMissingIntents(report)
+	}
+	return nil
+}
+
+func init() {
+	preflightCmd.Flags().String("format", "table", "format is an output format (table or json).")
+}
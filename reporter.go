@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aqyuki/felm/internal/app"
+	"github.com/aqyuki/felm/pkg/report"
+	"github.com/bwmarrin/discordgo"
+)
+
+// newErrorReporter returns the reporter logging the errors of the handlers and sending them to the configured sinks.
+// The sinks are de-duplicated and rate limited, while every error is logged. The configuration must have been validated.
+func newErrorReporter(cfg *app.Config) (report.Reporter, error) {
+	sinks := make([]report.Reporter, 0, 3)
+	if cfg.Report.SentryDSN != "" {
+		sentry, err := report.NewSentry(cfg.Report.SentryDSN, report.WithTimeout(cfg.Timeout))
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sentry)
+	}
+	if cfg.Report.Webhook != "" {
+		sinks = append(sinks, report.NewWebhook(cfg.Report.Webhook, report.WithTimeout(cfg.Timeout)))
+	}
+	if cfg.Report.ChannelID != "" {
+		session, err := newRESTSession(cfg.Token)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, report.NewChannel(session, cfg.Report.ChannelID))
+	}
+
+	if len(sinks) == 0 {
+		return report.Log(), nil
+	}
+	return report.Multi(report.Log(), report.Limit(report.Multi(sinks...),
+		report.WithDedupeWindow(cfg.Report.DedupeWindow),
+		report.WithRate(cfg.Report.Burst, cfg.Report.Interval))), nil
+}
+
+// newRESTSession creates the session used only to call the REST API, which is never opened.
+func newRESTSession(token string) (*discordgo.Session, error) {
+	session, err := discordgo.New("Bot " + token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the REST session: %w", err)
+	}
+	return session, nil
+}